@@ -1,10 +1,12 @@
 module github.com/Automata-Labs-team/code-sandbox-mcp
 
-go 1.22.9
+go 1.23.0
 
 require (
+	github.com/distribution/reference v0.6.0
 	github.com/docker/docker v28.0.2+incompatible
 	github.com/mark3labs/mcp-go v0.15.0
+	github.com/opencontainers/image-spec v1.1.1
 )
 
 require (
@@ -19,7 +21,6 @@ require (
 
 require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
-	github.com/distribution/reference v0.6.0 // indirect
 	github.com/docker/go-connections v0.5.0 // indirect
 	github.com/docker/go-units v0.5.0 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
@@ -29,7 +30,6 @@ require (
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/moby/docker-image-spec v1.3.1 // indirect
 	github.com/opencontainers/go-digest v1.0.0 // indirect
-	github.com/opencontainers/image-spec v1.1.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.60.0 // indirect
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Automata-Labs-team/code-sandbox-mcp/installer"
 	"github.com/Automata-Labs-team/code-sandbox-mcp/resources"
@@ -14,6 +20,15 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// The Docker connection flags are registered at package level, so they are already known when init parses the command line
+var (
+	dockerHostFlag       = flag.String("docker-host", "", "Docker daemon address, e.g. tcp://sandbox-host:2376 (default: DOCKER_HOST or the local socket)")
+	dockerTLSCACertFlag  = flag.String("docker-tlscacert", "", "CA certificate used to verify the Docker daemon")
+	dockerTLSCertFlag    = flag.String("docker-tlscert", "", "TLS client certificate presented to the Docker daemon")
+	dockerTLSKeyFlag     = flag.String("docker-tlskey", "", "TLS client key presented to the Docker daemon")
+	dockerAPIVersionFlag = flag.String("docker-api-version", "", "Docker API version to use instead of negotiating it, e.g. 1.47")
+)
+
 func init() {
 	// Check for --install flag
 	installFlag := flag.Bool("install", false, "Add this binary to Claude Desktop config")
@@ -47,6 +62,9 @@ func main() {
 	port := flag.String("port", "9520", "Port to listen on")
 	transport := flag.String("transport", "stdio", "Transport to use (stdio, sse)")
 	flag.Parse()
+	// Libraries that log through the standard logger end up in the server's log too
+	slog.SetDefault(tools.Logger())
+	defaultMemoryMB, defaultCPULimit, defaultPidsLimit := tools.DefaultLimits()
 	s := server.NewMCPServer("code-sandbox-mcp", "v1.0.0", server.WithLogging(), server.WithResourceCapabilities(true, true), server.WithPromptCapabilities(false))
 	s.AddNotificationHandler("notifications/error", handleNotification)
 	// Register tools
@@ -55,11 +73,190 @@ func main() {
 		mcp.WithDescription(
 			"Initialize a new compute environment for code execution. \n"+
 				"Creates a container based on the specified Docker image or defaults to a slim debian image with Python. \n"+
-				"Returns a container_id that can be used with other tools to interact with this environment.",
+				"Returns a JSON object with the container_id, image and status; the container_id can be used with other tools to interact with this environment.",
+		),
+		mcp.WithString("name",
+			mcp.Description("Optional friendly name for the sandbox, usable instead of the container_id in other tools. The container is named with a 'csmcp-' prefix; an error is returned if the name is already in use"),
 		),
 		mcp.WithString("image",
 			mcp.Description("Docker image to use as the base environment (e.g., 'python:3.12-slim-bookworm')"),
-			mcp.DefaultString("python:3.12-slim-bookworm"),
+			mcp.DefaultString(tools.DefaultImage()),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Absolute path of the working directory inside the container. Relative paths given to the file tools are resolved against it"),
+			mcp.DefaultString("/app"),
+		),
+		mcp.WithString("workdir_mode",
+			mcp.Description("Octal permission mode of the working directory, e.g. 0770. The owner, the sandbox user unless run_as_root is set, always keeps full access"),
+			mcp.DefaultString("0755"),
+		),
+		mcp.WithString("platform",
+			mcp.Description("Platform to run, e.g. linux/amd64 or linux/arm64. Foreign architectures run under emulation, which needs qemu binfmt handlers on the Docker host"),
+		),
+		mcp.WithBoolean("allow_pull",
+			mcp.Description("Pull the image from its registry when it isn't available locally. When false, only pre-loaded images can be used"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("run_as_root",
+			mcp.Description("Run code as root instead of the unprivileged user 1000:1000. Only enable this for workloads that need it"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithArray("cap_add",
+			mcp.Description("Linux capabilities to add back to the sandbox, which otherwise runs with all capabilities dropped"),
+			mcp.Description("Example: [\"NET_BIND_SERVICE\", \"CHOWN\"]"),
+			tools.StringItems(),
+		),
+		mcp.WithString("network",
+			mcp.Description("Network for the sandbox: 'none' (offline), 'bridge' (outbound access) or the name of an existing Docker network"),
+			mcp.DefaultString("none"),
+		),
+		mcp.WithString("security_preset",
+			mcp.Description("Bundle of hardening settings for the sandbox: 'none' (each option keeps its default), 'standard' or 'strict', "+
+				"which adds a read-only root filesystem and tighter memory, CPU and process limits and is recommended for untrusted code. "+
+				"Options given explicitly override the preset"),
+			mcp.Enum(tools.SecurityPresets()...),
+			mcp.DefaultString("none"),
+		),
+		mcp.WithArray("networks",
+			mcp.Description("Existing user-defined networks the sandbox joins, so it can reach other containers on them by name. "+
+				"Example: [\"backend\", \"db\"]. Without network the sandbox is created on the first one"),
+			tools.StringItems(),
+		),
+		mcp.WithArray("network_aliases",
+			mcp.Description("Extra hostnames the sandbox is reachable by on each of its networks. Example: [\"sandbox\"]"),
+			tools.StringItems(),
+		),
+		mcp.WithArray("dns",
+			mcp.Description("IP addresses of the DNS servers the sandbox resolves names with, instead of the daemon's. "+
+				"Example: [\"10.0.0.53\"]. Ignored with network 'none'"),
+			tools.StringItems(),
+		),
+		mcp.WithArray("extra_hosts",
+			mcp.Description("Static hostname:ip entries added to the sandbox's /etc/hosts. "+
+				"Example: [\"db.internal:10.0.0.5\"]. Ignored with network 'none'"),
+			tools.StringItems(),
+		),
+		mcp.WithString("runtime",
+			mcp.Description("OCI runtime for the sandbox, e.g. 'runsc' for gVisor's stronger isolation. Must be one of the runtimes configured on the Docker daemon; defaults to the daemon's default runtime"),
+		),
+		mcp.WithString("seccomp_profile",
+			mcp.Description("Seccomp profile restricting the syscalls of sandboxed code: 'default' (Docker's profile), "+
+				"the absolute path of a JSON profile on the server's host, or 'unconfined' if the operator allows it"),
+			mcp.DefaultString("default"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables to set in the sandbox, as an object of names to values or an array of KEY=VALUE strings. "+
+				"Values are visible to everything running in the container, so don't pass secrets the sandboxed code shouldn't see"),
+			tools.Types("object", "array"),
+		),
+		mcp.WithString("env_file",
+			mcp.Description("Environment variables in dotenv format: the absolute path of a file on the server's host, or the KEY=VALUE lines themselves. "+
+				"Blank lines and # comments are skipped, later lines override earlier ones, and env entries override the file"),
+		),
+		mcp.WithObject("secrets",
+			mcp.Description("Secrets as an object of file names to contents, written to read-only files in /run/secrets instead of the environment, "+
+				"so they don't show up in process listings or the container config. /run/secrets is a tmpfs readable only by the sandbox user; "+
+				"its files are gone once the sandbox stops or restarts"),
+			mcp.Description("Example: {\"api_token\": \"...\"} is read back from /run/secrets/api_token"),
+		),
+		mcp.WithArray("mounts",
+			mcp.Description("Host directories to bind-mount into the sandbox, as host_path:container_path[:ro|rw] strings with absolute paths. "+
+				"Mounts are read-only unless rw is given. SECURITY: sandboxed code can read everything under a mounted host path, "+
+				"and with rw it can modify or delete those host files. Only mount directories you are willing to expose"),
+			mcp.Description("Example: [\"/home/me/project:/app/project:ro\"]"),
+			tools.StringItems(),
+		),
+		mcp.WithArray("volumes",
+			mcp.Description("Named volumes created with sandbox_volume_create to attach, as volume_name:container_path[:ro|rw] strings. "+
+				"Volumes are writable unless ro is given, and keep their data after the sandbox is removed"),
+			mcp.Description("Example: [\"pip-cache:/app/.cache:rw\"]"),
+			tools.StringItems(),
+		),
+		mcp.WithBoolean("readonly_rootfs",
+			mcp.Description("Make the root filesystem read-only. The working directory and /tmp become writable tmpfs mounts"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("tmpfs_tmp",
+			mcp.Description("Mount /tmp as a size-limited, noexec tmpfs so scratch files don't fill the container's disk layer"),
+			mcp.DefaultBool(true),
+		),
+		mcp.WithNumber("tmpfs_size_mb",
+			mcp.Description("Size limit in megabytes of the /tmp tmpfs, and of the working directory tmpfs when readonly_rootfs is set"),
+			mcp.DefaultNumber(64),
+		),
+		mcp.WithString("gpus",
+			mcp.Description("GPUs to pass through, as with docker run --gpus: 'all', a count such as 2, or 'device=0,1'. Needs the NVIDIA Container Toolkit on the Docker host; no GPU by default"),
+			tools.Types("string", "number"),
+		),
+		mcp.WithNumber("storage_mb",
+			mcp.Description("Size limit in megabytes of the container's writable layer. Needs a storage driver with quota support, e.g. overlay2 on xfs with pquota"),
+		),
+		mcp.WithNumber("idle_timeout_seconds",
+			mcp.Description("Remove the sandbox once no tool has used it for this many seconds, instead of after the server-wide idle TTL. "+
+				"Every command, file operation or other tool call on the sandbox restarts the countdown"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("End user or session the sandbox belongs to, stored as a label so sandbox_list and sandbox_stop_all can select its sandboxes. Letters, digits, '_', '.', '@' and '-'"),
+		),
+		mcp.WithObject("metadata",
+			mcp.Description("Key/value metadata to attach to the sandbox, e.g. {\"task_id\": \"42\"}, read back with sandbox_get_metadata. "+
+				"Stored in labels, so it survives server restarts. Keys are letters, digits, '_', '.' and '-'; values are strings"),
+		),
+		mcp.WithBoolean("auto_remove",
+			mcp.Description("Have Docker delete the container as soon as it stops, like docker run --rm. The sandbox keeps running until it is stopped"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("restart_policy",
+			mcp.Description("Have Docker restart the container when its main process exits, for sandboxes running a service with cmd: "+
+				"'no', 'always', 'unless-stopped', 'on-failure' or 'on-failure:N' to give up after N restarts (1 to 100). Can't be combined with auto_remove or run_once"),
+			mcp.DefaultString("no"),
+		),
+		mcp.WithString("cmd",
+			mcp.Description("Main command of the container instead of 'sleep infinity'. A string is run through 'sh -c'; an array is used as is"),
+			tools.Types("string", "array"), tools.StringItems(),
+		),
+		mcp.WithString("entrypoint",
+			mcp.Description("Entrypoint replacing the image's, as an executable or an array of the executable and its arguments. An empty array clears it"),
+			tools.Types("string", "array"), tools.StringItems(),
+		),
+		mcp.WithBoolean("use_image_cmd",
+			mcp.Description("Run the image's own command instead of 'sleep infinity'"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("run_once",
+			mcp.Description("Run cmd (or the image's command) to completion, return its stdout, stderr and exit_code, and remove the container"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("With run_once, maximum time the command may run before the container is removed"),
+			mcp.DefaultNumber(60),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("With run_once, maximum bytes of stdout and of stderr returned"),
+			mcp.DefaultNumber(1048576),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only validate the options against the Docker host, i.e. the image, runtime, network, volumes and image allowlist, "+
+				"and return what would be created without creating or pulling anything"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber("memory_mb",
+			mcp.Description("Memory limit for the container in megabytes"),
+			mcp.DefaultNumber(defaultMemoryMB),
+		),
+		mcp.WithNumber("pids_limit",
+			mcp.Description("Maximum number of processes and threads in the container, which stops fork bombs"),
+			mcp.DefaultNumber(float64(defaultPidsLimit)),
+		),
+		mcp.WithArray("ulimits",
+			mcp.Description("Resource limits as {name, soft, hard} objects, e.g. nofile for open files or nproc. "+
+				"hard defaults to soft. Unless nofile is given, open files are limited to 1024"),
+			mcp.Description("Example: [{\"name\": \"nofile\", \"soft\": 512, \"hard\": 1024}]"),
+			tools.ObjectItems(),
+		),
+		mcp.WithNumber("cpu_limit",
+			mcp.Description("Number of CPUs the container may use (fractions such as 0.5 are allowed)"),
+			mcp.DefaultNumber(defaultCPULimit),
 		),
 	)
 
@@ -71,7 +268,7 @@ func main() {
 		),
 		mcp.WithString("container_id",
 			mcp.Required(),
-			mcp.Description("ID of the container returned from the initialize call"),
+			mcp.Description("ID or name of the container returned from the initialize call"),
 		),
 		mcp.WithString("local_src_dir",
 			mcp.Required(),
@@ -82,6 +279,28 @@ func main() {
 		),
 	)
 
+	// Upload a whole directory tree from an archive or a host directory
+	copyDirectoryTool := mcp.NewTool("copy_directory",
+		mcp.WithDescription(
+			"Copy a whole directory tree into the sandboxed filesystem. \n"+
+				"Accepts either a base64-encoded tar archive (optionally gzip-compressed) or a directory on the server's host, "+
+				"and extracts it under the destination directory, keeping file modes and the directory structure. Uploads are limited in total size and number of files.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("archive_base64",
+			mcp.Description("Base64-encoded tar or tar.gz archive whose entries are extracted relative to dest_dir"),
+		),
+		mcp.WithString("local_src_dir",
+			mcp.Description("Directory on the server's host whose contents are copied; use instead of archive_base64"),
+		),
+		mcp.WithString("dest_dir",
+			mcp.Description("Directory to extract into, relative to the container working dir. Defaults to the working dir itself"),
+		),
+	)
+
 	// Write a file to the sandboxed filesystem
 	writeFileTool := mcp.NewTool("write_file_sandbox",
 		mcp.WithDescription(
@@ -90,16 +309,21 @@ func main() {
 		),
 		mcp.WithString("container_id",
 			mcp.Required(),
-			mcp.Description("ID of the container returned from the initialize call"),
+			mcp.Description("ID or name of the container returned from the initialize call"),
 		),
 		mcp.WithString("file_name",
 			mcp.Required(),
-			mcp.Description("Name of the file to create"),
+			mcp.Description("Name of the file to create. May include subdirectories, which are created as needed"),
 		),
 		mcp.WithString("file_contents",
 			mcp.Required(),
 			mcp.Description("Contents to write to the file"),
 		),
+		mcp.WithString("encoding",
+			mcp.Description("Encoding of file_contents. Use base64 to write binary files byte-for-byte"),
+			mcp.Enum("utf8", "base64"),
+			mcp.DefaultString("utf8"),
+		),
 		mcp.WithString("dest_dir",
 			mcp.Description("Directory to create the file in, relative to the container working dir"),
 			mcp.Description("Default: ${WORKDIR}"),
@@ -114,12 +338,345 @@ func main() {
 		),
 		mcp.WithString("container_id",
 			mcp.Required(),
-			mcp.Description("ID of the container returned from the initialize call"),
+			mcp.Description("ID or name of the container returned from the initialize call"),
 		),
 		mcp.WithArray("commands",
 			mcp.Required(),
 			mcp.Description("List of command(s) to run in the sandboxed environment"),
 			mcp.Description("Example: [\"apt-get update\", \"pip install numpy\", \"python script.py\"]"),
+			tools.Types("array", "string"), tools.StringItems(),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time each command may run before it is killed"),
+			mcp.DefaultNumber(30),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum bytes of stdout and of stderr kept per command; the rest is replaced by a truncation notice"),
+			mcp.DefaultNumber(1048576),
+		),
+		mcp.WithString("user",
+			mcp.Description("Run the commands as this user instead of the sandbox's, e.g. root or 1000:1000. Capabilities stay dropped, so root can't escape the sandbox"),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Run the commands in this directory instead of the working directory, absolute or relative to it"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables for these commands only, as an object of names to values or an array of KEY=VALUE strings. "+
+				"They are added to the sandbox's environment, replacing variables of the same name, and don't change the container"),
+			tools.Types("object", "array"),
+		),
+		mcp.WithBoolean("tty",
+			mcp.Description("Run the commands in a pseudo-terminal, for programs that only color their output or prompt on a terminal. "+
+				"stdout and stderr then can't be told apart and are returned together as the output. Can't be combined with output_file"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("output_file",
+			mcp.Description("Write the commands' stdout and stderr to this file in the sandbox, relative to the working directory, instead of returning them. "+
+				"Read it back with read_file_sandbox or sandbox_download_archive"),
+		),
+	)
+
+	// Run a single command in the sandboxed environment
+	runCommandTool := mcp.NewTool("sandbox_run_command",
+		mcp.WithDescription(
+			"Run a single command in an existing sandbox. \n"+
+				"Executes the command in the container working directory and returns a JSON object with separate stdout, stderr and exit_code fields.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Command to run. A string is run through 'sh -c'; an array of strings is executed directly without a shell"),
+			mcp.Description("Example: \"python main.py\" or [\"python\", \"main.py\"]"),
+			tools.Types("string", "array"), tools.StringItems(),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Text written to the command's standard input, which is then closed"),
+			mcp.Description("Example: \"3\\n1\\n2\\n\" piped into [\"sort\", \"-n\"]"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time the command may run before it is killed"),
+			mcp.DefaultNumber(30),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum bytes of stdout and of stderr returned; the rest is replaced by a truncation notice"),
+			mcp.DefaultNumber(1048576),
+		),
+		mcp.WithString("user",
+			mcp.Description("Run the command as this user instead of the sandbox's, e.g. root or 1000:1000. Capabilities stay dropped, so root can't escape the sandbox"),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Run the command in this directory instead of the working directory, absolute or relative to it"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables for this command only, as an object of names to values or an array of KEY=VALUE strings. "+
+				"They are added to the sandbox's environment, replacing variables of the same name, and don't change the container"),
+			tools.Types("object", "array"),
+		),
+		mcp.WithBoolean("tty",
+			mcp.Description("Run the command in a pseudo-terminal, for programs that only color their output or prompt on a terminal. "+
+				"stdout and stderr then can't be told apart: all output is returned in stdout and stderr is empty. Can't be combined with stdin"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	// Run a single command and stream its output while it runs
+	execStreamTool := mcp.NewTool("sandbox_exec_stream",
+		mcp.WithDescription(
+			"Run a single command in an existing sandbox and stream its output. \n"+
+				"When the request carries a progress token, each line of output is sent as a notifications/progress message as soon as it is printed. "+
+				"The final result is the same JSON object as sandbox_run_command, with the full stdout, stderr and exit_code.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Command to run. A string is run through 'sh -c'; an array of strings is executed directly without a shell"),
+			mcp.Description("Example: \"python train.py\" or [\"python\", \"train.py\"]"),
+			tools.Types("string", "array"), tools.StringItems(),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Text written to the command's standard input, which is then closed"),
+			mcp.Description("Example: \"3\\n1\\n2\\n\" piped into [\"sort\", \"-n\"]"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time the command may run before it is killed"),
+			mcp.DefaultNumber(30),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum bytes of stdout and of stderr in the final result; every line is still streamed"),
+			mcp.DefaultNumber(1048576),
+		),
+		mcp.WithString("user",
+			mcp.Description("Run the command as this user instead of the sandbox's, e.g. root or 1000:1000. Capabilities stay dropped, so root can't escape the sandbox"),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Run the command in this directory instead of the working directory, absolute or relative to it"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables for this command only, as an object of names to values or an array of KEY=VALUE strings. "+
+				"They are added to the sandbox's environment, replacing variables of the same name, and don't change the container"),
+			tools.Types("object", "array"),
+		),
+		mcp.WithBoolean("tty",
+			mcp.Description("Run the command in a pseudo-terminal, for programs that only color their output or prompt on a terminal. "+
+				"stdout and stderr then can't be told apart: all output is returned in stdout and stderr is empty. Can't be combined with stdin"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	// Start a long-running command in the background, to be polled and cancelled by later calls
+	startExecTool := mcp.NewTool("sandbox_exec_start",
+		mcp.WithDescription(
+			"Start a long-running command in an existing sandbox without waiting for it. \n"+
+				"Returns a JSON object with the exec_id, the status and any output already produced. "+
+				"Poll the command with sandbox_exec_read and stop it with sandbox_exec_cancel.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Command to run. A string is run through 'sh -c'; an array of strings is executed directly without a shell"),
+			mcp.Description("Example: \"python train.py\" or [\"python\", \"train.py\"]"),
+			tools.Types("string", "array"), tools.StringItems(),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time the command may run before it is killed"),
+			mcp.DefaultNumber(3600),
+		),
+		mcp.WithString("user",
+			mcp.Description("Run the command as this user instead of the sandbox's, e.g. root or 1000:1000. Capabilities stay dropped, so root can't escape the sandbox"),
+		),
+		mcp.WithString("workdir",
+			mcp.Description("Run the command in this directory instead of the working directory, absolute or relative to it"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables for this command only, as an object of names to values or an array of KEY=VALUE strings. "+
+				"They are added to the sandbox's environment, replacing variables of the same name, and don't change the container"),
+			tools.Types("object", "array"),
+		),
+		mcp.WithBoolean("tty",
+			mcp.Description("Run the command in a pseudo-terminal, for programs that only color their output or prompt on a terminal. "+
+				"stdout and stderr then can't be told apart: all output is returned in stdout and stderr is empty"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	readExecTool := mcp.NewTool("sandbox_exec_read",
+		mcp.WithDescription(
+			"Read the status of a command started with sandbox_exec_start and the output it produced since it was last read. \n"+
+				"status is running, exited, cancelled, timed_out or failed; exit_code is set once it has exited. "+
+				"The final output of a finished command can be read once, after which the exec_id is no longer known.",
+		),
+		mcp.WithString("exec_id",
+			mcp.Required(),
+			mcp.Description("ID of the command returned from sandbox_exec_start"),
+		),
+		mcp.WithNumber("wait_seconds",
+			mcp.Description("Wait up to this long for the command to finish before returning, instead of returning at once"),
+			mcp.DefaultNumber(0),
+		),
+	)
+
+	cancelExecTool := mcp.NewTool("sandbox_exec_cancel",
+		mcp.WithDescription(
+			"Cancel a command started with sandbox_exec_start. \n"+
+				"Kills the command and every process it started, and returns the same JSON object as sandbox_exec_read with the output that wasn't read yet.",
+		),
+		mcp.WithString("exec_id",
+			mcp.Required(),
+			mcp.Description("ID of the command returned from sandbox_exec_start"),
+		),
+	)
+
+	// Install packages into the sandboxed environment
+	installPackagesTool := mcp.NewTool("sandbox_install_packages",
+		mcp.WithDescription(
+			"Install packages into an existing sandbox with pip, apt or npm. \n"+
+				"Returns a JSON object with the install log and whether it succeeded. Requires a sandbox with network access; apt also requires run_as_root.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("manager",
+			mcp.Required(),
+			mcp.Description("Package manager to install with"),
+			mcp.Enum("pip", "apt", "npm"),
+		),
+		mcp.WithArray("packages",
+			mcp.Required(),
+			mcp.Description("Names of the packages to install, optionally with version specifiers"),
+			mcp.Description("Example: [\"numpy\", \"pandas==2.2.2\"]"),
+			tools.StringItems(),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time the install may run before it is killed"),
+			mcp.DefaultNumber(300),
+		),
+	)
+
+	// Clone a git repository into the sandbox
+	gitCloneTool := mcp.NewTool("sandbox_git_clone",
+		mcp.WithDescription(
+			"Clone a git repository into a sandbox. \n"+
+				"Needs a sandbox with network access. git is installed with apt if the image lacks it and the sandbox runs as root. "+
+				"Returns a JSON object with the path, the commit checked out and the git log.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("repo_url",
+			mcp.Required(),
+			mcp.Description("Repository to clone, as an https://, git:// or ssh:// URL or user@host:path"),
+		),
+		mcp.WithString("ref",
+			mcp.Description("Branch, tag or commit to check out. Defaults to the repository's default branch"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("Create a shallow clone with this many commits of history"),
+		),
+		mcp.WithString("target_path",
+			mcp.Description("Directory to clone into, relative to the container working dir. Defaults to the repository name"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time the clone may take before it is killed"),
+			mcp.DefaultNumber(300),
+		),
+	)
+
+	// Write and run a snippet of code in one call
+	runCodeTool := mcp.NewTool("sandbox_run_code",
+		mcp.WithDescription(
+			"Run a snippet of code in a sandbox in a single call. \n"+
+				"Writes the code to a temporary file and runs it with the interpreter for its language, returning a JSON object with stdout, stderr and exit_code. "+
+				"Without a container_id, an ephemeral sandbox is created with the sandbox_initialize defaults and removed afterwards unless keep_alive is set.",
+		),
+		mcp.WithString("code",
+			mcp.Required(),
+			mcp.Description("Source code to run"),
+		),
+		mcp.WithString("language",
+			mcp.Required(),
+			mcp.Description("Language of the code"),
+			mcp.Enum(tools.SupportedLanguages()...),
+		),
+		mcp.WithString("container_id",
+			mcp.Description("ID or name of an existing sandbox to run the code in. When omitted, an ephemeral sandbox is created"),
+		),
+		mcp.WithBoolean("keep_alive",
+			mcp.Description("Keep the ephemeral sandbox running after the code finishes; its container_id is included in the result"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("image",
+			mcp.Description("Docker image for the ephemeral sandbox. Defaults to the server's default image for python and bash, and to an official image for other languages, e.g. node:20-slim for node"),
+		),
+		mcp.WithString("network",
+			mcp.Description("Network for the ephemeral sandbox: 'none', 'bridge' or the name of an existing Docker network"),
+			mcp.DefaultString("none"),
+		),
+		mcp.WithString("security_preset",
+			mcp.Description("Bundle of hardening settings for the ephemeral sandbox: 'none' (each option keeps its default), 'standard' or 'strict', "+
+				"which adds a read-only root filesystem and tighter memory, CPU and process limits and is recommended for untrusted code. "+
+				"Options given explicitly override the preset"),
+			mcp.Enum(tools.SecurityPresets()...),
+			mcp.DefaultString("none"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time the code may run before it is killed"),
+			mcp.DefaultNumber(30),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum bytes of stdout and of stderr returned; the rest is replaced by a truncation notice"),
+			mcp.DefaultNumber(1048576),
+		),
+	)
+
+	// Run several snippets of code in parallel, each in an ephemeral sandbox
+	runBatchTool := mcp.NewTool("sandbox_run_batch",
+		mcp.WithDescription(
+			"Run independent snippets of code in parallel, each in an ephemeral sandbox of its own. \n"+
+				"Returns a JSON object with one result per item, in input order, plus the number that succeeded and failed. "+
+				"A failing item doesn't stop the others. All sandboxes are removed afterwards.",
+		),
+		mcp.WithArray("items",
+			mcp.Required(),
+			mcp.Description("Snippets to run, as {language, code} objects. language is one of: "+strings.Join(tools.SupportedLanguages(), ", ")),
+			mcp.Description("Example: [{\"language\": \"python\", \"code\": \"print(1)\"}, {\"language\": \"node\", \"code\": \"console.log(2)\"}]"),
+			tools.ObjectItems(),
+		),
+		mcp.WithNumber("max_parallel",
+			mcp.Description("Maximum number of snippets running at the same time, at most 16"),
+			mcp.DefaultNumber(4),
+		),
+		mcp.WithString("image",
+			mcp.Description("Docker image for every sandbox of the batch. Defaults to the image sandbox_run_code picks for each item's language"),
+		),
+		mcp.WithString("network",
+			mcp.Description("Network for the sandboxes: 'none', 'bridge' or the name of an existing Docker network"),
+			mcp.DefaultString("none"),
+		),
+		mcp.WithString("security_preset",
+			mcp.Description("Bundle of hardening settings for every sandbox: 'none' (each option keeps its default), 'standard' or 'strict', "+
+				"which adds a read-only root filesystem and tighter memory, CPU and process limits and is recommended for untrusted code. "+
+				"Options given explicitly override the preset"),
+			mcp.Enum(tools.SecurityPresets()...),
+			mcp.DefaultString("none"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time each snippet may run before it is killed"),
+			mcp.DefaultNumber(30),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum bytes of stdout and of stderr returned for each snippet"),
+			mcp.DefaultNumber(1048576),
 		),
 	)
 
@@ -131,7 +688,7 @@ func main() {
 		),
 		mcp.WithString("container_id",
 			mcp.Required(),
-			mcp.Description("ID of the container returned from the initialize call"),
+			mcp.Description("ID or name of the container returned from the initialize call"),
 		),
 		mcp.WithString("local_src_file",
 			mcp.Required(),
@@ -150,7 +707,7 @@ func main() {
 		),
 		mcp.WithString("container_id",
 			mcp.Required(),
-			mcp.Description("ID of the container to copy from"),
+			mcp.Description("ID or name of the container to copy from"),
 		),
 		mcp.WithString("container_src_path",
 			mcp.Required(),
@@ -162,6 +719,254 @@ func main() {
 		),
 	)
 
+	// Copy files from one sandbox into another
+	copyBetweenTool := mcp.NewTool("copy_between_sandboxes",
+		mcp.WithDescription(
+			"Copy a file or directory from one sandbox into another, e.g. to hand the artifacts of one pipeline step to the next. "+
+				"The content is streamed between the sandboxes by the server and never passes through the client. \n"+
+				"Returns the number of bytes transferred.",
+		),
+		mcp.WithString("source_container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the sandbox to copy from"),
+		),
+		mcp.WithString("source_path",
+			mcp.Required(),
+			mcp.Description("File or directory to copy. Relative paths are resolved against the source sandbox's working directory"),
+		),
+		mcp.WithString("dest_container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the sandbox to copy into"),
+		),
+		mcp.WithString("dest_dir",
+			mcp.Description("Directory the source is placed in, keeping its name. Created if missing; defaults to the destination's working directory"),
+		),
+	)
+
+	// Download a directory of a sandbox as a tarball
+	downloadArchiveTool := mcp.NewTool("sandbox_download_archive",
+		mcp.WithDescription(
+			"Download a directory of a sandbox, by default its working directory, as a tar archive. \n"+
+				"Returns a JSON object with the base64-encoded archive, whose entries are relative to the directory, and the number of entries and bytes. "+
+				"If the selected files exceed max_bytes the archive is truncated and a warning is included.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Directory to download, relative to the container working dir. Defaults to the working dir itself"),
+		),
+		mcp.WithString("filter",
+			mcp.Description("Only include entries starting with this prefix, e.g. 'output/', or matching this glob, e.g. '*.csv'"),
+		),
+		mcp.WithBoolean("gzip",
+			mcp.Description("Compress the archive with gzip"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber("max_bytes",
+			mcp.Description("Maximum total size of the files in the archive"),
+			mcp.DefaultNumber(10485760),
+		),
+	)
+
+	// Read a file from the sandboxed filesystem
+	readFileTool := mcp.NewTool("read_file_sandbox",
+		mcp.WithDescription(
+			"Read a file from the sandboxed filesystem. \n"+
+				"Returns a JSON object with the file contents. Text files are returned as utf8, anything else is base64-encoded.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the file to read, relative to the container working dir"),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum bytes of the file returned; the rest is left out and counted in omitted_bytes"),
+			mcp.DefaultNumber(1048576),
+		),
+	)
+
+	// List the files of a directory in the sandbox
+	listFilesTool := mcp.NewTool("list_files_sandbox",
+		mcp.WithDescription(
+			"List the files and directories in a directory of the sandboxed filesystem. \n"+
+				"Returns a JSON object with the name, type (file, dir, symlink), size, mode and modification time of each entry. An empty directory gives an empty list.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Directory to list, relative to the container working dir. Defaults to the working dir itself"),
+		),
+		mcp.WithBoolean("recursive",
+			mcp.Description("Also list the contents of subdirectories, down to max_depth levels"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber("max_depth",
+			mcp.Description("How many levels below path a recursive listing goes, at most 20"),
+			mcp.DefaultNumber(5),
+		),
+	)
+
+	// Snapshot a sandbox as a new image
+	commitImageTool := mcp.NewTool("sandbox_commit_image",
+		mcp.WithDescription(
+			"Save the current filesystem of a sandbox as a new Docker image. \n"+
+				"Use it to reuse a prepared environment, e.g. with dependencies installed, by passing the image to sandbox_initialize. "+
+				"Returns a JSON object with the new image_id.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("image",
+			mcp.Required(),
+			mcp.Description("Repository and tag for the new image, e.g. my-env:v1. The tag defaults to latest"),
+		),
+		mcp.WithString("message",
+			mcp.Description("Commit message stored with the image"),
+		),
+		mcp.WithArray("changes",
+			mcp.Description("Dockerfile instructions to apply to the image config, such as CMD, ENV or WORKDIR"),
+			mcp.Description("Example: [\"ENV PYTHONPATH=/app\", \"CMD python main.py\"]"),
+			tools.StringItems(),
+		),
+	)
+
+	// Remove images this server created and no longer needs
+	pruneImagesTool := mcp.NewTool("sandbox_prune_images",
+		mcp.WithDescription(
+			"Remove unused images created by this server to free disk space. \n"+
+				"By default only dangling images left behind by sandbox_commit_image are removed. Images used by a container are never removed. "+
+				"Returns a JSON object with the removed image IDs and the space reclaimed.",
+		),
+		mcp.WithBoolean("include_committed",
+			mcp.Description("Also remove tagged images created by sandbox_commit_image that no container uses"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("include_untracked",
+			mcp.Description("Also remove dangling images that were not created by this server"),
+			mcp.DefaultBool(false),
+		),
+	)
+
+	// Create and remove named volumes that outlive the sandboxes using them
+	createVolumeTool := mcp.NewTool("sandbox_volume_create",
+		mcp.WithDescription(
+			"Create a named Docker volume for keeping data across sandboxes. \n"+
+				"Attach it with the volumes argument of sandbox_initialize. Creating a volume that already exists returns it unchanged. "+
+				"Returns a JSON object with the volume's name, driver and creation time.",
+		),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the volume, e.g. pip-cache"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("End user or session the volume belongs to, stored as a label"),
+		),
+	)
+	removeVolumeTool := mcp.NewTool("sandbox_volume_remove",
+		mcp.WithDescription(
+			"Remove a named volume created with sandbox_volume_create, deleting its data. \n"+
+				"Fails while any container, running or stopped, still uses the volume.",
+		),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the volume to remove"),
+		),
+	)
+
+	// Report what the Docker host supports
+	capabilitiesTool := mcp.NewTool("sandbox_capabilities",
+		mcp.WithDescription(
+			"Report the features of the Docker host that runs the sandboxes. \n"+
+				"Returns a JSON object with the Docker version, OS and architecture, CPUs and memory, the available runtimes, "+
+				"whether gVisor, GPUs and storage_mb quotas are supported, and the image policy. "+
+				"Check it before requesting runtime, gpus or storage_mb in sandbox_initialize.",
+		),
+	)
+
+	// Describe the configuration and state of a sandbox
+	describeTool := mcp.NewTool("sandbox_describe",
+		mcp.WithDescription(
+			"Describe a container's configuration and state. \n"+
+				"Returns a JSON object with its image, state, creation time, resource limits, mounts, network and labels, "+
+				"and whether it is a sandbox managed by this server.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+
+	// Find the language runtimes installed in a sandbox
+	detectRuntimesTool := mcp.NewTool("sandbox_detect_runtimes",
+		mcp.WithDescription(
+			"Detect which language runtimes a sandbox has, e.g. python3, node, go, ruby, java or rustc, before running code in an unfamiliar image. \n"+
+				"Returns a JSON object with the names of the available runtimes and, for each runtime probed, whether it is available, its version and the first line of its version output.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+
+	// Attach metadata to a sandbox after it was created
+	setMetadataTool := mcp.NewTool("sandbox_set_metadata",
+		mcp.WithDescription(
+			"Set or remove key/value metadata of a sandbox, such as a task ID, priority or owner. \n"+
+				"Changes are kept in the server's memory and are lost when it restarts; only metadata given to sandbox_initialize is durable. "+
+				"Returns a JSON object with all of the sandbox's metadata and the keys that are durable.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithObject("metadata",
+			mcp.Required(),
+			mcp.Description("Keys to change, mapped to their new string value, or to null to remove a key set by an earlier sandbox_set_metadata call"),
+		),
+	)
+
+	// Read the metadata of a sandbox
+	getMetadataTool := mcp.NewTool("sandbox_get_metadata",
+		mcp.WithDescription(
+			"Get the key/value metadata of a sandbox. \n"+
+				"Returns a JSON object with the metadata, and the keys that are durable because they were given to sandbox_initialize.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+
+	// Return a sandbox to a clean state without recreating it
+	resetTool := mcp.NewTool("sandbox_reset",
+		mcp.WithDescription(
+			"Reset a sandbox for a fresh task without destroying it: close its shell sessions, kill every process left running by earlier commands, "+
+				"and delete the contents of its working directory and /tmp. Installed packages, files elsewhere, the environment and mounted host directories and volumes are kept. \n"+
+				"Returns a JSON object with the number of processes killed and sessions closed, the directories emptied and the mounts that were kept.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+
+	// Remove the idle sandboxes of the warm pool
+	drainPoolTool := mcp.NewTool("sandbox_drain_pool",
+		mcp.WithDescription(
+			"Remove every idle sandbox kept ready by the warm pool and stop refilling it until the server restarts. "+
+				"Sandboxes already handed out are not affected. \n"+
+				"Returns a JSON object with the number and IDs of the removed sandboxes and any that could not be removed.",
+		),
+	)
+
 	// Stop and remove a container
 	stopContainerTool := mcp.NewTool("sandbox_stop",
 		mcp.WithDescription(
@@ -170,7 +975,265 @@ func main() {
 		),
 		mcp.WithString("container_id",
 			mcp.Required(),
-			mcp.Description("ID of the container to stop and remove"),
+			mcp.Description("ID or name of the container to stop and remove"),
+		),
+		mcp.WithNumber("stop_timeout_seconds",
+			mcp.Description("How long to wait for processes to exit before they are killed. 0 kills them immediately"),
+			mcp.DefaultNumber(10),
+		),
+		mcp.WithBoolean("remove_volumes",
+			mcp.Description("Also remove the container's anonymous volumes. Named volumes are always kept"),
+			mcp.DefaultBool(true),
+		),
+	)
+
+	// Stop and remove every sandbox created by this server
+	stopAllTool := mcp.NewTool("sandbox_stop_all",
+		mcp.WithDescription(
+			"Stop and remove every sandbox container created by this server. \n"+
+				"Continues past containers that fail to stop and returns a JSON summary with the number removed and any per-container failures.",
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Only remove the sandboxes created with this session_id"),
+		),
+	)
+
+	// Persistent shell sessions, which keep the working directory and variables between inputs
+	startSessionTool := mcp.NewTool("sandbox_session_start",
+		mcp.WithDescription(
+			"Open a persistent shell session in a sandbox. \n"+
+				"Unlike sandbox_exec, state such as the current directory and exported variables carries over between inputs sent with sandbox_session_send. "+
+				"Returns a JSON object with the session_id. Close the session with sandbox_session_close when done.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("shell",
+			mcp.Description("Shell to start"),
+			mcp.DefaultString("/bin/sh"),
+		),
+	)
+
+	sendToSessionTool := mcp.NewTool("sandbox_session_send",
+		mcp.WithDescription(
+			"Send input to a shell session and wait for it to finish. \n"+
+				"Returns a JSON object with the stdout, stderr and exit_code of the input. If it is still running when the timeout elapses, "+
+				"running is true and the remaining output can be fetched with sandbox_session_read.",
+		),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the session returned from sandbox_session_start"),
+		),
+		mcp.WithString("input",
+			mcp.Required(),
+			mcp.Description("Shell commands to run, e.g. 'cd src && export DEBUG=1'"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time to wait for the input to finish"),
+			mcp.DefaultNumber(30),
+		),
+	)
+
+	readSessionTool := mcp.NewTool("sandbox_session_read",
+		mcp.WithDescription(
+			"Read the output a shell session produced since it was last read, without waiting. \n"+
+				"Reports whether input is still running and whether the session has ended, e.g. because the container stopped.",
+		),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the session returned from sandbox_session_start"),
+		),
+	)
+
+	closeSessionTool := mcp.NewTool("sandbox_session_close",
+		mcp.WithDescription(
+			"Close a shell session. \n"+
+				"Ends the shell and every process started from it, and returns any output that wasn't read yet.",
+		),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the session returned from sandbox_session_start"),
+		),
+	)
+
+	// Restart a sandbox, keeping its filesystem
+	restartTool := mcp.NewTool("sandbox_restart",
+		mcp.WithDescription(
+			"Restart a sandbox container to reset its process state. \n"+
+				"Every running process is stopped, while files written to the container are kept. Returns a JSON object with the container's new status.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("How long to wait for processes to exit before they are killed"),
+			mcp.DefaultNumber(10),
+		),
+	)
+
+	// Send a signal to a sandbox's main process
+	killTool := mcp.NewTool("sandbox_kill",
+		mcp.WithDescription(
+			"Send a signal to the main process of a sandbox container without removing the container. \n"+
+				"Stopping the main process stops the sandbox, which can then be inspected, restarted or removed with sandbox_stop. Returns a JSON object with the signal sent and the container's status.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithString("signal",
+			mcp.Description("Signal to send: SIGHUP, SIGINT, SIGQUIT, SIGABRT, SIGKILL, SIGUSR1, SIGUSR2, SIGALRM, SIGTERM, SIGCONT, SIGSTOP, SIGTSTP or SIGWINCH"),
+			mcp.DefaultString("SIGKILL"),
+		),
+	)
+
+	// Freeze and resume a sandbox's processes
+	pauseTool := mcp.NewTool("sandbox_pause",
+		mcp.WithDescription(
+			"Pause a running sandbox container, freezing all of its processes without losing their memory or files. \n"+
+				"Fails if the sandbox is already paused. Returns a JSON object with the container's new status.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+	unpauseTool := mcp.NewTool("sandbox_unpause",
+		mcp.WithDescription(
+			"Resume a sandbox container paused with sandbox_pause. \n"+
+				"Fails if the sandbox is not paused. Returns a JSON object with the container's new status.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+
+	// Change the resource limits of a sandbox
+	updateResourcesTool := mcp.NewTool("sandbox_update_resources",
+		mcp.WithDescription(
+			"Change the memory, CPU and process limits of a sandbox without recreating it. \n"+
+				"Only the limits that are given change. Returns a JSON object with the limits in effect after the update.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithNumber("memory_mb",
+			mcp.Description("New memory limit in megabytes. Must not be below what a running sandbox currently uses"),
+		),
+		mcp.WithNumber("cpu_limit",
+			mcp.Description("New number of CPUs the container may use (fractions such as 0.5 are allowed)"),
+		),
+		mcp.WithNumber("pids_limit",
+			mcp.Description("New maximum number of processes and threads in the container"),
+		),
+	)
+
+	// Wait for a sandbox to stop
+	waitTool := mcp.NewTool("sandbox_wait",
+		mcp.WithDescription(
+			"Wait until a sandbox container stops running. \n"+
+				"Returns a JSON object with the exit code once the container has stopped, or with timed_out set and status running if it is still running when the timeout elapses.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time to wait for the container to stop"),
+			mcp.DefaultNumber(60),
+		),
+	)
+
+	// List the sandboxes created by this server
+	listSandboxesTool := mcp.NewTool("sandbox_list",
+		mcp.WithDescription(
+			"List the sandbox containers created by this server. \n"+
+				"Returns the ID, image, creation time and status of each sandbox, including stopped ones. Containers not created by this server are excluded.",
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Only list the sandboxes created with this session_id"),
+		),
+	)
+
+	// Report the resource usage of a sandbox
+	statsTool := mcp.NewTool("sandbox_stats",
+		mcp.WithDescription(
+			"Get the current resource usage of a running sandbox. \n"+
+				"Returns a JSON object with the CPU percentage, memory usage and limit, network I/O and process count.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+	)
+
+	// Stream the resource usage of a sandbox
+	watchStatsTool := mcp.NewTool("sandbox_watch_stats",
+		mcp.WithDescription(
+			"Watch the resource usage of a running sandbox. Every interval a notifications/progress message carries the CPU percentage, memory usage and process count, "+
+				"until the request is cancelled, the sandbox stops or duration_seconds passes. \n"+
+				"Send a progressToken with the request to receive the samples. Returns a JSON object with the reason the watch ended, the number of samples, the peaks and the last sample.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithNumber("interval_seconds",
+			mcp.Description("Time between samples, at least 1"),
+			mcp.DefaultNumber(2),
+		),
+		mcp.WithNumber("duration_seconds",
+			mcp.Description("Stop watching after this long. Without it the watch lasts until it is cancelled or the sandbox stops"),
+		),
+	)
+
+	// Fetch the logs of a sandbox
+	logsTool := mcp.NewTool("sandbox_logs",
+		mcp.WithDescription(
+			"Get the stdout and stderr logs of a sandbox container. \n"+
+				"Useful for debugging background processes started in the sandbox. Long logs are truncated to the most recent output.",
+		),
+		mcp.WithString("container_id",
+			mcp.Required(),
+			mcp.Description("ID or name of the container returned from the initialize call"),
+		),
+		mcp.WithNumber("tail",
+			mcp.Description("Only return this many lines from the end of the logs"),
+		),
+		mcp.WithString("since",
+			mcp.Description("Only return logs since this time, as an RFC 3339 or Unix timestamp or a relative duration such as 10m"),
+		),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Maximum size of the returned logs; earlier output beyond it is dropped with a notice"),
+			mcp.DefaultNumber(65536),
+		),
+	)
+
+	// Consolidate the outcome of a set of sandboxes into one report
+	reportTool := mcp.NewTool("sandbox_report",
+		mcp.WithDescription(
+			"Generate a report on a set of sandboxes, e.g. after a batch of runs. \n"+
+				"Returns a JSON object with a summary and, per sandbox, its state and exit code, resource usage while it runs and the tail of its logs. "+
+				"Sandboxes that were already removed are reported with status removed.",
+		),
+		mcp.WithArray("container_ids",
+			mcp.Description("IDs or names of the sandboxes to report on"),
+			tools.StringItems(),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Also report on every sandbox created with this session_id"),
+		),
+		mcp.WithNumber("log_tail_lines",
+			mcp.Description("Number of lines from the end of each sandbox's logs to include"),
+			mcp.DefaultNumber(50),
+		),
+		mcp.WithBoolean("markdown",
+			mcp.Description("Also return the report rendered as Markdown, as a second text content after the JSON"),
+			mcp.DefaultBool(false),
 		),
 	)
 
@@ -185,23 +1248,107 @@ func main() {
 	)
 
 	s.AddResourceTemplate(containerLogsTemplate, resources.GetContainerLogs)
-	s.AddTool(initializeTool, tools.InitializeEnvironment)
-	s.AddTool(copyProjectTool, tools.CopyProject)
-	s.AddTool(writeFileTool, tools.WriteFile)
-	s.AddTool(execTool, tools.Exec)
-	s.AddTool(copyFileTool, tools.CopyFile)
-	s.AddTool(copyFileFromContainerTool, tools.CopyFileFromContainer)
-	s.AddTool(stopContainerTool, tools.StopContainer)
+
+	// Every tool checks its arguments against its input schema before its handler runs
+	addTool := func(tool mcp.Tool, handler server.ToolHandlerFunc) {
+		s.AddTool(tool, tools.ValidateArguments(tool, handler))
+	}
+	addTool(initializeTool, tools.InitializeEnvironment)
+	addTool(copyProjectTool, tools.CopyProject)
+	addTool(copyDirectoryTool, tools.CopyDirectory)
+	addTool(writeFileTool, tools.WriteFile)
+	addTool(execTool, tools.Exec)
+	addTool(runCommandTool, tools.RunCommand)
+	addTool(execStreamTool, tools.ExecStream)
+	addTool(startExecTool, tools.StartExec)
+	addTool(readExecTool, tools.ReadExec)
+	addTool(cancelExecTool, tools.CancelExec)
+	addTool(installPackagesTool, tools.InstallPackages)
+	addTool(gitCloneTool, tools.GitClone)
+	addTool(runCodeTool, tools.RunCode)
+	addTool(runBatchTool, tools.RunBatch)
+	addTool(copyFileTool, tools.CopyFile)
+	addTool(copyFileFromContainerTool, tools.CopyFileFromContainer)
+	addTool(readFileTool, tools.ReadFile)
+	addTool(listFilesTool, tools.ListFiles)
+	addTool(copyBetweenTool, tools.CopyBetweenContainers)
+	addTool(downloadArchiveTool, tools.DownloadArchive)
+	addTool(capabilitiesTool, tools.GetServerCapabilities)
+	addTool(describeTool, tools.DescribeContainer)
+	addTool(detectRuntimesTool, tools.DetectRuntimes)
+	addTool(setMetadataTool, tools.SetMetadata)
+	addTool(getMetadataTool, tools.GetMetadata)
+	addTool(commitImageTool, tools.CommitToImage)
+	addTool(pruneImagesTool, tools.PruneImages)
+	addTool(createVolumeTool, tools.CreateVolume)
+	addTool(removeVolumeTool, tools.RemoveVolume)
+	addTool(stopContainerTool, tools.StopContainer)
+	addTool(stopAllTool, tools.StopAllSandboxes)
+	addTool(drainPoolTool, tools.DrainPool)
+	addTool(startSessionTool, tools.StartSession)
+	addTool(sendToSessionTool, tools.SendToSession)
+	addTool(readSessionTool, tools.ReadSession)
+	addTool(closeSessionTool, tools.CloseSession)
+	addTool(restartTool, tools.RestartContainer)
+	addTool(resetTool, tools.ResetSandbox)
+	addTool(killTool, tools.KillContainer)
+	addTool(pauseTool, tools.PauseContainer)
+	addTool(unpauseTool, tools.UnpauseContainer)
+	addTool(updateResourcesTool, tools.UpdateResources)
+	addTool(waitTool, tools.WaitForContainer)
+	addTool(listSandboxesTool, tools.ListSandboxes)
+	addTool(statsTool, tools.GetContainerStats)
+	addTool(watchStatsTool, tools.WatchStats)
+	addTool(logsTool, tools.GetContainerLogs)
+	addTool(reportTool, tools.GenerateReport)
+
+	// Connect to the configured daemon, falling back to the Docker environment variables
+	if err := tools.ConfigureDocker(tools.DockerConfig{
+		Host:       *dockerHostFlag,
+		TLSCACert:  *dockerTLSCACertFlag,
+		TLSCert:    *dockerTLSCertFlag,
+		TLSKey:     *dockerTLSKeyFlag,
+		APIVersion: *dockerAPIVersionFlag,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Shut down on SIGINT or SIGTERM. A second signal stops the server without waiting for the cleanup.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Remove sandboxes left behind by clients that never called sandbox_stop
+	tools.StartReaper(ctx)
+
+	// Serve Prometheus metrics when CODE_SANDBOX_METRICS_ADDR is set
+	tools.StartMetricsServer(ctx)
+
+	// Keep idle sandboxes ready when CODE_SANDBOX_WARM_POOL_SIZE is set
+	tools.StartWarmPool(ctx)
+
+	// Release the Docker client shared by all tools when the server shuts down
+	defer tools.CloseDockerClient()
+
 	switch *transport {
 	case "stdio":
-		if err := server.ServeStdio(s); err != nil {
+		stdioServer := server.NewStdioServer(s)
+		stdioServer.SetErrorLogger(slog.NewLogLogger(tools.Logger().Handler(), slog.LevelError))
+		if err := stdioServer.Listen(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
 			s.SendNotificationToClient(context.Background(), "notifications/error", map[string]interface{}{
 				"message": fmt.Sprintf("Failed to start stdio server: %v", err),
 			})
 		}
 	case "sse":
 		sseServer := server.NewSSEServer(s)
-		if err := sseServer.Start(fmt.Sprintf(":%s", *port)); err != nil {
+		go func() {
+			<-ctx.Done()
+			stop()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = sseServer.Shutdown(shutdownCtx)
+		}()
+		if err := sseServer.Start(fmt.Sprintf(":%s", *port)); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.SendNotificationToClient(context.Background(), "notifications/error", map[string]interface{}{
 				"message": fmt.Sprintf("Failed to start SSE server: %v", err),
 			})
@@ -210,12 +1357,17 @@ func main() {
 		s.SendNotificationToClient(context.Background(), "notifications/error", map[string]interface{}{
 			"message": fmt.Sprintf("Invalid transport: %s", *transport),
 		})
+		return
 	}
+
+	// Remove the sandboxes of this server before exiting, unless CODE_SANDBOX_CLEANUP_ON_EXIT is false
+	stop()
+	tools.CleanupOnShutdown()
 }
 
 func handleNotification(
 	ctx context.Context,
 	notification mcp.JSONRPCNotification,
 ) {
-	log.Printf("Received notification from client: %s", notification.Method)
+	slog.Debug("received notification from client", "method", notification.Method)
 }
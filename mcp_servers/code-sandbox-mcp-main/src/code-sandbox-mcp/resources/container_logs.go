@@ -6,25 +6,21 @@ import (
 	"strings"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/pkg/stdcopy"
 
-	"github.com/docker/docker/client"
+	"github.com/Automata-Labs-team/code-sandbox-mcp/tools"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func GetContainerLogs(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Docker client: %w", err)
-	}
-	defer cli.Close()
-
 	containerIDPath, found := strings.CutPrefix(request.Params.URI, "containers://") // Extract ID from the full URI
 	if !found {
 		return nil, fmt.Errorf("invalid URI: %s", request.Params.URI)
 	}
-	containerID := strings.TrimSuffix(containerIDPath, "/logs")
+	// The URI may name a sandbox instead of giving its ID
+	containerID, err := tools.ResolveContainer(ctx, strings.TrimSuffix(containerIDPath, "/logs"))
+	if err != nil {
+		return nil, err
+	}
 
 	// Set default ContainerLogsOptions
 	logOpts := container.LogsOptions{
@@ -32,24 +28,14 @@ func GetContainerLogs(ctx context.Context, request mcp.ReadResourceRequest) ([]m
 		ShowStderr: true,
 	}
 
-	// Actually fetch the logs
-	reader, err := cli.ContainerLogs(ctx, containerID, logOpts)
+	combined, err := tools.ReadContainerLogs(ctx, containerID, logOpts, tools.MaxOutputBytes())
 	if err != nil {
-		return nil, fmt.Errorf("error fetching container logs: %w", err)
-	}
-	defer reader.Close()
-
-	var b strings.Builder
-	if _, err := stdcopy.StdCopy(&b, &b, reader); err != nil {
-		return nil, fmt.Errorf("error copying container logs: %w", err)
+		return nil, err
 	}
 
-	// Combine them. You could also return them separately if you prefer.
-	combined := b.String()
-
 	return []mcp.ResourceContents{
 		mcp.TextResourceContents{
-			URI:      fmt.Sprintf("containers://%s/logs", containerID),
+			URI:      request.Params.URI,
 			MIMEType: "text/plain",
 			Text:     combined,
 		},
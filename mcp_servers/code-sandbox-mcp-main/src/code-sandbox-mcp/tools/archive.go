@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// archiveTimeout bounds how long streaming an archive through tar inside a container may take
+const archiveTimeout = 5 * time.Minute
+
+// Docker's archive API works on the container's filesystem layer as seen by the daemon. That layer
+// can't be written when the root filesystem is read-only, and it doesn't include tmpfs mounts such
+// as /tmp or the writable /app of a readonly_rootfs sandbox. For those paths the archive is streamed
+// through tar running inside the container instead, which sees the same filesystem the code does.
+
+// archiveNeedsExec reports whether p can only be reached by running tar inside the container
+func archiveNeedsExec(info container.InspectResponse, p string) bool {
+	if info.HostConfig == nil {
+		return false
+	}
+	if info.HostConfig.ReadonlyRootfs {
+		return true
+	}
+	for target := range info.HostConfig.Tmpfs {
+		if p == target || strings.HasPrefix(p, strings.TrimSuffix(target, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// putArchive extracts a tar archive into dir inside the container
+func putArchive(ctx context.Context, cli DockerAPI, containerID string, dir string, content io.Reader, opts container.CopyToContainerOptions) error {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return inspectError(containerID, err)
+	}
+
+	if !archiveNeedsExec(info, dir) {
+		if err := cli.CopyToContainer(ctx, containerID, dir, content, opts); err != nil {
+			return fmt.Errorf("failed to copy to container: %w", err)
+		}
+		return nil
+	}
+
+	// tar runs as the container user, so extracted files are owned by it without any chown
+	result, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		Cmd: []string{"tar", "-xf", "-", "--no-same-owner", "-C", dir},
+	}, execIO{Stdin: content}, archiveTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to copy to container: tar exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// getArchive returns a tar stream holding srcPath from the container, together with its stat info
+func getArchive(ctx context.Context, cli DockerAPI, containerID string, srcPath string) (io.ReadCloser, container.PathStat, error) {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, container.PathStat{}, inspectError(containerID, err)
+	}
+
+	if !archiveNeedsExec(info, srcPath) {
+		return cli.CopyFromContainer(ctx, containerID, srcPath)
+	}
+
+	result, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		Cmd: []string{"tar", "-cf", "-", "-C", filepath.Dir(srcPath), filepath.Base(srcPath)},
+	}, execIO{}, archiveTimeout)
+	if err != nil {
+		return nil, container.PathStat{}, err
+	}
+	if result.ExitCode != 0 {
+		// Report missing paths the same way the Docker API does so callers can rely on errdefs
+		if strings.Contains(result.Stderr, "No such file") {
+			return nil, container.PathStat{}, errdefs.NotFound(fmt.Errorf("no such file or directory: %s", srcPath))
+		}
+		return nil, container.PathStat{}, fmt.Errorf("tar exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	// The first entry of the archive is srcPath itself
+	data := []byte(result.Stdout)
+	header, err := tar.NewReader(bytes.NewReader(data)).Next()
+	if err != nil {
+		return nil, container.PathStat{}, fmt.Errorf("failed to read tar header: %w", err)
+	}
+	stat := container.PathStat{
+		Name:  header.Name,
+		Size:  header.Size,
+		Mode:  header.FileInfo().Mode(),
+		Mtime: header.ModTime,
+	}
+	return io.NopCloser(bytes.NewReader(data)), stat, nil
+}
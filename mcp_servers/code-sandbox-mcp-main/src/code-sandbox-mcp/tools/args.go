@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"fmt"
+)
+
+// positiveNumberArg reads an optional numeric argument, falling back to def when it is unset.
+// A value that is present but not a positive number is rejected.
+func positiveNumberArg(args map[string]interface{}, key string, def float64) (float64, error) {
+	raw, ok := args[key]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	value, ok := raw.(float64)
+	if !ok || value <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number", key)
+	}
+	return value, nil
+}
+
+// nonNegativeNumberArg is like positiveNumberArg, but also accepts zero
+func nonNegativeNumberArg(args map[string]interface{}, key string, def float64) (float64, error) {
+	raw, ok := args[key]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	value, ok := raw.(float64)
+	if !ok || value < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number", key)
+	}
+	return value, nil
+}
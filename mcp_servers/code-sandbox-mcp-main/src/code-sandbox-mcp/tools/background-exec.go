@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultBackgroundExecTimeout bounds a command started with sandbox_exec_start when no timeout is requested
+	defaultBackgroundExecTimeout = time.Hour
+	// maxBackgroundExecs caps the commands running in the background at once, across all sandboxes
+	maxBackgroundExecs = 32
+	// backgroundExecRetention is how long a finished command is kept for its output to be read
+	backgroundExecRetention = 15 * time.Minute
+)
+
+// Background exec states. A command is running until it exits, is cancelled, times out or can't
+// be waited for, e.g. because its container was removed.
+const (
+	execStatusRunning   = "running"
+	execStatusExited    = "exited"
+	execStatusCancelled = "cancelled"
+	execStatusTimedOut  = "timed_out"
+	execStatusFailed    = "failed"
+)
+
+// backgroundExec is a command started with sandbox_exec_start that outlives the request starting it
+type backgroundExec struct {
+	id          string
+	containerID string
+	startedAt   time.Time
+	cancel      context.CancelFunc
+
+	mu         sync.Mutex
+	stdout     bytes.Buffer
+	stderr     bytes.Buffer
+	dropped    int
+	status     string
+	exitCode   *int
+	err        string
+	oomNote    string
+	finishedAt time.Time
+	// done is closed once the command has finished, whatever the reason
+	done chan struct{}
+}
+
+// backgroundExecs is the registry of commands started with sandbox_exec_start, keyed by exec ID.
+// A finished command stays until its remaining output has been read, or for backgroundExecRetention.
+var backgroundExecs = struct {
+	sync.Mutex
+	byID map[string]*backgroundExec
+}{byID: make(map[string]*backgroundExec)}
+
+// backgroundExecResult is the structured result of the background exec tools
+type backgroundExecResult struct {
+	ExecID      string `json:"exec_id"`
+	ContainerID string `json:"container_id"`
+	Status      string `json:"status"`
+	// Stdout and Stderr hold the output produced since the previous read
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// OOMKilled is set, with a Note saying so, when the sandbox ran out of memory
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+	Note      string `json:"note,omitempty"`
+	// DroppedBytes counts output discarded because it exceeded the buffer before it was read
+	DroppedBytes    int     `json:"dropped_bytes,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// StartExec starts a command in a sandbox without waiting for it and returns a handle to it
+func StartExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	cmd, err := parseCommandArgument(request.Params.Arguments["command"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultBackgroundExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	overrides, err := parseExecOverrides(ctx, containerID, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	e, err := startBackgroundExec(ctx, containerID, cmd, overrides, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(e.read())
+}
+
+// ReadExec returns the status of a command started with sandbox_exec_start and the output it
+// produced since it was last read, optionally waiting for it to finish first
+func ReadExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	e, err := backgroundExecArg(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	wait, err := nonNegativeNumberArg(request.Params.Arguments, "wait_seconds", 0)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	if wait > 0 {
+		timer := time.NewTimer(time.Duration(wait * float64(time.Second)))
+		defer timer.Stop()
+		select {
+		case <-e.done:
+		case <-timer.C:
+		case <-ctx.Done():
+			return newToolResultError(fmt.Sprintf("Error: %v", ctx.Err())), nil
+		}
+	}
+
+	touchContainer(e.containerID)
+	return newToolResultJSON(e.read())
+}
+
+// CancelExec kills a command started with sandbox_exec_start, with every process it started
+func CancelExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	e, err := backgroundExecArg(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	e.cancel()
+	// Killing the processes takes a moment; the output read until then is still returned
+	select {
+	case <-e.done:
+	case <-time.After(15 * time.Second):
+		return newToolResultError(fmt.Sprintf("Error: exec %s did not stop in time, check it again with sandbox_exec_read", e.id)), nil
+	case <-ctx.Done():
+		return newToolResultError(fmt.Sprintf("Error: %v", ctx.Err())), nil
+	}
+
+	touchContainer(e.containerID)
+	return newToolResultJSON(e.read())
+}
+
+// backgroundExecArg looks up the command named by the required exec_id argument
+func backgroundExecArg(args map[string]interface{}) (*backgroundExec, error) {
+	id, ok := args["exec_id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("exec_id is required")
+	}
+
+	backgroundExecs.Lock()
+	defer backgroundExecs.Unlock()
+	e, ok := backgroundExecs.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("no exec %s, its final output may have been read already or it finished more than %s ago", id, backgroundExecRetention)
+	}
+	return e, nil
+}
+
+// startBackgroundExec registers a command and runs it in a goroutine that isn't tied to the
+// request, so it keeps running after the request returns. The command is tracked under the
+// container's full ID, so a container named in the request is still found by the reaper.
+func startBackgroundExec(ctx context.Context, containerID string, cmd []string, overrides execOverrides, timeout time.Duration) (*backgroundExec, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if info.State == nil || !info.State.Running {
+		return nil, fmt.Errorf("container %s is not running", containerID)
+	}
+	containerID = info.ID
+
+	id, err := newExecMarker()
+	if err != nil {
+		return nil, err
+	}
+	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	e := &backgroundExec{
+		id:          id,
+		containerID: containerID,
+		startedAt:   time.Now(),
+		cancel:      cancel,
+		status:      execStatusRunning,
+		done:        make(chan struct{}),
+	}
+
+	backgroundExecs.Lock()
+	running := 0
+	for _, other := range backgroundExecs.byID {
+		if !other.isFinished() {
+			running++
+		}
+	}
+	if running >= maxBackgroundExecs {
+		backgroundExecs.Unlock()
+		cancel()
+		return nil, fmt.Errorf("%d commands are already running in the background, wait for one to finish or cancel it", running)
+	}
+	backgroundExecs.byID[id] = e
+	backgroundExecs.Unlock()
+
+	go func() {
+		defer cancel()
+		// runAttachedExec's own copy of the output is capped like any other command's and dropped
+		// when it returns; the output is read from the per-line copy kept here
+		result, err := runCommandInContainer(execCtx, containerID, cmd, overrides, execIO{
+			OnLine:         e.appendLine,
+			MaxOutputBytes: defaultMaxOutputBytes,
+		}, timeout)
+		e.finish(result, err)
+		logger.Info("background exec finished", "container_id", containerID, "exec_id", id, "status", e.currentStatus(), "duration", time.Since(e.startedAt))
+
+		time.AfterFunc(backgroundExecRetention, func() { forgetBackgroundExec(id) })
+	}()
+	return e, nil
+}
+
+// appendLine adds a line of output to the unread output of its stream
+func (e *backgroundExec) appendLine(stream string, line string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	buf := &e.stdout
+	if stream == "stderr" {
+		buf = &e.stderr
+	}
+	buf.WriteString(line)
+	if over := buf.Len() - maxSessionOutput; over > 0 {
+		buf.Next(over)
+		e.dropped += over
+	}
+}
+
+// finish records how the command ended and wakes up the callers waiting for it
+func (e *backgroundExec) finish(result *commandResult, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.finishedAt = time.Now()
+	switch {
+	case errors.Is(err, context.Canceled):
+		e.status = execStatusCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		e.status = execStatusTimedOut
+	case err != nil:
+		e.status, e.err = execStatusFailed, err.Error()
+	default:
+		e.status = execStatusExited
+		e.exitCode = &result.ExitCode
+		if result.OOMKilled {
+			e.oomNote = result.Note
+		}
+	}
+	close(e.done)
+}
+
+// read moves the unread output into a result. Once a finished command's output has been read in
+// full it is removed from the registry, so the final status is reported exactly once.
+func (e *backgroundExec) read() *backgroundExecResult {
+	e.mu.Lock()
+	result := &backgroundExecResult{
+		ExecID:       e.id,
+		ContainerID:  e.containerID,
+		Status:       e.status,
+		Stdout:       e.stdout.String(),
+		Stderr:       e.stderr.String(),
+		ExitCode:     e.exitCode,
+		Error:        e.err,
+		OOMKilled:    e.oomNote != "",
+		Note:         e.oomNote,
+		DroppedBytes: e.dropped,
+	}
+	e.stdout.Reset()
+	e.stderr.Reset()
+	e.dropped = 0
+	finished := e.status != execStatusRunning
+	end := time.Now()
+	if finished {
+		end = e.finishedAt
+	}
+	e.mu.Unlock()
+
+	result.DurationSeconds = end.Sub(e.startedAt).Seconds()
+	if finished {
+		forgetBackgroundExec(e.id)
+	}
+	return result
+}
+
+// currentStatus returns the command's status
+func (e *backgroundExec) currentStatus() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// isFinished reports whether the command has finished
+func (e *backgroundExec) isFinished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// forgetBackgroundExec drops a command from the registry
+func forgetBackgroundExec(id string) {
+	backgroundExecs.Lock()
+	defer backgroundExecs.Unlock()
+	delete(backgroundExecs.byID, id)
+}
+
+// forgetBackgroundExecs cancels and drops the background commands of a removed container
+func forgetBackgroundExecs(containerID string) {
+	backgroundExecs.Lock()
+	defer backgroundExecs.Unlock()
+	for id, e := range backgroundExecs.byID {
+		if strings.HasPrefix(e.containerID, containerID) || strings.HasPrefix(containerID, e.containerID) {
+			e.cancel()
+			delete(backgroundExecs.byID, id)
+		}
+	}
+}
+
+// hasRunningBackgroundExec reports whether a container has a background command still running,
+// which keeps the reaper from removing it as idle
+func hasRunningBackgroundExec(containerID string) bool {
+	backgroundExecs.Lock()
+	defer backgroundExecs.Unlock()
+	for _, e := range backgroundExecs.byID {
+		if strings.HasPrefix(containerID, e.containerID) && !e.isFinished() {
+			return true
+		}
+	}
+	return false
+}
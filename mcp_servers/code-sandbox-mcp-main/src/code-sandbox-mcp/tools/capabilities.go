@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// capabilitiesTTL is how long a capability report is reused before the daemon is asked again
+const capabilitiesTTL = 30 * time.Second
+
+// serverCapabilities reports what the Docker host supports, so clients can pick sandbox options
+// that will work instead of finding out at create time
+type serverCapabilities struct {
+	// Engine is docker, or podman for Podman's Docker-compatible service
+	Engine          string   `json:"engine"`
+	DockerVersion   string   `json:"docker_version"`
+	APIVersion      string   `json:"api_version"`
+	OS              string   `json:"os"`
+	Arch            string   `json:"arch"`
+	OperatingSystem string   `json:"operating_system"`
+	KernelVersion   string   `json:"kernel_version"`
+	CPUs            int      `json:"cpus"`
+	MemoryBytes     int64    `json:"memory_bytes"`
+	CgroupVersion   string   `json:"cgroup_version,omitempty"`
+	Runtimes        []string `json:"runtimes"`
+	DefaultRuntime  string   `json:"default_runtime"`
+	// GVisor is true when the runsc runtime can be requested with runtime
+	GVisor        bool     `json:"gvisor"`
+	GPU           bool     `json:"gpu"`
+	StorageDriver string   `json:"storage_driver"`
+	StorageQuota  bool     `json:"storage_quota"`
+	SecurityOpts  []string `json:"security_options"`
+	DefaultImage  string   `json:"default_image"`
+	// ImagesRestricted is set when the operator limits images with CODE_SANDBOX_ALLOWED_IMAGES,
+	// and AllowedImages lists the permitted ones then
+	ImagesRestricted bool     `json:"images_restricted"`
+	AllowedImages    []string `json:"allowed_images,omitempty"`
+	CheckedAt        string   `json:"checked_at"`
+}
+
+// capabilitiesCache holds the last capability report and when it was taken
+var capabilitiesCache struct {
+	sync.Mutex
+	report *serverCapabilities
+	taken  time.Time
+}
+
+// GetServerCapabilities reports the features of the Docker host sandboxes run on
+func GetServerCapabilities(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	report, err := serverCapabilitiesReport(ctx)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	return newToolResultJSON(report)
+}
+
+// serverCapabilitiesReport returns the cached report while it is fresh, and asks the daemon otherwise
+func serverCapabilitiesReport(ctx context.Context) (*serverCapabilities, error) {
+	capabilitiesCache.Lock()
+	defer capabilitiesCache.Unlock()
+	if capabilitiesCache.report != nil && time.Since(capabilitiesCache.taken) < capabilitiesTTL {
+		return capabilitiesCache.report, nil
+	}
+
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the Docker daemon: %w", err)
+	}
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the Docker daemon version: %w", err)
+	}
+
+	runtimes := make([]string, 0, len(info.Runtimes))
+	for name := range info.Runtimes {
+		runtimes = append(runtimes, name)
+	}
+	sort.Strings(runtimes)
+	_, gvisor := info.Runtimes["runsc"]
+
+	engine := versionEngine(version)
+	now := time.Now()
+	report := &serverCapabilities{
+		Engine:           engine,
+		DockerVersion:    version.Version,
+		APIVersion:       version.APIVersion,
+		OS:               info.OSType,
+		Arch:             info.Architecture,
+		OperatingSystem:  info.OperatingSystem,
+		KernelVersion:    info.KernelVersion,
+		CPUs:             info.NCPU,
+		MemoryBytes:      info.MemTotal,
+		CgroupVersion:    info.CgroupVersion,
+		Runtimes:         runtimes,
+		DefaultRuntime:   info.DefaultRuntime,
+		GVisor:           gvisor,
+		GPU:              gpuSupport(info, engine) == nil,
+		StorageDriver:    info.Driver,
+		StorageQuota:     storageQuotaSupport(info, engine) == nil,
+		SecurityOpts:     info.SecurityOptions,
+		DefaultImage:     DefaultImage(),
+		ImagesRestricted: restrictImages,
+		AllowedImages:    AllowedImages(),
+		CheckedAt:        now.UTC().Format(time.RFC3339),
+	}
+	capabilitiesCache.report, capabilitiesCache.taken = report, now
+	return report, nil
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// commitChangeInstructions are the Dockerfile instructions Docker accepts as commit changes
+var commitChangeInstructions = map[string]bool{
+	"CMD":        true,
+	"ENTRYPOINT": true,
+	"ENV":        true,
+	"EXPOSE":     true,
+	"LABEL":      true,
+	"ONBUILD":    true,
+	"STOPSIGNAL": true,
+	"USER":       true,
+	"VOLUME":     true,
+	"WORKDIR":    true,
+}
+
+// commitResult is the structured result of committing a sandbox to an image
+type commitResult struct {
+	ImageID     string `json:"image_id"`
+	Image       string `json:"image"`
+	ContainerID string `json:"container_id"`
+}
+
+// CommitToImage snapshots the filesystem of a sandbox as a new image, so prepared environments
+// can be reused by passing the image to sandbox_initialize
+func CommitToImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	image, ok := request.Params.Arguments["image"].(string)
+	if !ok || image == "" {
+		return newToolResultError("image is required"), nil
+	}
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("invalid image %q: %v", image, err)), nil
+	}
+	if _, ok := ref.(reference.Digested); ok {
+		return newToolResultError(fmt.Sprintf("invalid image %q: use a repository:tag reference, not a digest", image)), nil
+	}
+	ref = reference.TagNameOnly(ref)
+
+	message, _ := request.Params.Arguments["message"].(string)
+
+	changes, err := parseCommitChanges(request.Params.Arguments["changes"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := commitContainer(ctx, containerID, reference.FamiliarString(ref), message, changes)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// parseCommitChanges validates the changes argument, an array of Dockerfile instructions such as
+// "ENV PATH=/app/bin:$PATH"
+func parseCommitChanges(arg interface{}) ([]string, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("changes must be an array of Dockerfile instructions")
+	}
+
+	changes := make([]string, 0, len(list))
+	for _, item := range list {
+		change, ok := item.(string)
+		if !ok || strings.TrimSpace(change) == "" {
+			return nil, fmt.Errorf("each change must be a non-empty string")
+		}
+		instruction, _, _ := strings.Cut(strings.TrimSpace(change), " ")
+		if !commitChangeInstructions[strings.ToUpper(instruction)] {
+			return nil, fmt.Errorf("unsupported change %q, must start with one of CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, ONBUILD, STOPSIGNAL, USER, VOLUME or WORKDIR", change)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// commitContainer commits a managed container as image. The container is paused while its
+// filesystem is copied, so the image isn't taken halfway through a write.
+func commitContainer(ctx context.Context, containerID string, image string, message string, changes []string) (*commitResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Like sandbox_stop, only touch containers this server created
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to commit it", containerID)
+	}
+
+	// The label change comes last so the caller's changes can't override it
+	resp, err := cli.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: image,
+		Comment:   message,
+		Author:    "code-sandbox-mcp",
+		Changes:   append(changes, imageLabelChange(info.ID, info.Config.Labels)),
+		Pause:     true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit container: %w", err)
+	}
+
+	return &commitResult{
+		ImageID:     resp.ID,
+		Image:       image,
+		ContainerID: info.ID,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// fallbackImage is the sandbox image used when neither the image argument nor CODE_SANDBOX_DEFAULT_IMAGE is set
+const fallbackImage = "python:3.12-slim-bookworm"
+
+// defaultImage is read once at startup so every sandbox created by this server agrees on it
+var defaultImage = envImage("CODE_SANDBOX_DEFAULT_IMAGE", fallbackImage)
+
+// DefaultImage returns the image used for sandboxes when the caller doesn't request one
+func DefaultImage() string {
+	return defaultImage
+}
+
+// envDuration reads a duration such as "30m" or "90s" from the named environment variable.
+// An unset variable yields def; an unparsable one is reported and also yields def.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logger.Warn("ignoring invalid setting", "name", name, "value", value, "default", def)
+		return def
+	}
+	return d
+}
+
+// envImage reads a Docker image reference such as "python:3.12" or "registry.local/base@sha256:..."
+// from the named environment variable. An unset variable yields def; a malformed one is reported and also yields def.
+func envImage(name string, def string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	if _, err := reference.ParseNormalizedNamed(value); err != nil {
+		logger.Warn("ignoring invalid setting", "name", name, "value", value, "error", err, "default", def)
+		return def
+	}
+	return value
+}
+
+// envBool reads a boolean such as "true", "false", "1" or "0" from the named environment variable.
+// An unset variable yields def; an unparsable one is reported and also yields def.
+func envBool(name string, def bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("ignoring invalid setting", "name", name, "value", value, "default", def)
+		return def
+	}
+	return b
+}
+
+// envInt reads a non-negative integer from the named environment variable.
+// An unset variable yields def; an unparsable or negative one is reported and also yields def.
+func envInt(name string, def int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		logger.Warn("ignoring invalid setting", "name", name, "value", value, "default", def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a positive number such as "512" or "0.5" from the named environment variable.
+// An unset variable yields def; an unparsable, zero or negative one is reported and also yields def.
+func envFloat(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		logger.Warn("ignoring invalid setting", "name", name, "value", value, "default", def)
+		return def
+	}
+	return f
+}
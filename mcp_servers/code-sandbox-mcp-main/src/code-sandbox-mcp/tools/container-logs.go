@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultMaxLogBytes caps the size of the logs returned by sandbox_logs when no cap is requested
+const defaultMaxLogBytes = 64 * 1024
+
+// GetContainerLogs returns the stdout and stderr logs of a container as text
+func GetContainerLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	logOpts := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	}
+
+	// Only the last lines when tail is given, everything otherwise
+	if request.Params.Arguments["tail"] != nil {
+		tail, err := positiveNumberArg(request.Params.Arguments, "tail", 0)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		logOpts.Tail = strconv.Itoa(int(tail))
+	}
+
+	// Docker accepts RFC 3339 timestamps, Unix timestamps and relative durations such as 10m
+	if since, ok := request.Params.Arguments["since"].(string); ok {
+		logOpts.Since = since
+	}
+
+	// max_bytes is the older name of max_output_bytes and is still honoured
+	maxBytes, err := positiveNumberArg(request.Params.Arguments, "max_bytes", defaultMaxLogBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, int(maxBytes))
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	logs, err := ReadContainerLogs(ctx, containerID, logOpts, maxOutputBytes)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error reading container logs: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(logs), nil
+}
+
+// ReadContainerLogs returns the logs of a container with stdout and stderr combined, keeping only the
+// most recent maxBytes of them with a notice in place of the rest. Zero keeps all of them.
+// Containers created with a TTY log a raw stream, all others a multiplexed one that has to be split.
+func ReadContainerLogs(ctx context.Context, containerID string, logOpts container.LogsOptions, maxBytes int) (string, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", inspectError(containerID, err)
+	}
+
+	reader, err := cli.ContainerLogs(ctx, containerID, logOpts)
+	if err != nil {
+		return "", fmt.Errorf("error fetching container logs: %w", err)
+	}
+	defer reader.Close()
+
+	var b outputBuffer = &strings.Builder{}
+	if maxBytes > 0 {
+		b = &tailWriter{limit: maxBytes}
+	}
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(b, reader)
+	} else {
+		_, err = stdcopy.StdCopy(b, b, reader)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error copying container logs: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// containerNamePrefix namespaces the names of sandboxes so they can't collide with unrelated containers
+const containerNamePrefix = "csmcp-"
+
+var (
+	// containerNamePattern is the set of names Docker accepts for a container
+	containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	// fullContainerIDPattern matches a complete container ID, which never needs a name lookup
+	fullContainerIDPattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// parseContainerName validates the optional name argument of sandbox_initialize and returns the
+// namespaced container name, or "" to let Docker pick a random one
+func parseContainerName(raw interface{}) (string, error) {
+	if raw == nil {
+		return "", nil
+	}
+	name, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("name must be a string")
+	}
+	if name == "" {
+		return "", nil
+	}
+	if !containerNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid name %q: use letters, digits, '_', '.' and '-', starting with a letter or digit", name)
+	}
+	return sandboxContainerName(name), nil
+}
+
+// sandboxContainerName returns the Docker container name of a sandbox, adding the namespace prefix unless it is already there
+func sandboxContainerName(name string) string {
+	if strings.HasPrefix(name, containerNamePrefix) {
+		return name
+	}
+	return containerNamePrefix + name
+}
+
+// containerIDArg reads the required container_id argument, which may be a container ID, a sandbox
+// name given to sandbox_initialize, or its full namespaced name, and resolves it to a container ID
+func containerIDArg(ctx context.Context, args map[string]interface{}) (string, error) {
+	ref, ok := args["container_id"].(string)
+	if !ok || ref == "" {
+		return "", fmt.Errorf("container_id is required")
+	}
+	return ResolveContainer(ctx, ref)
+}
+
+// ResolveContainer returns the ID of the sandbox named ref, or ref itself when there is no sandbox
+// by that name, so IDs and ID prefixes are passed through to Docker unchanged
+func ResolveContainer(ctx context.Context, ref string) (string, error) {
+	if fullContainerIDPattern.MatchString(ref) || !containerNamePattern.MatchString(ref) {
+		return ref, nil
+	}
+
+	cli, err := DockerClient()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := cli.ContainerInspect(ctx, sandboxContainerName(ref))
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return ref, nil
+		}
+		return "", fmt.Errorf("failed to look up sandbox %s: %w", ref, err)
+	}
+	if info.Config == nil || !isManaged(info.Config.Labels) {
+		return ref, nil
+	}
+	return info.ID, nil
+}
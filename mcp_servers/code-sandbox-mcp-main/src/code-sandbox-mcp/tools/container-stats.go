@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// containerStats is the structured resource usage of a running container
+type containerStats struct {
+	ContainerID      string  `json:"container_id"`
+	CPUPercent       float64 `json:"cpu_percent"`
+	MemoryUsageBytes uint64  `json:"memory_usage_bytes"`
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes"`
+	MemoryPercent    float64 `json:"memory_percent"`
+	NetworkRxBytes   uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes   uint64  `json:"network_tx_bytes"`
+	Pids             uint64  `json:"pids"`
+}
+
+// GetContainerStats returns a snapshot of the CPU, memory and network usage of a container
+func GetContainerStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	stats, err := getContainerStats(ctx, containerID)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error getting container stats: %v", err)), nil
+	}
+
+	return newToolResultJSON(stats)
+}
+
+// getContainerStats samples the container's stats once and converts them into usage figures
+func getContainerStats(ctx context.Context, containerID string) (*containerStats, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireRunning(ctx, cli, containerID); err != nil {
+		return nil, err
+	}
+
+	// Without streaming the daemon takes two samples, so precpu_stats is filled in for the CPU delta
+	resp, err := cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var raw container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	return statsFromResponse(containerID, raw), nil
+}
+
+// requireRunning fails for a container that isn't running: a stopped container reports all-zero
+// stats, which would look like an idle sandbox
+func requireRunning(ctx context.Context, cli DockerAPI, containerID string) error {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return inspectError(containerID, err)
+	}
+	if info.State == nil || !info.State.Running {
+		state := "unknown"
+		if info.State != nil {
+			state = info.State.Status
+		}
+		return fmt.Errorf("container %s is not running (state: %s), so it has no live resource usage", containerID, state)
+	}
+	return nil
+}
+
+// statsFromResponse converts one stats sample from the daemon into usage figures
+func statsFromResponse(containerID string, raw container.StatsResponse) *containerStats {
+	stats := &containerStats{
+		ContainerID:      containerID,
+		CPUPercent:       cpuPercent(raw),
+		MemoryUsageBytes: memoryUsage(raw.MemoryStats),
+		MemoryLimitBytes: raw.MemoryStats.Limit,
+		Pids:             raw.PidsStats.Current,
+	}
+	if stats.MemoryLimitBytes > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsageBytes) / float64(stats.MemoryLimitBytes) * 100
+	}
+	for _, n := range raw.Networks {
+		stats.NetworkRxBytes += n.RxBytes
+		stats.NetworkTxBytes += n.TxBytes
+	}
+	return stats
+}
+
+// cpuPercent computes CPU usage the same way `docker stats` does: the container's share of the
+// host CPU time between the two samples, scaled by the number of CPUs, so one busy core is 100%
+func cpuPercent(s container.StatsResponse) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(s.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// memoryUsage returns the memory in use excluding the page cache, matching `docker stats`.
+// cgroup v1 reports the cache as total_inactive_file, cgroup v2 as inactive_file.
+func memoryUsage(m container.MemoryStats) uint64 {
+	cache, ok := m.Stats["total_inactive_file"]
+	if !ok {
+		cache = m.Stats["inactive_file"]
+	}
+	if cache < m.Usage {
+		return m.Usage - cache
+	}
+	return m.Usage
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// CopyBetweenContainers copies a file or directory from one sandbox into another. The archive is
+// streamed from the source to the destination by the server, so it never passes through the client.
+func CopyBetweenContainers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	sourceRef, ok := request.Params.Arguments["source_container_id"].(string)
+	if !ok || sourceRef == "" {
+		return newToolResultError("source_container_id is required"), nil
+	}
+	sourceID, err := ResolveContainer(ctx, sourceRef)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	destRef, ok := request.Params.Arguments["dest_container_id"].(string)
+	if !ok || destRef == "" {
+		return newToolResultError("dest_container_id is required"), nil
+	}
+	destID, err := ResolveContainer(ctx, destRef)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	sourcePath, ok := request.Params.Arguments["source_path"].(string)
+	if !ok || sourcePath == "" {
+		return newToolResultError("source_path is required"), nil
+	}
+	sourcePath, err = resolveContainerPath(ctx, sourceID, sourcePath)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving source_path: %v", err)), nil
+	}
+
+	// The source is placed in the destination's working directory unless another one is given
+	destDir, _ := request.Params.Arguments["dest_dir"].(string)
+	if destDir == "" {
+		destDir = "."
+	}
+	destDir, err = resolveContainerPath(ctx, destID, destDir)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving dest_dir: %v", err)), nil
+	}
+
+	n, err := copyBetweenContainers(ctx, sourceID, sourcePath, destID, destDir)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error copying between containers: %v", err)), nil
+	}
+
+	touchContainer(sourceID)
+	touchContainer(destID)
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s from container %s to %s in container %s (%d bytes transferred)", sourcePath, sourceID, destDir, destID, n)), nil
+}
+
+// copyBetweenContainers streams srcPath out of one container and extracts it into destDir of
+// another, returning the size of the tar stream. The archive goes through a pipe, so only a
+// buffer's worth is held in memory no matter how large the artifact is.
+func copyBetweenContainers(ctx context.Context, sourceID, srcPath, destID, destDir string) (int64, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return 0, err
+	}
+
+	reader, _, err := getArchive(ctx, cli, sourceID, srcPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy from container %s: %w", sourceID, err)
+	}
+	defer reader.Close()
+
+	pr, pw := io.Pipe()
+	copied := make(chan int64, 1)
+	go func() {
+		n, err := io.Copy(pw, reader)
+		// A failed read reaches the extraction as an error instead of a truncated archive
+		pw.CloseWithError(err)
+		copied <- n
+	}()
+
+	err = copyToContainer(ctx, destID, destDir, pr)
+	// Unblock the copy if the extraction stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	n := <-copied
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy to container %s: %w", destID, err)
+	}
+	return n, nil
+}
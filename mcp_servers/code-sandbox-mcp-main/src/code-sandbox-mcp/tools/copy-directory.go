@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// CopyDirectory extracts a whole directory tree into a container, either from a base64-encoded
+// tar archive supplied by the client or from a directory on the server's host
+func CopyDirectory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	archiveB64, _ := request.Params.Arguments["archive_base64"].(string)
+	localSrcDir, _ := request.Params.Arguments["local_src_dir"].(string)
+	if (archiveB64 == "") == (localSrcDir == "") {
+		return newToolResultError("exactly one of archive_base64 or local_src_dir is required"), nil
+	}
+
+	// The tree is extracted into the working directory unless another destination is given
+	destDir, _ := request.Params.Arguments["dest_dir"].(string)
+	if destDir == "" {
+		destDir = "."
+	}
+	destDir, err = resolveContainerPath(ctx, containerID, destDir)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving dest_dir: %v", err)), nil
+	}
+
+	limits := newUploadLimits()
+	var archive io.Reader
+	if archiveB64 != "" {
+		data, err := base64.StdEncoding.DecodeString(archiveB64)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("archive_base64 is not valid base64: %v", err)), nil
+		}
+		archive, err = repackArchive(data, limits)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error reading archive: %v", err)), nil
+		}
+	} else {
+		localSrcDir = filepath.Clean(localSrcDir)
+		info, err := os.Stat(localSrcDir)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error accessing source directory: %v", err)), nil
+		}
+		if !info.IsDir() {
+			return newToolResultError("local_src_dir must be a directory"), nil
+		}
+		archive, err = createTarArchive(localSrcDir, limits)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error creating tar archive: %v", err)), nil
+		}
+	}
+
+	if err := copyToContainer(ctx, containerID, destDir, archive); err != nil {
+		return newToolResultError(fmt.Sprintf("Error copying to container: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %d files and directories (%d bytes) to %s in container %s", limits.files, limits.bytes, destDir, containerID)), nil
+}
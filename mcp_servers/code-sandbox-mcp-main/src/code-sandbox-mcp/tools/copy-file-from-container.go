@@ -7,28 +7,27 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 // CopyFileFromContainer copies a single file from a container's filesystem to the local filesystem
 func CopyFileFromContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Extract parameters
-	containerID, ok := request.Params.Arguments["container_id"].(string)
-	if !ok || containerID == "" {
-		return mcp.NewToolResultText("container_id is required"), nil
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
 	}
 
 	containerSrcPath, ok := request.Params.Arguments["container_src_path"].(string)
 	if !ok || containerSrcPath == "" {
-		return mcp.NewToolResultText("container_src_path is required"), nil
+		return newToolResultError("container_src_path is required"), nil
 	}
 
-	// If container path doesn't start with /, prepend /app/
-	if !strings.HasPrefix(containerSrcPath, "/") {
-		containerSrcPath = filepath.Join("/app", containerSrcPath)
+	// If container path doesn't start with /, resolve it against the container working dir
+	containerSrcPath, err = resolveContainerPath(ctx, containerID, containerSrcPath)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving container_src_path: %v", err)), nil
 	}
 
 	// Get the local destination path (optional parameter)
@@ -41,30 +40,27 @@ func CopyFileFromContainer(ctx context.Context, request mcp.CallToolRequest) (*m
 	// Clean and create the destination directory if it doesn't exist
 	localDestPath = filepath.Clean(localDestPath)
 	if err := os.MkdirAll(filepath.Dir(localDestPath), 0755); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error creating destination directory: %v", err)), nil
+		return newToolResultError(fmt.Sprintf("Error creating destination directory: %v", err)), nil
 	}
 
 	// Copy the file from the container
 	if err := copyFileFromContainer(ctx, containerID, containerSrcPath, localDestPath); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error copying file from container: %v", err)), nil
+		return newToolResultError(fmt.Sprintf("Error copying file from container: %v", err)), nil
 	}
 
+	touchContainer(containerID)
 	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s from container %s to %s", containerSrcPath, containerID, localDestPath)), nil
 }
 
 // copyFileFromContainer copies a single file from the container to the local filesystem
 func copyFileFromContainer(ctx context.Context, containerID string, srcPath string, destPath string) error {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
+	cli, err := DockerClient()
 	if err != nil {
-		return fmt.Errorf("failed to create Docker client: %w", err)
+		return err
 	}
-	defer cli.Close()
 
 	// Create reader for the file from container
-	reader, stat, err := cli.CopyFromContainer(ctx, containerID, srcPath)
+	reader, stat, err := getArchive(ctx, cli, containerID, srcPath)
 	if err != nil {
 		return fmt.Errorf("failed to copy from container: %w", err)
 	}
@@ -8,73 +8,68 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 // CopyFile copies a single local file to a container's filesystem
 func CopyFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Extract parameters
-	containerID, ok := request.Params.Arguments["container_id"].(string)
-	if !ok || containerID == "" {
-		return mcp.NewToolResultText("container_id is required"), nil
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
 	}
 
 	localSrcFile, ok := request.Params.Arguments["local_src_file"].(string)
 	if !ok || localSrcFile == "" {
-		return mcp.NewToolResultText("local_src_file is required"), nil
+		return newToolResultError("local_src_file is required"), nil
 	}
 
 	// Clean and validate the source path
 	localSrcFile = filepath.Clean(localSrcFile)
 	info, err := os.Stat(localSrcFile)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error accessing source file: %v", err)), nil
+		return newToolResultError(fmt.Sprintf("Error accessing source file: %v", err)), nil
 	}
 
 	if info.IsDir() {
-		return mcp.NewToolResultText("local_src_file must be a file, not a directory"), nil
+		return newToolResultError("local_src_file must be a file, not a directory"), nil
 	}
 
 	// Get the destination path (optional parameter)
 	destPath, ok := request.Params.Arguments["dest_path"].(string)
 	if !ok || destPath == "" {
 		// Default: use the name of the source file
-		destPath = filepath.Join("/app", filepath.Base(localSrcFile))
-	} else {
-		// If provided but doesn't start with /, prepend /app/
-		if !strings.HasPrefix(destPath, "/") {
-			destPath = filepath.Join("/app", destPath)
-		}
+		destPath = filepath.Base(localSrcFile)
+	}
+	// If the path doesn't start with /, resolve it against the container working dir
+	destPath, err = resolveContainerPath(ctx, containerID, destPath)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving dest_path: %v", err)), nil
 	}
 
 	// Create destination directory in container if it doesn't exist
 	destDir := filepath.Dir(destPath)
 	if err := createDirectoryInContainer(ctx, containerID, destDir); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error creating destination directory: %v", err)), nil
+		return newToolResultError(fmt.Sprintf("Error creating destination directory: %v", err)), nil
 	}
 
 	// Copy the file to the container
 	if err := copyFileToContainer(ctx, containerID, localSrcFile, destPath); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error copying file to container: %v", err)), nil
+		return newToolResultError(fmt.Sprintf("Error copying file to container: %v", err)), nil
 	}
 
+	touchContainer(containerID)
 	return mcp.NewToolResultText(fmt.Sprintf("Successfully copied %s to %s in container %s", localSrcFile, destPath, containerID)), nil
 }
 
 // createDirectoryInContainer creates a directory in the container if it doesn't exist
 func createDirectoryInContainer(ctx context.Context, containerID string, dirPath string) error {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
+	cli, err := DockerClient()
 	if err != nil {
-		return fmt.Errorf("failed to create Docker client: %w", err)
+		return err
 	}
-	defer cli.Close()
 
 	createDirCmd := []string{"mkdir", "-p", dirPath}
 	exec, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
@@ -95,14 +90,10 @@ func createDirectoryInContainer(ctx context.Context, containerID string, dirPath
 
 // copyFileToContainer copies a single file to the container
 func copyFileToContainer(ctx context.Context, containerID string, srcPath string, destPath string) error {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
+	cli, err := DockerClient()
 	if err != nil {
-		return fmt.Errorf("failed to create Docker client: %w", err)
+		return err
 	}
-	defer cli.Close()
 
 	// Open and stat the source file
 	srcFile, err := os.Open(srcPath)
@@ -146,9 +137,12 @@ func copyFileToContainer(ctx context.Context, containerID string, srcPath string
 	}
 
 	// Copy the tar archive to the container
-	err = cli.CopyToContainer(ctx, containerID, filepath.Dir(destPath), &buf, container.CopyToContainerOptions{})
+	err = putArchive(ctx, cli, containerID, filepath.Dir(destPath), &buf, container.CopyToContainerOptions{
+		// Match the container user rather than the host file's owner
+		CopyUIDGID: true,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to copy to container: %w", err)
+		return err
 	}
 
 	return nil
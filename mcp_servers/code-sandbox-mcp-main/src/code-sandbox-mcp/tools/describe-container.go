@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// containerDescription is the curated subset of docker inspect returned by sandbox_describe
+type containerDescription struct {
+	ContainerID string            `json:"container_id"`
+	Name        string            `json:"name"`
+	Managed     bool              `json:"managed"`
+	Image       string            `json:"image"`
+	ImageID     string            `json:"image_id"`
+	Created     string            `json:"created"`
+	User        string            `json:"user,omitempty"`
+	WorkingDir  string            `json:"working_dir,omitempty"`
+	State       containerState    `json:"state"`
+	Resources   containerLimits   `json:"resources"`
+	Mounts      []containerMount  `json:"mounts"`
+	Network     containerNetwork  `json:"network"`
+	Labels      map[string]string `json:"labels"`
+}
+
+// containerState is the state section of a containerDescription
+type containerState struct {
+	Status     string `json:"status"`
+	Running    bool   `json:"running"`
+	ExitCode   int    `json:"exit_code"`
+	OOMKilled  bool   `json:"oom_killed"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// containerLimits is the resources section of a containerDescription. Zero limits are unlimited.
+type containerLimits struct {
+	MemoryBytes    int64    `json:"memory_bytes"`
+	CPUs           float64  `json:"cpus"`
+	PidsLimit      int64    `json:"pids_limit"`
+	ReadonlyRootfs bool     `json:"readonly_rootfs"`
+	Runtime        string   `json:"runtime,omitempty"`
+	CapAdd         []string `json:"cap_add,omitempty"`
+	SecurityOpt    []string `json:"security_opt,omitempty"`
+	Ulimits        []string `json:"ulimits,omitempty"`
+}
+
+// containerMount is a bind, volume or tmpfs mount of a containerDescription
+type containerMount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination"`
+	ReadWrite   bool   `json:"read_write"`
+}
+
+// containerNetwork is the network section of a containerDescription
+type containerNetwork struct {
+	Mode     string            `json:"mode"`
+	Networks map[string]string `json:"networks"`
+	Ports    []string          `json:"ports,omitempty"`
+}
+
+// DescribeContainer returns the configuration and state of a container in a compact form
+func DescribeContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	description, err := describeContainer(ctx, containerID)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	return newToolResultJSON(description)
+}
+
+// describeContainer inspects a container and picks out the parts useful for debugging a sandbox
+func describeContainer(ctx context.Context, containerID string) (*containerDescription, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+
+	d := &containerDescription{
+		ContainerID: info.ID,
+		Name:        strings.TrimPrefix(info.Name, "/"),
+		Image:       info.Config.Image,
+		ImageID:     info.Image,
+		Created:     info.Created,
+		User:        info.Config.User,
+		WorkingDir:  info.Config.WorkingDir,
+		Labels:      info.Config.Labels,
+		Mounts:      []containerMount{},
+	}
+	d.Managed = isManaged(d.Labels)
+
+	if info.State != nil {
+		d.State = describeState(info.State)
+	}
+
+	if hc := info.HostConfig; hc != nil {
+		d.Resources = describeLimits(hc)
+		d.Network.Mode = string(hc.NetworkMode)
+		d.Mounts = append(d.Mounts, describeTmpfs(hc.Tmpfs)...)
+	}
+
+	for _, m := range info.Mounts {
+		d.Mounts = append(d.Mounts, containerMount{
+			Type:        string(m.Type),
+			Source:      m.Source,
+			Destination: m.Destination,
+			ReadWrite:   m.RW,
+		})
+	}
+	sort.Slice(d.Mounts, func(i, j int) bool { return d.Mounts[i].Destination < d.Mounts[j].Destination })
+
+	d.Network.Networks = map[string]string{}
+	if info.NetworkSettings != nil {
+		for name, endpoint := range info.NetworkSettings.Networks {
+			d.Network.Networks[name] = endpoint.IPAddress
+		}
+		for port, bindings := range info.NetworkSettings.Ports {
+			for _, b := range bindings {
+				d.Network.Ports = append(d.Network.Ports, fmt.Sprintf("%s:%s->%s", b.HostIP, b.HostPort, port))
+			}
+		}
+		sort.Strings(d.Network.Ports)
+	}
+
+	return d, nil
+}
+
+// describeState converts the state of docker inspect into the state section of a description
+func describeState(state *container.State) containerState {
+	s := containerState{
+		Status:    state.Status,
+		Running:   state.Running,
+		ExitCode:  state.ExitCode,
+		OOMKilled: state.OOMKilled,
+		StartedAt: state.StartedAt,
+		Error:     state.Error,
+	}
+	// Docker reports the zero time for containers that haven't finished
+	if !state.Running && !strings.HasPrefix(state.FinishedAt, "0001-") {
+		s.FinishedAt = state.FinishedAt
+	}
+	return s
+}
+
+// describeLimits picks the resource limits and security settings out of a host config
+func describeLimits(hc *container.HostConfig) containerLimits {
+	limits := containerLimits{
+		MemoryBytes:    hc.Memory,
+		CPUs:           float64(hc.NanoCPUs) / 1e9,
+		ReadonlyRootfs: hc.ReadonlyRootfs,
+		Runtime:        hc.Runtime,
+		CapAdd:         hc.CapAdd,
+		SecurityOpt:    describeSecurityOpt(hc.SecurityOpt),
+	}
+	if hc.PidsLimit != nil {
+		limits.PidsLimit = *hc.PidsLimit
+	}
+	for _, u := range hc.Ulimits {
+		limits.Ulimits = append(limits.Ulimits, u.String())
+	}
+	return limits
+}
+
+// describeTmpfs lists the tmpfs mounts of a host config, which docker inspect doesn't include in its mounts
+func describeTmpfs(tmpfs map[string]string) []containerMount {
+	var mounts []containerMount
+	for target, opts := range tmpfs {
+		readOnly := false
+		for _, opt := range strings.Split(opts, ",") {
+			readOnly = readOnly || opt == "ro"
+		}
+		mounts = append(mounts, containerMount{Type: "tmpfs", Destination: target, ReadWrite: !readOnly})
+	}
+	return mounts
+}
+
+// describeSecurityOpt shortens inline seccomp profiles, which hold a whole JSON document, to a
+// placeholder so the description stays readable
+func describeSecurityOpt(opts []string) []string {
+	out := make([]string, 0, len(opts))
+	for _, opt := range opts {
+		if profile, ok := strings.CutPrefix(opt, "seccomp="); ok && strings.HasPrefix(profile, "{") {
+			opt = "seccomp=<custom profile>"
+		}
+		out = append(out, opt)
+	}
+	return out
+}
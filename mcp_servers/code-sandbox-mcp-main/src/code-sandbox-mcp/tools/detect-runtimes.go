@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// detectRuntimesTimeout bounds the single shell invocation that runs every probe
+const detectRuntimesTimeout = 30 * time.Second
+
+// runtimeProbe is a command that prints the version of one language runtime
+type runtimeProbe struct {
+	// Name is the runtime, using the sandbox_run_code language name where there is one
+	Name string
+	// Binary is looked up on the PATH before Args are run with it
+	Binary string
+	Args   []string
+}
+
+// runtimeProbes are the runtimes looked for, in the order they are reported
+var runtimeProbes = []runtimeProbe{
+	{Name: "python", Binary: "python3", Args: []string{"--version"}},
+	{Name: "python2", Binary: "python2", Args: []string{"--version"}},
+	{Name: "node", Binary: "node", Args: []string{"--version"}},
+	{Name: "deno", Binary: "deno", Args: []string{"--version"}},
+	{Name: "bun", Binary: "bun", Args: []string{"--version"}},
+	{Name: "ruby", Binary: "ruby", Args: []string{"--version"}},
+	{Name: "bash", Binary: "bash", Args: []string{"--version"}},
+	{Name: "go", Binary: "go", Args: []string{"version"}},
+	{Name: "rust", Binary: "rustc", Args: []string{"--version"}},
+	{Name: "java", Binary: "java", Args: []string{"-version"}},
+	{Name: "dotnet", Binary: "dotnet", Args: []string{"--version"}},
+	{Name: "php", Binary: "php", Args: []string{"--version"}},
+	{Name: "perl", Binary: "perl", Args: []string{"-e", "print $^V"}},
+	{Name: "r", Binary: "Rscript", Args: []string{"--version"}},
+	{Name: "gcc", Binary: "gcc", Args: []string{"--version"}},
+}
+
+// probeMarker starts the lines the probe script writes about each probe, keeping them apart from
+// the output of the probed commands
+const probeMarker = "@@code-sandbox-probe"
+
+// runtimeVersionPattern finds the version number in a probe's output, e.g. 3.12.4 in "Python 3.12.4"
+var runtimeVersionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// detectedRuntime reports the outcome of one probe
+type detectedRuntime struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	// Version is the version number found in the output, and Output the first line of it
+	Version string `json:"version,omitempty"`
+	Output  string `json:"output,omitempty"`
+	// Error is set when the runtime is on the PATH but its version command failed
+	Error string `json:"error,omitempty"`
+}
+
+// detectRuntimesResult is the structured result of probing a container for language runtimes
+type detectRuntimesResult struct {
+	ContainerID string `json:"container_id"`
+	// Available lists the names of the runtimes found, for quick checks
+	Available []string          `json:"available"`
+	Runtimes  []detectedRuntime `json:"runtimes"`
+}
+
+// DetectRuntimes reports which language runtimes a container has and their versions, so code can
+// be run with an interpreter that exists in an image brought by the user
+func DetectRuntimes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := detectRuntimes(ctx, containerID)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// detectRuntimes runs every probe in one sh invocation. A probe that fails is reported on its own
+// and doesn't affect the others; only a container that can't run sh at all is an error.
+func detectRuntimes(ctx context.Context, containerID string) (*detectRuntimesResult, error) {
+	result, err := runCommandInContainer(ctx, containerID, []string{"sh", "-c", probeScript(runtimeProbes)},
+		execOverrides{}, execIO{MaxOutputBytes: defaultMaxOutputBytes}, detectRuntimesTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run runtime probes: %w", err)
+	}
+	if result.ExitCode != 0 && !strings.Contains(result.Stdout, probeMarker) {
+		return nil, fmt.Errorf("failed to run runtime probes: sh exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	runtimes := parseProbeOutput(runtimeProbes, result.Stdout)
+	detected := &detectRuntimesResult{ContainerID: containerID, Available: []string{}, Runtimes: runtimes}
+	for _, r := range runtimes {
+		if r.Available {
+			detected.Available = append(detected.Available, r.Name)
+		}
+	}
+	return detected, nil
+}
+
+// probeTimeoutSeconds stops a single probe that hangs, where the image has the timeout command,
+// so it can't use up the time of the others
+const probeTimeoutSeconds = 5
+
+// probeScript returns a shell script that, for each probe in turn, writes a marker line with the
+// probe's index, then either "missing" or the probe's combined output followed by its exit code
+func probeScript(probes []runtimeProbe) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "t=; if command -v timeout >/dev/null 2>&1; then t='timeout %d'; fi; ", probeTimeoutSeconds)
+	for i, p := range probes {
+		cmd := shellQuote(p.Binary)
+		for _, arg := range p.Args {
+			cmd += " " + shellQuote(arg)
+		}
+		fmt.Fprintf(&b, "echo '%s %d'; ", probeMarker, i)
+		fmt.Fprintf(&b, "if command -v %s >/dev/null 2>&1; then out=$($t %s 2>&1 </dev/null); rc=$?; printf '%%s\\n' \"$out\"; echo \"%s exit $rc\"; else echo '%s missing'; fi; ",
+			shellQuote(p.Binary), cmd, probeMarker, probeMarker)
+	}
+	return b.String()
+}
+
+// parseProbeOutput turns the output of probeScript into one result per probe. Probes whose section
+// is missing, e.g. because the output was cut short, are reported as unavailable.
+func parseProbeOutput(probes []runtimeProbe, stdout string) []detectedRuntime {
+	runtimes := make([]detectedRuntime, len(probes))
+	output := make([][]string, len(probes))
+	for i, p := range probes {
+		runtimes[i].Name = p.Name
+	}
+
+	current := -1
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimRight(line, "\r")
+		rest, isMarker := strings.CutPrefix(line, probeMarker+" ")
+		if !isMarker {
+			if current >= 0 {
+				output[current] = append(output[current], line)
+			}
+			continue
+		}
+		if i, err := strconv.Atoi(rest); err == nil && i >= 0 && i < len(probes) {
+			current = i
+			continue
+		}
+		if current < 0 {
+			continue
+		}
+		if code, ok := strings.CutPrefix(rest, "exit "); ok {
+			r := &runtimes[current]
+			r.Output = firstLine(output[current])
+			switch code {
+			case "0":
+				r.Available = true
+				r.Version = runtimeVersionPattern.FindString(r.Output)
+			case "124":
+				r.Error = fmt.Sprintf("%s timed out after %d seconds", probes[current].Binary, probeTimeoutSeconds)
+			default:
+				r.Error = fmt.Sprintf("%s exited with code %s", probes[current].Binary, code)
+			}
+		}
+		// "missing" leaves the runtime unavailable without an error
+	}
+	return runtimes
+}
+
+// shellQuote quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// firstLine returns the first non-empty line, where version commands put the version
+func firstLine(lines []string) string {
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			return l
+		}
+	}
+	return ""
+}
@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// DockerAPI is the subset of the Docker client used by the tools. *client.Client implements it,
+// and tests can substitute a fake with SetDockerClient.
+type DockerAPI interface {
+	Ping(ctx context.Context) (types.Ping, error)
+	Info(ctx context.Context) (system.Info, error)
+	ServerVersion(ctx context.Context) (types.Version, error)
+
+	ImageInspectWithRaw(ctx context.Context, image string) (image.InspectResponse, []byte, error)
+	ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error)
+	ImagesPrune(ctx context.Context, pruneFilters filters.Args) (image.PruneReport, error)
+
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, container string, options container.StartOptions) error
+	ContainerStop(ctx context.Context, container string, options container.StopOptions) error
+	ContainerRestart(ctx context.Context, container string, options container.StopOptions) error
+	ContainerKill(ctx context.Context, container, signal string) error
+	ContainerPause(ctx context.Context, container string) error
+	ContainerUnpause(ctx context.Context, container string) error
+	ContainerRemove(ctx context.Context, container string, options container.RemoveOptions) error
+	ContainerInspect(ctx context.Context, container string) (container.InspectResponse, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerLogs(ctx context.Context, container string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerStats(ctx context.Context, container string, stream bool) (container.StatsResponseReader, error)
+	ContainerWait(ctx context.Context, container string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error)
+	ContainerCommit(ctx context.Context, container string, options container.CommitOptions) (container.CommitResponse, error)
+	ContainerUpdate(ctx context.Context, container string, updateConfig container.UpdateConfig) (container.UpdateResponse, error)
+
+	ContainerExecCreate(ctx context.Context, container string, options container.ExecOptions) (container.ExecCreateResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error)
+	ContainerExecStart(ctx context.Context, execID string, options container.ExecStartOptions) error
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	NetworkConnect(ctx context.Context, networkID, container string, config *network.EndpointSettings) error
+
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+
+	CopyToContainer(ctx context.Context, container, path string, content io.Reader, options container.CopyToContainerOptions) error
+	CopyFromContainer(ctx context.Context, container, srcPath string) (io.ReadCloser, container.PathStat, error)
+
+	Close() error
+}
+
+// The real client must keep satisfying the interface
+var _ DockerAPI = (*client.Client)(nil)
+
+// DockerConfig selects the Docker daemon the tools talk to. Empty fields keep the settings from
+// the environment (DOCKER_HOST, DOCKER_CERT_PATH, DOCKER_TLS_VERIFY and DOCKER_API_VERSION).
+type DockerConfig struct {
+	// Host is the daemon address, e.g. tcp://sandbox-host:2376 or unix:///var/run/docker.sock
+	Host string
+	// TLSCACert is the CA certificate used to verify the daemon
+	TLSCACert string
+	// TLSCert and TLSKey are the client certificate and key presented to the daemon
+	TLSCert string
+	TLSKey  string
+	// APIVersion pins the API version, e.g. 1.47, instead of negotiating it with the daemon
+	APIVersion string
+}
+
+const (
+	// dockerHealthCheckAfter is how long the shared client may go unchecked before DockerClient
+	// pings the daemon again, since idle connections to it can go stale, e.g. across a daemon restart
+	dockerHealthCheckAfter = 30 * time.Second
+	// dockerPingTimeout bounds a single health check, so a hung connection counts as dead
+	dockerPingTimeout = 2 * time.Second
+	// dockerReconnectAttempts bounds how often a dead client is replaced before DockerClient gives up
+	dockerReconnectAttempts = 3
+)
+
+var (
+	dockerMu     sync.Mutex
+	dockerCli    DockerAPI
+	dockerConfig DockerConfig
+	// dockerChecked is when dockerCli was created or last answered a ping
+	dockerChecked time.Time
+	// newDockerClient creates a client for cfg; tests can replace it to hand out fakes on reconnect
+	newDockerClient = func(cfg DockerConfig) (DockerAPI, error) {
+		return client.NewClientWithOpts(dockerClientOptions(cfg)...)
+	}
+)
+
+// ConfigureDocker sets how DockerClient connects to the daemon. A client that was already
+// created is closed, so the next tool call connects with the new settings.
+func ConfigureDocker(cfg DockerConfig) error {
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return fmt.Errorf("a TLS client certificate and key must be given together")
+	}
+
+	dockerMu.Lock()
+	defer dockerMu.Unlock()
+
+	dockerConfig = cfg
+	if dockerCli != nil {
+		_ = dockerCli.Close()
+		dockerCli = nil
+	}
+	return nil
+}
+
+// dockerClientOptions returns the client options for cfg, applied on top of the environment
+func dockerClientOptions(cfg DockerConfig) []client.Opt {
+	opts := []client.Opt{client.FromEnv}
+	if cfg.Host != "" {
+		opts = append(opts, client.WithHost(cfg.Host))
+	}
+	if cfg.TLSCACert != "" || cfg.TLSCert != "" {
+		opts = append(opts, client.WithTLSClientConfig(cfg.TLSCACert, cfg.TLSCert, cfg.TLSKey))
+	}
+	// An explicit version turns off negotiation, which would otherwise pick the daemon's version
+	if cfg.APIVersion != "" {
+		opts = append(opts, client.WithVersion(cfg.APIVersion))
+	} else {
+		opts = append(opts, client.WithAPIVersionNegotiation())
+	}
+	return opts
+}
+
+// DockerClient returns the Docker client shared by all tools, creating it on first use.
+// The client is safe for concurrent use and negotiates the API version once, on its first request.
+// A client that hasn't been checked for dockerHealthCheckAfter is pinged before it is handed out,
+// and replaced by a new one if its connection turns out to be dead.
+func DockerClient() (DockerAPI, error) {
+	dockerMu.Lock()
+	if dockerCli == nil {
+		dockerMu.Unlock()
+		return currentDockerClient()
+	}
+	cli := dockerCli
+	if time.Since(dockerChecked) < dockerHealthCheckAfter {
+		dockerMu.Unlock()
+		return cli, nil
+	}
+	// Calls made while the client is checked get it unchecked rather than waiting for the ping
+	dockerChecked = time.Now()
+	dockerMu.Unlock()
+
+	err := pingDocker(cli)
+	// The daemon answered, so the connection works and any error is the daemon's to report
+	if err == nil || !isDeadConnection(err) {
+		return cli, nil
+	}
+	return reconnectDocker(cli, err)
+}
+
+// dockerReconnecting is held by the one reconnectDocker that replaces a dead client at a time
+var dockerReconnecting sync.Mutex
+
+// reconnectDocker replaces the shared client dead, whose connection failed with cause, with a new
+// one, trying up to dockerReconnectAttempts times with the backoff of withDockerRetry. If the
+// daemon stays unreachable the last new client is kept anyway: the caller's own request then
+// fails with the connection error, and the next call tries again. dockerMu is only held to swap
+// the client, so other tool calls never wait for a reconnect; while one is in progress they get
+// the current client, like calls that come in before its connection is found dead.
+func reconnectDocker(dead DockerAPI, cause error) (DockerAPI, error) {
+	if !dockerReconnecting.TryLock() {
+		return currentDockerClient()
+	}
+	defer dockerReconnecting.Unlock()
+
+	logger.Warn("docker connection is dead, reconnecting", "error", cause)
+	delay := dockerRetryDelay
+	for attempt := 1; ; attempt++ {
+		dockerMu.Lock()
+		cfg := dockerConfig
+		replaced := dockerCli != dead
+		dockerMu.Unlock()
+		// ConfigureDocker or SetDockerClient got there first, and their client wins
+		if replaced {
+			return currentDockerClient()
+		}
+
+		cli, err := newDockerClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Docker client: %w", err)
+		}
+		err = pingDocker(cli)
+		healthy := err == nil || !isDeadConnection(err)
+
+		dockerMu.Lock()
+		if dockerCli != dead {
+			dockerMu.Unlock()
+			_ = cli.Close()
+			return currentDockerClient()
+		}
+		dockerCli = cli
+		if healthy {
+			dockerChecked = time.Now()
+		}
+		dockerMu.Unlock()
+		_ = dead.Close()
+		dead = cli
+
+		if healthy {
+			logger.Info("reconnected to docker", "attempt", attempt)
+			return cli, nil
+		}
+		if attempt >= dockerReconnectAttempts {
+			logger.Warn("docker is still unreachable, giving up reconnecting for now", "attempts", attempt, "error", err)
+			return cli, nil
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, maxDockerRetryDelay)
+	}
+}
+
+// currentDockerClient returns the shared client as it is, creating one if there is none
+func currentDockerClient() (DockerAPI, error) {
+	dockerMu.Lock()
+	defer dockerMu.Unlock()
+	if dockerCli == nil {
+		cli, err := newDockerClient(dockerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Docker client: %w", err)
+		}
+		dockerCli, dockerChecked = cli, time.Now()
+	}
+	return dockerCli, nil
+}
+
+// pingDocker checks that the daemon answers on cli's connection
+func pingDocker(cli DockerAPI) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerPingTimeout)
+	defer cancel()
+	_, err := cli.Ping(ctx)
+	return err
+}
+
+// isDeadConnection reports whether a ping failed because the daemon couldn't be reached at all,
+// rather than answering with an error
+func isDeadConnection(err error) bool {
+	return client.IsErrConnectionFailed(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// SetDockerClient replaces the shared Docker client, for example with a fake in tests.
+// The previous client is closed.
+func SetDockerClient(cli DockerAPI) {
+	dockerMu.Lock()
+	defer dockerMu.Unlock()
+
+	if dockerCli != nil && dockerCli != cli {
+		_ = dockerCli.Close()
+	}
+	dockerCli, dockerChecked = cli, time.Now()
+}
+
+// CloseDockerClient releases the shared Docker client. A later call to DockerClient creates a new one.
+func CloseDockerClient() error {
+	dockerMu.Lock()
+	defer dockerMu.Unlock()
+
+	if dockerCli == nil {
+		return nil
+	}
+	err := dockerCli.Close()
+	dockerCli = nil
+	return err
+}
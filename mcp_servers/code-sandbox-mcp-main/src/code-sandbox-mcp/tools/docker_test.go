@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// reconnectTest makes dead the shared client, due for a health check, and has newDockerClient hand
+// out the given clients in order
+func reconnectTest(t *testing.T, dead *fakeDocker, next ...*fakeDocker) func() int {
+	t.Helper()
+	useFakeDocker(t, dead)
+	dockerMu.Lock()
+	dockerChecked = time.Now().Add(-2 * dockerHealthCheckAfter)
+	dockerMu.Unlock()
+
+	created := 0
+	var mu sync.Mutex
+	prevNew, prevDelay := newDockerClient, dockerRetryDelay
+	newDockerClient = func(DockerConfig) (DockerAPI, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		cli := next[min(created, len(next)-1)]
+		created++
+		return cli, nil
+	}
+	dockerRetryDelay = 10 * time.Millisecond
+	t.Cleanup(func() { newDockerClient, dockerRetryDelay = prevNew, prevDelay })
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return created
+	}
+}
+
+// deadFakeDocker returns a fake whose connection to the daemon has been dropped
+func deadFakeDocker() *fakeDocker {
+	f := newFakeDocker()
+	f.pingErr = client.ErrorConnectionFailed("unix:///var/run/docker.sock")
+	return f
+}
+
+func TestDockerClientReconnectsDroppedConnection(t *testing.T) {
+	dead, stillDead, healthy := deadFakeDocker(), deadFakeDocker(), newFakeDocker()
+	created := reconnectTest(t, dead, stillDead, healthy)
+
+	cli, err := DockerClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli != healthy {
+		t.Fatal("DockerClient did not return the reconnected client")
+	}
+	if created() != 2 {
+		t.Errorf("created %d clients, want 2", created())
+	}
+	if !dead.closed || !stillDead.closed {
+		t.Error("the clients with a dead connection were not closed")
+	}
+
+	// The new client counts as checked, so the next call uses it without reconnecting
+	if cli, _ := DockerClient(); cli != healthy || created() != 2 {
+		t.Error("a freshly checked client was replaced")
+	}
+}
+
+func TestDockerClientGivesUpReconnecting(t *testing.T) {
+	last := deadFakeDocker()
+	created := reconnectTest(t, deadFakeDocker(), deadFakeDocker(), deadFakeDocker(), last)
+
+	cli, err := DockerClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created() != dockerReconnectAttempts {
+		t.Errorf("created %d clients, want %d", created(), dockerReconnectAttempts)
+	}
+	if cli != last || last.closed {
+		t.Error("the last client was not kept after giving up")
+	}
+}
+
+func TestDockerClientKeepsClientWhenDaemonAnswers(t *testing.T) {
+	f := newFakeDocker()
+	created := reconnectTest(t, f, newFakeDocker())
+
+	if cli, _ := DockerClient(); cli != f {
+		t.Error("a client with a live connection was replaced")
+	}
+	if created() != 0 {
+		t.Errorf("created %d clients, want none", created())
+	}
+}
+
+// TestDockerClientDoesNotWaitForReconnect checks that a reconnect backing off doesn't hold up
+// other calls, which get the current client instead
+func TestDockerClientDoesNotWaitForReconnect(t *testing.T) {
+	created := reconnectTest(t, deadFakeDocker(), deadFakeDocker(), deadFakeDocker(), deadFakeDocker())
+	dockerRetryDelay = 300 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = DockerClient()
+	}()
+	// Wait for the first reconnect attempt, after which it backs off
+	for deadline := time.Now().Add(time.Second); ; {
+		if created() > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if _, err := DockerClient(); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > 200*time.Millisecond {
+		t.Errorf("DockerClient waited %s for the reconnect", waited)
+	}
+	<-done
+}
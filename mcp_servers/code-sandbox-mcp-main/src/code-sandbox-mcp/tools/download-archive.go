@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultMaxArchiveBytes caps the file contents returned by sandbox_download_archive when no cap is requested
+const defaultMaxArchiveBytes = 10 * 1024 * 1024
+
+// archiveResult is the structured result of downloading part of a sandbox's filesystem
+type archiveResult struct {
+	ArchiveBase64 string `json:"archive_base64"`
+	// Format is "tar" or "tar+gzip"
+	Format    string `json:"format"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Truncated bool   `json:"truncated"`
+	Warning   string `json:"warning,omitempty"`
+}
+
+// DownloadArchive returns a directory of a container, by default its working directory, as a base64-encoded tarball
+func DownloadArchive(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	srcPath, _ := request.Params.Arguments["path"].(string)
+	if srcPath == "" {
+		srcPath = "."
+	}
+	srcPath, err = resolveContainerPath(ctx, containerID, srcPath)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving path: %v", err)), nil
+	}
+
+	filter, _ := request.Params.Arguments["filter"].(string)
+	if _, err := path.Match(filter, ""); err != nil {
+		return newToolResultError(fmt.Sprintf("invalid filter %q: %v", filter, err)), nil
+	}
+	compress, _ := request.Params.Arguments["gzip"].(bool)
+
+	maxBytes, err := positiveNumberArg(request.Params.Arguments, "max_bytes", defaultMaxArchiveBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := downloadArchive(ctx, containerID, srcPath, filter, compress, int64(maxBytes))
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error downloading archive: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// downloadArchive repacks the container's archive of srcPath with entry names relative to srcPath,
+// keeping only entries matched by filter. Once the file contents would exceed maxBytes, the
+// remaining entries are left out and the result is marked as truncated.
+func downloadArchive(ctx context.Context, containerID string, srcPath string, filter string, compress bool, maxBytes int64) (*archiveResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, _, err := getArchive(ctx, cli, containerID, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		out = gz
+	}
+	tw := tar.NewWriter(out)
+
+	result := &archiveResult{Format: "tar"}
+	if compress {
+		result.Format = "tar+gzip"
+	}
+
+	// Docker names the entries after the last element of srcPath, e.g. app/output/result.csv
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		_, name, found := strings.Cut(strings.TrimSuffix(header.Name, "/"), "/")
+		if !found || !archiveEntryMatches(name, filter) {
+			continue
+		}
+		if result.Bytes+header.Size > maxBytes {
+			result.Truncated = true
+			result.Warning = fmt.Sprintf("archive truncated: the selected files exceed %d bytes, use path or filter to download less, or raise max_bytes", maxBytes)
+			break
+		}
+
+		header.Name = name
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, fmt.Errorf("failed to read archive entry %s: %w", name, err)
+		}
+		result.Entries++
+		result.Bytes += header.Size
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	result.ArchiveBase64 = base64.StdEncoding.EncodeToString(buf.Bytes())
+	return result, nil
+}
+
+// archiveEntryMatches reports whether an entry belongs in the download. A filter is either a
+// prefix such as "output/", or a glob such as "*.csv" matched against the whole relative name
+// and against its last element. An empty filter matches everything.
+func archiveEntryMatches(name string, filter string) bool {
+	if filter == "" || strings.HasPrefix(name, filter) {
+		return true
+	}
+	if ok, _ := path.Match(filter, name); ok {
+		return true
+	}
+	ok, _ := path.Match(filter, path.Base(name))
+	return ok
+}
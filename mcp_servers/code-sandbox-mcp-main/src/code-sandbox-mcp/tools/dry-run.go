@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// dryRunResult describes the sandbox sandbox_initialize would create with dry_run. Env is left
+// out, since values passed through it may be secrets.
+type dryRunResult struct {
+	DryRun       bool   `json:"dry_run"`
+	Image        string `json:"image"`
+	ImagePresent bool   `json:"image_present"`
+	// WouldPull is set when the image is missing and allow_pull lets it be pulled. Whether the
+	// registry has it is only known once the pull runs.
+	WouldPull  bool              `json:"would_pull,omitempty"`
+	Platform   string            `json:"platform,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	User       string            `json:"user,omitempty"`
+	WorkingDir string            `json:"working_dir"`
+	Cmd        []string          `json:"cmd,omitempty"`
+	Entrypoint []string          `json:"entrypoint,omitempty"`
+	Resources  containerLimits   `json:"resources"`
+	Mounts     []containerMount  `json:"mounts"`
+	Network    string            `json:"network"`
+	Networks   []string          `json:"networks,omitempty"`
+	Aliases    []string          `json:"network_aliases,omitempty"`
+	DNS        []string          `json:"dns,omitempty"`
+	ExtraHosts []string          `json:"extra_hosts,omitempty"`
+	AutoRemove bool              `json:"auto_remove,omitempty"`
+	Labels     map[string]string `json:"labels"`
+
+	// RestartPolicy is the policy as given, e.g. on-failure:3; it is left out when it is no
+	RestartPolicy string `json:"restart_policy,omitempty"`
+	// Warnings name the options the sandbox would be created without
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// validateContainer runs every check createContainer would run before creating the sandbox and
+// describes the container it would create, without creating, pulling or otherwise changing anything
+func validateContainer(ctx context.Context, opts *containerOptions) (*dryRunResult, error) {
+	cli, err := preflightContainer(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dryRunResult{DryRun: true, Image: opts.Image, Name: opts.Name}
+	present, err := findImage(ctx, cli, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.ImagePresent, result.WouldPull = present, !present
+	if opts.Platform != nil {
+		result.Platform = platformString(opts.Platform)
+	}
+
+	// Names are unique, so a taken one would only fail at create time
+	if opts.Name != "" {
+		if _, err := cli.ContainerInspect(ctx, opts.Name); err == nil {
+			return nil, fmt.Errorf("the name %s is already in use by another container, choose a different name or stop that sandbox first", opts.Name)
+		} else if !errdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to check the name %s: %w", opts.Name, err)
+		}
+	}
+
+	config, hostConfig := containerConfig(opts)
+	result.User = config.User
+	result.WorkingDir = config.WorkingDir
+	result.Cmd = config.Cmd
+	result.Entrypoint = config.Entrypoint
+	result.Resources = describeLimits(hostConfig)
+	result.Network = string(hostConfig.NetworkMode)
+	result.Networks, result.Aliases = opts.Networks, opts.NetworkAliases
+	result.DNS, result.ExtraHosts = hostConfig.DNS, hostConfig.ExtraHosts
+	result.AutoRemove = hostConfig.AutoRemove
+	if !hostConfig.RestartPolicy.IsNone() {
+		result.RestartPolicy = string(hostConfig.RestartPolicy.Name)
+		if hostConfig.RestartPolicy.MaximumRetryCount > 0 {
+			result.RestartPolicy += ":" + strconv.Itoa(hostConfig.RestartPolicy.MaximumRetryCount)
+		}
+	}
+	result.Labels = config.Labels
+	result.Warnings = opts.Warnings
+
+	result.Mounts = append([]containerMount{}, describeTmpfs(hostConfig.Tmpfs)...)
+	for _, m := range hostConfig.Mounts {
+		result.Mounts = append(result.Mounts, containerMount{
+			Type:        string(m.Type),
+			Source:      m.Source,
+			Destination: m.Target,
+			ReadWrite:   !m.ReadOnly,
+		})
+	}
+	sort.Slice(result.Mounts, func(i, j int) bool { return result.Mounts[i].Destination < result.Mounts[j].Destination })
+
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxEnvFileBytes caps how much of an env file is read, which is far more than any real one needs
+const maxEnvFileBytes = 1 << 20
+
+// parseEnvFile converts the env_file argument into a KEY=VALUE list. The argument is either the
+// absolute path of a dotenv file on the server's host or the dotenv content itself, told apart by
+// the path being a single line starting with /. Blank lines and lines starting with # are skipped,
+// a leading "export " is allowed, and a value wrapped in single or double quotes is unquoted. A name
+// that appears on several lines keeps the value of the last one.
+func parseEnvFile(arg interface{}) ([]string, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	value, ok := arg.(string)
+	if !ok {
+		return nil, fmt.Errorf("env_file must be a string")
+	}
+
+	content, source := value, "env_file"
+	if strings.HasPrefix(value, "/") && !strings.Contains(value, "\n") {
+		path := filepath.Clean(value)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env_file %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("env_file %s is not a regular file", path)
+		}
+		if info.Size() > maxEnvFileBytes {
+			return nil, fmt.Errorf("env_file %s is larger than %d bytes", path, maxEnvFileBytes)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env_file %s: %w", path, err)
+		}
+		content, source = string(data), "env_file "+path
+	}
+
+	var env []string
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		// Errors never quote the line, whose value may well be a secret
+		name, val, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: expected KEY=VALUE", source, i+1)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("%s line %d: empty variable name", source, i+1)
+		}
+		if strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%s line %d: variable name %q must not contain whitespace", source, i+1, name)
+		}
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') {
+			if val[len(val)-1] != val[0] {
+				return nil, fmt.Errorf("%s line %d: unterminated quoted value for %s", source, i+1, name)
+			}
+			val = val[1 : len(val)-1]
+		}
+		env = append(env, name+"="+val)
+	}
+	return mergeEnv(nil, env), nil
+}
+
+// mergeEnv returns base with the entries of overrides applied on top: a name already in base takes
+// the value from overrides but keeps its position, and new names are appended in order
+func mergeEnv(base, overrides []string) []string {
+	var merged []string
+	index := map[string]int{}
+	for _, entry := range append(append([]string(nil), base...), overrides...) {
+		name, _, _ := strings.Cut(entry, "=")
+		if i, ok := index[name]; ok {
+			merged[i] = entry
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged
+}
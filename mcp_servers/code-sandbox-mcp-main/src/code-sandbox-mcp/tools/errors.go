@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// Errors for the failures callers commonly need to tell apart. The tools wrap them with more
+// detail, so use errors.Is rather than comparing the message.
+var (
+	// ErrImageNotFoundLocally means the sandbox image isn't on the Docker host and pulling wasn't allowed
+	ErrImageNotFoundLocally = errors.New("image not found locally")
+	// ErrContainerNotFound means no container has the given ID or name
+	ErrContainerNotFound = errors.New("no such container")
+	// ErrNetworkDisabled means the operation needs network access, but the sandbox was created without it
+	ErrNetworkDisabled = errors.New("network access is disabled for this sandbox")
+)
+
+// imageNotFoundError reports that a sandbox image is missing and pulling wasn't allowed
+func imageNotFoundError(image string) error {
+	return fmt.Errorf("docker %w: %s. Please build or load it before initializing a sandbox, or set allow_pull", ErrImageNotFoundLocally, image)
+}
+
+// inspectError describes a failed ContainerInspect, reporting a missing container as ErrContainerNotFound
+func inspectError(containerID string, err error) error {
+	if errdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+	}
+	return fmt.Errorf("failed to inspect container: %w", err)
+}
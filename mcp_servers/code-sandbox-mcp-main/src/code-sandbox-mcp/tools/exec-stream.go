@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ExecStream runs a single command in an existing container and reports its output line by line
+// through progress notifications while it runs. The final result holds the full output, so clients
+// that ignore progress notifications get the same result as sandbox_run_command.
+func ExecStream(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	cmd, err := parseCommandArgument(request.Params.Arguments["command"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	overrides, err := parseExecOverrides(ctx, containerID, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// Output is only streamed when the client asked for progress updates; the cap applies to the
+	// final result, every line is still streamed
+	streams := execIO{MaxOutputBytes: maxOutputBytes}
+	if stdin, ok := request.Params.Arguments["stdin"].(string); ok {
+		streams.Stdin = strings.NewReader(stdin)
+	}
+	if request.Params.Meta != nil && request.Params.Meta.ProgressToken != nil {
+		streams.OnLine = progressLineNotifier(ctx, request.Params.Meta.ProgressToken)
+	}
+
+	result, err := runCommandInContainer(ctx, containerID, cmd, overrides, streams, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error executing command: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// progressLineNotifier returns an output callback that sends each line to the client as a
+// notifications/progress message carrying the line and the stream it came from.
+// Notifications are best effort: if the client can't keep up, lines are dropped from the
+// stream but still appear in the final result.
+func progressLineNotifier(ctx context.Context, token mcp.ProgressToken) func(stream string, line string) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+
+	var progress float64
+	warned := false
+	return func(stream string, line string) {
+		progress++
+		err := srv.SendNotificationToClient(ctx, "notifications/progress", map[string]interface{}{
+			"progressToken": token,
+			"progress":      progress,
+			"message":       line,
+			"stream":        stream,
+		})
+		if err != nil && !warned {
+			logger.Warn("failed to send output notification, some streamed lines were dropped", "error", err)
+			warned = true
+		}
+	}
+}
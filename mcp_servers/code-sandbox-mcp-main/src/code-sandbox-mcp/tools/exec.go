@@ -1,22 +1,99 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/mark3labs/mcp-go/mcp"
-	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultExecTimeout bounds how long a single command may run when no timeout is requested
+const defaultExecTimeout = 30 * time.Second
+
+// With output_file, the first command's output replaces the file and later commands append to it.
+// The command and the file are passed as positional parameters so neither needs quoting.
+const (
+	outputFileScript   = `mkdir -p "$(dirname "$2")" && exec sh -c "$1" >"$2" 2>&1`
+	outputAppendScript = `exec sh -c "$1" >>"$2" 2>&1`
 )
 
+// execUserPattern matches the user forms Docker accepts for an exec: a name or UID, optionally with a group
+var execUserPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+
+// execOverrides replace the container's user and working directory for a single command.
+// Empty fields keep the container's settings.
+type execOverrides struct {
+	User       string
+	WorkingDir string
+	// Env is added to the container's environment for the command only; Docker merges the two,
+	// with these values replacing container variables of the same name
+	Env []string
+	// Tty runs the command in a pseudo-terminal, which merges stderr into stdout
+	Tty bool
+}
+
+// parseExecOverrides reads the optional user, workdir, env and tty arguments of the command tools.
+// A relative workdir is resolved against the container's working directory.
+func parseExecOverrides(ctx context.Context, containerID string, args map[string]interface{}) (execOverrides, error) {
+	var overrides execOverrides
+	if arg, ok := args["user"]; ok && arg != nil {
+		user, ok := arg.(string)
+		if !ok || (user != "" && !execUserPattern.MatchString(user)) {
+			return overrides, fmt.Errorf("user must be a user name or UID, optionally followed by :group, e.g. root or 1000:1000")
+		}
+		overrides.User = user
+	}
+	if arg, ok := args["workdir"]; ok && arg != nil {
+		workdir, ok := arg.(string)
+		if !ok {
+			return overrides, fmt.Errorf("workdir must be a string")
+		}
+		if workdir != "" {
+			resolved, err := resolveContainerPath(ctx, containerID, workdir)
+			if err != nil {
+				return overrides, err
+			}
+			overrides.WorkingDir = resolved
+		}
+	}
+	env, err := parseEnv(args["env"])
+	if err != nil {
+		return overrides, err
+	}
+	overrides.Env = env
+	if arg, ok := args["tty"]; ok && arg != nil {
+		tty, ok := arg.(bool)
+		if !ok {
+			return overrides, fmt.Errorf("tty must be a boolean")
+		}
+		overrides.Tty = tty
+	}
+	return overrides, nil
+}
+
+// apply sets the overrides on an exec configuration
+func (o execOverrides) apply(execConfig *container.ExecOptions) {
+	execConfig.User = o.User
+	execConfig.WorkingDir = o.WorkingDir
+	execConfig.Env = append(execConfig.Env, o.Env...)
+	execConfig.Tty = o.Tty
+}
+
 // Exec executes commands in a container
 func Exec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Extract parameters
-	containerID, ok := request.Params.Arguments["container_id"].(string)
-	if !ok || containerID == "" {
-		return mcp.NewToolResultText("container_id is required"), nil
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
 	}
 
 	// Commands can be a single string or an array of strings
@@ -27,18 +104,47 @@ func Exec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult
 			if cmdStr, ok := cmd.(string); ok {
 				commands = append(commands, cmdStr)
 			} else {
-				return mcp.NewToolResultText("Each command must be a string"), nil
+				return newToolResultError("Each command must be a string"), nil
 			}
 		}
 	} else if cmdStr, ok := request.Params.Arguments["commands"].(string); ok {
 		// It's a single command string
 		commands = []string{cmdStr}
 	} else {
-		return mcp.NewToolResultText("commands must be a string or an array of strings"), nil
+		return newToolResultError("commands must be a string or an array of strings"), nil
 	}
 
 	if len(commands) == 0 {
-		return mcp.NewToolResultText("at least one command is required"), nil
+		return newToolResultError("at least one command is required"), nil
+	}
+
+	// Each command gets its own timeout so a runaway process can't hang the sandbox
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// Every command of the call runs with the same user, directory and env overrides
+	overrides, err := parseExecOverrides(ctx, containerID, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// Output can go to a file in the sandbox instead of the response, to be read back on demand
+	outputFile, _ := request.Params.Arguments["output_file"].(string)
+	if outputFile != "" && overrides.Tty {
+		return newToolResultError("tty can't be combined with output_file: the output is redirected to the file, so the command wouldn't see a terminal"), nil
+	}
+	if outputFile != "" {
+		outputFile, err = resolveContainerPath(ctx, containerID, outputFile)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error resolving output_file: %v", err)), nil
+		}
 	}
 
 	// Execute each command and collect output
@@ -51,10 +157,19 @@ func Exec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult
 		outputBuilder.WriteString(fmt.Sprintf("$ %s\n", cmd))
 
 		// Execute the command
-		stdout, stderr, exitCode, err := executeCommandWithOutput(ctx, containerID, cmd)
+		argv := []string{"sh", "-c", cmd}
+		if outputFile != "" {
+			script := outputAppendScript
+			if i == 0 {
+				script = outputFileScript
+			}
+			argv = []string{"sh", "-c", script, "sh", cmd, outputFile}
+		}
+		result, err := executeCommandWithOutput(ctx, containerID, argv, overrides, timeout, maxOutputBytes)
 		if err != nil {
-			return mcp.NewToolResultText(fmt.Sprintf("Error executing command: %v", err)), nil
+			return newToolResultError(fmt.Sprintf("Error executing command: %v", err)), nil
 		}
+		stdout, stderr, exitCode := result.Stdout, result.Stderr, result.ExitCode
 
 		// Add the command output to the collector
 		if stdout != "" {
@@ -74,53 +189,323 @@ func Exec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult
 		// If the command failed, add the exit code and stop processing subsequent commands
 		if exitCode != 0 {
 			outputBuilder.WriteString(fmt.Sprintf("Command exited with code %d\n", exitCode))
+			if result.OOMKilled {
+				outputBuilder.WriteString(fmt.Sprintf("Note: %s\n", result.Note))
+			}
 			break
 		}
 	}
 
+	if outputFile != "" {
+		outputBuilder.WriteString(fmt.Sprintf("\nOutput written to %s\n", outputFile))
+	}
+
+	touchContainer(containerID)
 	return mcp.NewToolResultText(outputBuilder.String()), nil
 }
 
-// executeCommandWithOutput runs a command in a container and returns its stdout, stderr, exit code, and any error
-func executeCommandWithOutput(ctx context.Context, containerID string, cmd string) (stdout string, stderr string, exitCode int, err error) {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
+// executeCommandWithOutput runs a command in a container and returns its output and exit code
+func executeCommandWithOutput(ctx context.Context, containerID string, cmd []string, overrides execOverrides, timeout time.Duration, maxOutputBytes int) (*commandResult, error) {
+	cli, err := DockerClient()
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to create Docker client: %w", err)
+		return nil, err
 	}
-	defer cli.Close()
+
+	execConfig := container.ExecOptions{
+		Cmd: cmd,
+	}
+	overrides.apply(&execConfig)
+	return runAttachedExec(ctx, cli, containerID, execConfig, execIO{MaxOutputBytes: maxOutputBytes}, timeout)
+}
+
+// execIO holds the optional input and live output hooks of an attached exec
+type execIO struct {
+	// Stdin is streamed to the command when non-nil, which then sees EOF
+	Stdin io.Reader
+	// OnLine is called with each line of output as soon as it is complete, tagged with
+	// its stream ("stdout" or "stderr"). The full output is still returned at the end.
+	OnLine func(stream string, line string)
+	// MaxOutputBytes caps how much of each of stdout and stderr is kept, with a notice in place of
+	// the rest. Output beyond the cap is discarded as it is read. Zero keeps all of it.
+	MaxOutputBytes int
+}
+
+// ttyConsoleSize is the height and width of the terminal of an exec run with a TTY
+var ttyConsoleSize = [2]uint{24, 80}
+
+// runAttachedExec runs an exec instance with stdout and stderr attached and waits for it to finish.
+// If the command is still running when the timeout expires, its processes are killed inside the
+// container and an error is returned. With execConfig.Tty the command runs in a pseudo-terminal,
+// whose single stream is returned as stdout.
+func runAttachedExec(ctx context.Context, cli DockerAPI, containerID string, execConfig container.ExecOptions, streams execIO, timeout time.Duration) (*commandResult, error) {
+	// A terminal echoes its input and turns closing it into nothing the command sees as EOF
+	if execConfig.Tty && streams.Stdin != nil {
+		return nil, fmt.Errorf("stdin can't be combined with tty")
+	}
+	if execConfig.Tty {
+		size := ttyConsoleSize
+		execConfig.ConsoleSize = &size
+	}
+
+	// Tag the exec with a unique marker so its process tree can be found again on timeout
+	marker, err := newExecMarker()
+	if err != nil {
+		return nil, err
+	}
+	execConfig.AttachStdout = true
+	execConfig.AttachStderr = true
+	execConfig.AttachStdin = streams.Stdin != nil
+	execConfig.Env = append(execConfig.Env, execMarkerEnv+"="+marker)
 
 	// Create the exec configuration
-	exec, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
-		Cmd:          []string{"sh", "-c", cmd},
-		AttachStdout: true,
-		AttachStderr: true,
-	})
+	exec, err := cli.ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to create exec: %w", err)
+		return nil, fmt.Errorf("failed to create exec: %w", err)
 	}
 
+	// Attaching starts the command, so an OOM kill from here on is the command's
+	attached := time.Now()
 	// Attach to the exec instance to get output
-	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{Tty: execConfig.Tty})
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to attach to exec: %w", err)
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
 	}
 	defer resp.Close()
 
-	// Read the output
-	var stdoutBuf, stderrBuf strings.Builder
-	_, err = stdcopy.StdCopy(&stdoutBuf, &stderrBuf, resp.Reader)
+	if streams.Stdin != nil {
+		go func() {
+			// Errors surface through the command's exit code, e.g. tar reporting a truncated archive
+			_, _ = io.Copy(resp.Conn, streams.Stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
+	start := time.Now()
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Read the output in the background so the timeout can interrupt a blocked read
+	var stdoutBuf, stderrBuf outputBuffer = &strings.Builder{}, &strings.Builder{}
+	if streams.MaxOutputBytes > 0 {
+		stdoutBuf = &headWriter{limit: streams.MaxOutputBytes}
+		stderrBuf = &headWriter{limit: streams.MaxOutputBytes}
+	}
+	var stdout, stderr io.Writer = stdoutBuf, stderrBuf
+	if streams.OnLine != nil {
+		stdoutLines := &lineWriter{stream: "stdout", onLine: streams.OnLine}
+		stderrLines := &lineWriter{stream: "stderr", onLine: streams.OnLine}
+		defer stdoutLines.Flush()
+		defer stderrLines.Flush()
+		stdout = io.MultiWriter(stdout, stdoutLines)
+		stderr = io.MultiWriter(stderr, stderrLines)
+	}
+	copyDone := make(chan error, 1)
+	go func() {
+		// A TTY stream isn't multiplexed: stdout and stderr arrive mixed, with the terminal's line endings
+		if execConfig.Tty {
+			crlf := &crlfWriter{w: stdout}
+			_, err := io.Copy(crlf, resp.Reader)
+			crlf.Flush()
+			copyDone <- err
+			return
+		}
+		_, err := stdcopy.StdCopy(stdout, stderr, resp.Reader)
+		copyDone <- err
+	}()
+
+	// stop kills the command's processes once the timeout expired or the caller gave up
+	stop := func() (*commandResult, error) {
+		killExecProcesses(cli, containerID, execConfig.User, marker)
+		if ctx.Err() != nil {
+			logger.Debug("exec cancelled", "container_id", containerID, "duration", time.Since(start))
+			return nil, ctx.Err()
+		}
+		execDuration.Observe(time.Since(start))
+		logger.Warn("exec timed out", "container_id", containerID, "timeout", timeout)
+		return nil, fmt.Errorf("execution timed out after %g seconds", timeout.Seconds())
+	}
+
+	select {
+	case err := <-copyDone:
+		if err != nil {
+			return nil, fmt.Errorf("failed to read command output: %w", err)
+		}
+	case <-execCtx.Done():
+		// Closing the hijacked connection unblocks the reader goroutine
+		resp.Close()
+		<-copyDone
+		return stop()
+	}
+
+	// Get the exit code. A non-zero exit is a normal result, not an error. The output closes
+	// before the command exits when it hands its streams to nothing, e.g. a daemon that closed
+	// them, so the timeout can still expire here.
+	exitCode, err := waitForExecExit(execCtx, cli, exec.ID)
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to read command output: %w", err)
+		if execCtx.Err() != nil {
+			return stop()
+		}
+		return nil, err
+	}
+
+	result := &commandResult{
+		Stdout:   stdoutBuf.String(),
+		Stderr:   stderrBuf.String(),
+		ExitCode: exitCode,
+		Tty:      execConfig.Tty,
+	}
+	if exitCode == oomExitCode {
+		if note := execOOMNote(ctx, cli, containerID, attached); note != "" {
+			result.OOMKilled, result.Note = true, note
+			logger.Warn("exec killed for exceeding the memory limit", "container_id", containerID)
+		}
+	}
+	execDuration.Observe(time.Since(start))
+	logger.Debug("exec finished", "container_id", containerID, "exit_code", exitCode, "duration", time.Since(start))
+	return result, nil
+}
+
+// outputBuffer collects one output stream of an exec
+type outputBuffer interface {
+	io.Writer
+	String() string
+}
+
+// lineWriter splits the output written to it into lines and hands each complete line to onLine
+type lineWriter struct {
+	stream  string
+	onLine  func(stream string, line string)
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(w.stream, string(w.pending[:i+1]))
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush hands any trailing output that didn't end in a newline to onLine
+func (w *lineWriter) Flush() {
+	if len(w.pending) > 0 {
+		w.onLine(w.stream, string(w.pending))
+		w.pending = nil
+	}
+}
+
+// crlfWriter turns the \r\n line endings of a terminal back into \n. A \r on its own, as used
+// by progress bars, is kept.
+type crlfWriter struct {
+	w io.Writer
+	// cr is set when the last byte written was a \r that may start a \r\n split across writes
+	cr bool
+}
+
+func (w *crlfWriter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p)+1)
+	if w.cr {
+		if len(p) == 0 || p[0] != '\n' {
+			out = append(out, '\r')
+		}
+		w.cr = false
+	}
+	for i, b := range p {
+		if b == '\r' {
+			if i == len(p)-1 {
+				w.cr = true
+				continue
+			}
+			if p[i+1] == '\n' {
+				continue
+			}
+		}
+		out = append(out, b)
+	}
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing \r held back by Write
+func (w *crlfWriter) Flush() {
+	if w.cr {
+		_, _ = w.w.Write([]byte{'\r'})
+		w.cr = false
 	}
+}
+
+// waitForExecExit polls an exec instance until it has finished and returns its exit code.
+// The attached output stream can reach EOF slightly before the daemon records the
+// exit status, so a single inspect call may still report the process as running.
+func waitForExecExit(ctx context.Context, cli DockerAPI, execID string) (int, error) {
+	for {
+		inspect, err := cli.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return -1, fmt.Errorf("failed to inspect exec: %w", err)
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
 
-	// Get the exit code
-	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+		// Small sleep to avoid hammering the Docker API
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// execMarkerEnv is the environment variable used to tag the processes started by an exec
+const execMarkerEnv = "CODE_SANDBOX_EXEC_ID"
+
+// newExecMarker returns a random identifier for tagging an exec's processes
+func newExecMarker() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate exec marker: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// killExecProcesses kills every process in the container whose environment carries the exec marker.
+// Child processes inherit the marker, so this stops the whole process tree started by the exec.
+// It is best effort: failures are ignored because the caller is already reporting an error.
+func killExecProcesses(cli DockerAPI, containerID string, user string, marker string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	script := fmt.Sprintf(
+		`for p in /proc/[0-9]*; do `+
+			`if tr '\0' '\n' 2>/dev/null < "$p/environ" | grep -qx '%s=%s'; then kill -9 "${p#/proc/}" 2>/dev/null; fi; `+
+			`done`,
+		execMarkerEnv, marker,
+	)
+	exec, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:  []string{"sh", "-c", script},
+		User: user,
+	})
 	if err != nil {
-		return "", "", -1, fmt.Errorf("failed to inspect exec: %w", err)
+		return
 	}
+	if err := cli.ContainerExecStart(ctx, exec.ID, container.ExecStartOptions{}); err != nil {
+		return
+	}
+	_, _ = waitForExecExit(ctx, cli, exec.ID)
+}
 
-	return stdoutBuf.String(), stderrBuf.String(), inspect.ExitCode, nil
-}
\ No newline at end of file
+// parseTimeoutSeconds reads an optional timeout argument given in seconds, falling back to def when unset
+func parseTimeoutSeconds(args map[string]interface{}, key string, def time.Duration) (time.Duration, error) {
+	secs, err := positiveNumberArg(args, key, def.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
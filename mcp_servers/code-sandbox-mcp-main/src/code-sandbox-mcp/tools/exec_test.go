@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// shellExitFake answers `sh -c 'exit N'`-style commands the way a shell would: exitCodes maps
+// each script to its exit code, and every command prints its script to stdout
+func shellExitFake(exitCodes map[string]int) *fakeDocker {
+	f := newFakeDocker()
+	f.onExec = func(_ string, opts container.ExecOptions, _ string) fakeExecResult {
+		script := strings.Join(opts.Cmd, " ")
+		if len(opts.Cmd) == 3 && opts.Cmd[0] == "sh" && opts.Cmd[1] == "-c" {
+			script = opts.Cmd[2]
+		}
+		return fakeExecResult{Stdout: "ran " + script + "\n", ExitCode: exitCodes[script]}
+	}
+	return f
+}
+
+func TestRunCommandReportsExitCode(t *testing.T) {
+	f := shellExitFake(map[string]int{"exit 3": 3})
+	useFakeDocker(t, f)
+	id := f.addContainer("exit-code", nil)
+
+	var result commandResult
+	decodeResult(t, RunCommand, map[string]interface{}{"container_id": id, "command": "exit 3"}, &result)
+
+	if result.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", result.ExitCode)
+	}
+	// A failing program is a normal result, so its output is still returned
+	if result.Stdout != "ran exit 3\n" {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+	if got := f.execsRunning("sh"); len(got) != 1 || got[0].Cmd[2] != "exit 3" {
+		t.Errorf("execs = %v, want one sh -c 'exit 3'", got)
+	}
+}
+
+// TestRunCommandTimesOutAfterOutputCloses checks that a command which closed its output but keeps
+// running is killed and reported as timed out, like one still printing
+func TestRunCommandTimesOutAfterOutputCloses(t *testing.T) {
+	f := newFakeDocker()
+	f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+		return fakeExecResult{KeepRunning: opts.Cmd[2] == "daemon"}
+	}
+	useFakeDocker(t, f)
+	id := f.addContainer("closed-output", nil)
+
+	text := callTool(t, RunCommand, map[string]interface{}{"container_id": id, "command": "daemon", "timeout_seconds": 0.2}, true)
+	if !strings.Contains(text, "execution timed out after 0.2 seconds") {
+		t.Errorf("error = %q, want a timeout", text)
+	}
+	var kills int
+	for _, opts := range f.execOptions {
+		if opts.Cmd[0] == "sh" && strings.Contains(opts.Cmd[2], "kill -9") && strings.Contains(opts.Cmd[2], execMarkerEnv) {
+			kills++
+		}
+	}
+	if kills != 1 {
+		t.Errorf("%d kill commands ran, want 1", kills)
+	}
+}
+
+func TestExecStopsAtFailingCommand(t *testing.T) {
+	f := shellExitFake(map[string]int{"exit 3": 3})
+	useFakeDocker(t, f)
+	id := f.addContainer("exec-stop", nil)
+
+	text := callTool(t, Exec, map[string]interface{}{
+		"container_id": id,
+		"commands":     []interface{}{"echo ok", "exit 3", "echo never"},
+	}, false)
+
+	if !strings.Contains(text, "Command exited with code 3") {
+		t.Errorf("output doesn't report the exit code:\n%s", text)
+	}
+	if strings.Contains(text, "never") {
+		t.Errorf("commands after the failing one ran:\n%s", text)
+	}
+}
+
+// TestExecUserAndWorkdirOverride checks that user and workdir are sent with a single command only,
+// leaving the sandbox's own user and working directory to later commands
+func TestExecUserAndWorkdirOverride(t *testing.T) {
+	f := newFakeDocker()
+	id, _ := initializeSandbox(t, f, map[string]interface{}{})
+
+	tests := []struct {
+		name        string
+		args        map[string]interface{}
+		wantUser    string
+		wantWorkdir string
+	}{
+		{"overridden user", map[string]interface{}{"user": "root"}, "root", ""},
+		{"overridden uid", map[string]interface{}{"user": "65534:65534"}, "65534:65534", ""},
+		{"overridden workdir", map[string]interface{}{"workdir": "/tmp"}, "", "/tmp"},
+		{"relative workdir", map[string]interface{}{"workdir": "src"}, "", "/app/src"},
+		// Empty fields leave the container's user and working directory to the daemon
+		{"sandbox user and workdir", map[string]interface{}{}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args["container_id"] = id
+			tt.args["command"] = "true"
+			callTool(t, RunCommand, tt.args, false)
+			opts := f.execOptions[len(f.execOptions)-1]
+			if opts.User != tt.wantUser || opts.WorkingDir != tt.wantWorkdir {
+				t.Errorf("exec ran as %q in %q, want %q in %q", opts.User, opts.WorkingDir, tt.wantUser, tt.wantWorkdir)
+			}
+		})
+	}
+}
+
+func TestExecUserOverrideRejectsMalformedUser(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("bad-user", nil)
+	for _, user := range []interface{}{"root user", ":1000", "a:b:c", 0.0} {
+		callTool(t, RunCommand, map[string]interface{}{"container_id": id, "command": "whoami", "user": user}, true)
+	}
+	if len(f.execOptions) != 0 {
+		t.Errorf("commands ran despite a malformed user: %v", f.execOptions)
+	}
+}
+
+// TestExecEnvOverride checks that env is sent with a single command, for Docker to put on top of
+// the sandbox's environment, and leaves the container and later commands unaffected
+func TestExecEnvOverride(t *testing.T) {
+	f := newFakeDocker()
+	id, created := initializeSandbox(t, f, map[string]interface{}{"env": map[string]interface{}{"MODE": "prod", "API_URL": "http://api.test"}})
+
+	lastEnv := func() []string {
+		return f.execOptions[len(f.execOptions)-1].Env
+	}
+	callTool(t, RunCommand, map[string]interface{}{"container_id": id, "command": "true", "env": map[string]interface{}{"MODE": "debug", "DEBUG": "1"}}, false)
+	if env := lastEnv(); !contains(env, "MODE=debug") || !contains(env, "DEBUG=1") {
+		t.Errorf("with overrides: Env = %v", env)
+	}
+
+	callTool(t, RunCommand, map[string]interface{}{"container_id": id, "command": "true"}, false)
+	for _, env := range lastEnv() {
+		if !strings.HasPrefix(env, execMarkerEnv+"=") {
+			t.Errorf("after the override: Env holds %s", env)
+		}
+	}
+	if contains(created.Config.Env, "DEBUG=1") || !contains(created.Config.Env, "MODE=prod") {
+		t.Errorf("the override reached the container's environment: %v", created.Config.Env)
+	}
+
+	callTool(t, Exec, map[string]interface{}{
+		"container_id": id,
+		"commands":     []interface{}{"true"},
+		"env":          []interface{}{"MODE=test"},
+	}, false)
+	if env := lastEnv(); !contains(env, "MODE=test") {
+		t.Errorf("exec tool: Env = %v, want the override", env)
+	}
+}
@@ -0,0 +1,521 @@
+package tools
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mark3labs/mcp-go/mcp"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeDocker is an in-memory DockerAPI for tests. It keeps the containers it created and records
+// the calls made to it. Methods it doesn't implement panic through the nil embedded interface,
+// so a test notices when the code under test starts using one.
+type fakeDocker struct {
+	DockerAPI
+
+	mu         sync.Mutex
+	containers map[string]*container.InspectResponse
+	execs      map[string]*fakeExec
+	nextID     int
+
+	creates []fakeCreate
+	starts  []string
+	stops   []string
+	removes []fakeRemove
+	copies  []string
+	// dirs records the owner and mode of the directories copied into each container, by path
+	dirs map[string]map[string]*tar.Header
+	// execOptions records the options of every exec, in order
+	execOptions []container.ExecOptions
+
+	// createErrs, startErrs and removeErrs are returned by the next calls to ContainerCreate,
+	// ContainerStart and ContainerRemove, one per call, before they succeed again
+	createErrs []error
+	startErrs  []error
+	removeErrs []error
+	// createdDespiteErr makes ContainerCreate create the container even when it returns an error
+	// from createErrs, like a daemon whose reply was lost
+	createdDespiteErr bool
+	pingErr           error
+	info              system.Info
+	// clockOffset is how far the daemon's clock is ahead of the test's, for SystemTime and events
+	clockOffset time.Duration
+	// oomEvents records the oom events of the containers, stamped with the daemon's clock
+	oomEvents []events.Message
+	version   types.Version
+	// onExec decides what an exec prints and exits with; nil runs every command successfully
+	onExec func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult
+	closed bool
+}
+
+// fakeCreate is a recorded ContainerCreate call
+type fakeCreate struct {
+	Config     *container.Config
+	HostConfig *container.HostConfig
+	Networking *network.NetworkingConfig
+	Platform   *ocispec.Platform
+	Name       string
+}
+
+// fakeRemove is a recorded ContainerRemove call
+type fakeRemove struct {
+	ContainerID string
+	Options     container.RemoveOptions
+}
+
+// fakeExecResult is what a fake exec writes to its streams and exits with
+type fakeExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// KeepRunning leaves the exec running after its output closed, like a command that closed
+	// its streams and carries on
+	KeepRunning bool
+}
+
+// fakeExec is an exec instance of the fake daemon
+type fakeExec struct {
+	containerID string
+	opts        container.ExecOptions
+	running     bool
+	exitCode    int
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		containers: make(map[string]*container.InspectResponse),
+		execs:      make(map[string]*fakeExec),
+		dirs:       make(map[string]map[string]*tar.Header),
+		info:       system.Info{Driver: "overlay2", Runtimes: map[string]system.RuntimeWithStatus{"runc": {}}},
+		version:    types.Version{Version: "27.0.0", APIVersion: "1.47"},
+	}
+}
+
+// useFakeDocker makes f the shared Docker client for the duration of the test
+func useFakeDocker(t *testing.T, f *fakeDocker) {
+	t.Helper()
+	SetDockerClient(f)
+	t.Cleanup(func() {
+		dockerMu.Lock()
+		dockerCli = nil
+		dockerMu.Unlock()
+	})
+}
+
+// addContainer registers a running managed container, returning its full ID
+func (f *fakeDocker) addContainer(name string, labels map[string]string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if labels == nil {
+		labels = managedLabels("")
+	}
+	id := f.newID()
+	f.containers[id] = &container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         id,
+			Name:       "/" + name,
+			State:      &container.State{Running: true, Status: "running"},
+			HostConfig: &container.HostConfig{},
+		},
+		Config: &container.Config{Labels: labels, WorkingDir: "/app"},
+	}
+	return id
+}
+
+func (f *fakeDocker) newID() string {
+	f.nextID++
+	return fmt.Sprintf("%064x", 0xfa4e0000+f.nextID)
+}
+
+// lookup finds a container by ID, ID prefix or name. The caller must hold f.mu.
+func (f *fakeDocker) lookup(ref string) (*container.InspectResponse, error) {
+	for id, c := range f.containers {
+		if strings.HasPrefix(id, ref) || c.Name == "/"+ref {
+			return c, nil
+		}
+	}
+	return nil, errdefs.NotFound(fmt.Errorf("No such container: %s", ref))
+}
+
+func (f *fakeDocker) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{APIVersion: f.version.APIVersion}, f.pingErr
+}
+
+func (f *fakeDocker) Info(ctx context.Context) (system.Info, error) {
+	info := f.info
+	info.SystemTime = f.daemonNow().Format(time.RFC3339Nano)
+	return info, nil
+}
+
+// daemonNow is the current time on the daemon's clock
+func (f *fakeDocker) daemonNow() time.Time {
+	return time.Now().Add(f.clockOffset)
+}
+
+// oomKill records the kernel OOM-killing a process of a container: an oom event now, and the
+// container's OOM flag, which stays set like Docker's
+func (f *fakeDocker) oomKill(containerID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.daemonNow()
+	f.oomEvents = append(f.oomEvents, events.Message{
+		Type:     events.ContainerEventType,
+		Action:   events.ActionOOM,
+		Actor:    events.Actor{ID: containerID},
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	})
+	f.containers[containerID].State.OOMKilled = true
+}
+
+// Events replays the recorded oom events between since and until that match the filters, then
+// ends the stream the way the daemon does once until has passed
+func (f *fakeDocker) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	parse := func(ts string) int64 {
+		sec, nsec, _ := strings.Cut(ts, ".")
+		s, _ := strconv.ParseInt(sec, 10, 64)
+		n, _ := strconv.ParseInt(nsec, 10, 64)
+		return s*int64(time.Second) + n
+	}
+	since, until := parse(options.Since), parse(options.Until)
+
+	f.mu.Lock()
+	var matched []events.Message
+	for _, msg := range f.oomEvents {
+		if msg.TimeNano < since || (options.Until != "" && msg.TimeNano > until) {
+			continue
+		}
+		if !options.Filters.ExactMatch("type", string(msg.Type)) || !options.Filters.ExactMatch("event", string(msg.Action)) ||
+			!options.Filters.ExactMatch("container", msg.Actor.ID) {
+			continue
+		}
+		matched = append(matched, msg)
+	}
+	f.mu.Unlock()
+
+	messages := make(chan events.Message)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		for _, msg := range matched {
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+		errs <- io.EOF
+	}()
+	return messages, errs
+}
+
+func (f *fakeDocker) ServerVersion(ctx context.Context) (types.Version, error) {
+	return f.version, nil
+}
+
+func (f *fakeDocker) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeDocker) ImageInspectWithRaw(ctx context.Context, ref string) (image.InspectResponse, []byte, error) {
+	return image.InspectResponse{ID: "sha256:" + strings.Repeat("ab", 32), Os: "linux", Architecture: runtime.GOARCH}, nil, nil
+}
+
+func (f *fakeDocker) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.creates = append(f.creates, fakeCreate{Config: config, HostConfig: hostConfig, Networking: networkingConfig, Platform: platform, Name: containerName})
+
+	var err error
+	if len(f.createErrs) > 0 {
+		err, f.createErrs = f.createErrs[0], f.createErrs[1:]
+		if !f.createdDespiteErr {
+			return container.CreateResponse{}, err
+		}
+	}
+	if containerName != "" {
+		if _, lookupErr := f.lookup(containerName); lookupErr == nil {
+			return container.CreateResponse{}, errdefs.Conflict(fmt.Errorf("the container name %q is already in use", "/"+containerName))
+		}
+	}
+	id := f.newID()
+	f.containers[id] = &container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         id,
+			Name:       "/" + containerName,
+			State:      &container.State{Status: "created"},
+			HostConfig: hostConfig,
+		},
+		Config: config,
+	}
+	if err != nil {
+		return container.CreateResponse{}, err
+	}
+	return container.CreateResponse{ID: id}, nil
+}
+
+func (f *fakeDocker) ContainerStart(ctx context.Context, ref string, options container.StartOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup(ref)
+	if err != nil {
+		return err
+	}
+	f.starts = append(f.starts, c.ID)
+	if len(f.startErrs) > 0 {
+		err, f.startErrs = f.startErrs[0], f.startErrs[1:]
+		return err
+	}
+	c.State.Running, c.State.Status = true, "running"
+	return nil
+}
+
+func (f *fakeDocker) ContainerStop(ctx context.Context, ref string, options container.StopOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup(ref)
+	if err != nil {
+		return err
+	}
+	f.stops = append(f.stops, c.ID)
+	c.State.Running, c.State.Status = false, "exited"
+	return nil
+}
+
+func (f *fakeDocker) ContainerRemove(ctx context.Context, ref string, options container.RemoveOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removes = append(f.removes, fakeRemove{ContainerID: ref, Options: options})
+	if len(f.removeErrs) > 0 {
+		var err error
+		err, f.removeErrs = f.removeErrs[0], f.removeErrs[1:]
+		return err
+	}
+	c, err := f.lookup(ref)
+	if err != nil {
+		return err
+	}
+	delete(f.containers, c.ID)
+	return nil
+}
+
+func (f *fakeDocker) ContainerInspect(ctx context.Context, ref string) (container.InspectResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup(ref)
+	if err != nil {
+		return container.InspectResponse{}, err
+	}
+	return *c, nil
+}
+
+func (f *fakeDocker) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []container.Summary
+	for id, c := range f.containers {
+		if options.Filters.Len() > 0 && !options.Filters.MatchKVList("label", c.Config.Labels) {
+			continue
+		}
+		list = append(list, container.Summary{ID: id, Names: []string{c.Name}, Labels: c.Config.Labels, State: c.State.Status})
+	}
+	return list, nil
+}
+
+func (f *fakeDocker) CopyToContainer(ctx context.Context, ref, path string, content io.Reader, options container.CopyToContainerOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.copies = append(f.copies, path)
+	c, err := f.lookup(ref)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errdefs.InvalidParameter(err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			if f.dirs[c.ID] == nil {
+				f.dirs[c.ID] = make(map[string]*tar.Header)
+			}
+			f.dirs[c.ID][strings.TrimSuffix(filepath.Join(path, hdr.Name), "/")] = hdr
+		}
+	}
+}
+
+func (f *fakeDocker) ContainerExecCreate(ctx context.Context, ref string, options container.ExecOptions) (container.ExecCreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup(ref)
+	if err != nil {
+		return container.ExecCreateResponse{}, err
+	}
+	if !c.State.Running {
+		return container.ExecCreateResponse{}, errdefs.Conflict(fmt.Errorf("container %s is not running", ref))
+	}
+	f.execOptions = append(f.execOptions, options)
+	id := fmt.Sprintf("exec%d", len(f.execOptions))
+	f.execs[id] = &fakeExec{containerID: c.ID, opts: options, running: true}
+	return container.ExecCreateResponse{ID: id}, nil
+}
+
+// runExec decides the result of an exec and records its exit code
+func (f *fakeDocker) runExec(e *fakeExec, stdin string) fakeExecResult {
+	result := fakeExecResult{}
+	if f.onExec != nil {
+		result = f.onExec(e.containerID, e.opts, stdin)
+	}
+	f.mu.Lock()
+	e.running, e.exitCode = result.KeepRunning, result.ExitCode
+	f.mu.Unlock()
+	return result
+}
+
+func (f *fakeDocker) ContainerExecStart(ctx context.Context, execID string, options container.ExecStartOptions) error {
+	f.mu.Lock()
+	e, ok := f.execs[execID]
+	f.mu.Unlock()
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("No such exec instance: %s", execID))
+	}
+	f.runExec(e, "")
+	return nil
+}
+
+func (f *fakeDocker) ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error) {
+	f.mu.Lock()
+	e, ok := f.execs[execID]
+	f.mu.Unlock()
+	if !ok {
+		return types.HijackedResponse{}, errdefs.NotFound(fmt.Errorf("No such exec instance: %s", execID))
+	}
+
+	resp, server := newFakeConn()
+	go func() {
+		defer server.stdout.Close()
+		var stdin []byte
+		if e.opts.AttachStdin {
+			stdin, _ = io.ReadAll(server.stdin)
+		}
+		result := f.runExec(e, string(stdin))
+		if options.Tty {
+			_, _ = io.WriteString(server.stdout, result.Stdout)
+			return
+		}
+		_, _ = io.WriteString(stdcopy.NewStdWriter(server.stdout, stdcopy.Stdout), result.Stdout)
+		_, _ = io.WriteString(stdcopy.NewStdWriter(server.stdout, stdcopy.Stderr), result.Stderr)
+	}()
+	return resp, nil
+}
+
+func (f *fakeDocker) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.execs[execID]
+	if !ok {
+		return container.ExecInspect{}, errdefs.NotFound(fmt.Errorf("No such exec instance: %s", execID))
+	}
+	return container.ExecInspect{ExecID: execID, ContainerID: e.containerID, Running: e.running, ExitCode: e.exitCode}, nil
+}
+
+// execsRunning returns the commands of the recorded execs whose first argument is name
+func (f *fakeDocker) execsRunning(name string) []container.ExecOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []container.ExecOptions
+	for _, opts := range f.execOptions {
+		if len(opts.Cmd) > 0 && opts.Cmd[0] == name {
+			found = append(found, opts)
+		}
+	}
+	return found
+}
+
+// fakeConn is the client end of a hijacked exec connection: reads return what the exec writes
+// and writes go to its stdin, which CloseWrite ends like a half-closed socket
+type fakeConn struct {
+	net.Conn
+	out *io.PipeReader
+	in  *io.PipeWriter
+}
+
+// fakeConnServer is the exec's end of a fakeConn
+type fakeConnServer struct {
+	stdin  *io.PipeReader
+	stdout *io.PipeWriter
+}
+
+// newFakeConn returns a hijacked response backed by a fakeConn, and the exec's end of it
+func newFakeConn() (types.HijackedResponse, *fakeConnServer) {
+	outR, outW := io.Pipe()
+	inR, inW := io.Pipe()
+	conn := &fakeConn{out: outR, in: inW}
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, &fakeConnServer{stdin: inR, stdout: outW}
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)  { return c.out.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error) { return c.in.Write(p) }
+func (c *fakeConn) CloseWrite() error           { return c.in.Close() }
+
+func (c *fakeConn) Close() error {
+	_ = c.in.Close()
+	return c.out.Close()
+}
+
+// callTool calls a tool handler with args and returns the text of its result, failing the test
+// when the handler reports an error unless wantError is set
+func callTool(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]interface{}, wantError bool) string {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Arguments = args
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := mcp.AsTextContent(result.Content[0])
+	if text == nil {
+		t.Fatalf("result has no text content: %#v", result.Content)
+	}
+	if result.IsError != wantError {
+		t.Fatalf("IsError = %v, want %v: %s", result.IsError, wantError, text.Text)
+	}
+	return text.Text
+}
+
+// decodeResult calls a tool handler that returns JSON and decodes its result into v
+func decodeResult(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]interface{}, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(callTool(t, handler, args, false)), v); err != nil {
+		t.Fatal(err)
+	}
+}
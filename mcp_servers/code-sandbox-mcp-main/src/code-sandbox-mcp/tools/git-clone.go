@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultCloneTimeout bounds how long a clone may take when no timeout is requested
+const defaultCloneTimeout = 5 * time.Minute
+
+// gitCloneScript clones $1 into $2, checking out ref $3 when given, optionally with history
+// limited to $4 commits, and prints the commit checked out. A ref is fetched explicitly rather
+// than passed to --branch, so commit hashes work as well as branches and tags.
+const gitCloneScript = `set -e
+url=$1 target=$2 ref=$3 depth=$4
+if [ -z "$ref" ]; then
+	git clone ${depth:+--depth "$depth"} -- "$url" "$target"
+else
+	git init -q "$target"
+	git -C "$target" remote add origin "$url"
+	git -C "$target" fetch ${depth:+--depth "$depth"} origin "$ref"
+	git -C "$target" checkout -q FETCH_HEAD
+fi
+git -C "$target" rev-parse HEAD`
+
+var (
+	// gitURLPattern accepts network URLs and scp-like ssh addresses such as git@github.com:org/repo.git.
+	// Local paths and transports such as ext:: that run commands are not accepted.
+	gitURLPattern = regexp.MustCompile(`^(https?|git|ssh)://[^\s]+$|^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^\s]+$`)
+	// gitCommitPattern matches the commit hash printed at the end of the clone
+	gitCommitPattern = regexp.MustCompile(`([0-9a-f]{40}|[0-9a-f]{64})\s*$`)
+)
+
+// cloneResult is the structured result of cloning a repository into a container
+type cloneResult struct {
+	RepoURL  string `json:"repo_url"`
+	Ref      string `json:"ref,omitempty"`
+	Path     string `json:"path"`
+	Commit   string `json:"commit,omitempty"`
+	Success  bool   `json:"success"`
+	ExitCode int    `json:"exit_code"`
+	Log      string `json:"log"`
+}
+
+// GitClone clones a git repository into a running container
+func GitClone(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	repoURL, ok := request.Params.Arguments["repo_url"].(string)
+	if !ok || repoURL == "" {
+		return newToolResultError("repo_url is required"), nil
+	}
+	if !gitURLPattern.MatchString(repoURL) {
+		return newToolResultError(fmt.Sprintf("invalid repo_url %q, use an https://, http://, git:// or ssh:// URL or user@host:path", repoURL)), nil
+	}
+
+	ref, _ := request.Params.Arguments["ref"].(string)
+	if strings.HasPrefix(ref, "-") {
+		return newToolResultError(fmt.Sprintf("invalid ref %q", ref)), nil
+	}
+
+	depth := ""
+	if request.Params.Arguments["depth"] != nil {
+		d, err := positiveNumberArg(request.Params.Arguments, "depth", 0)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		depth = strconv.Itoa(int(d))
+	}
+
+	// Default to a directory named after the repository in the working directory
+	target, _ := request.Params.Arguments["target_path"].(string)
+	if target == "" {
+		target = strings.TrimSuffix(path.Base(strings.TrimRight(repoURL, "/")), ".git")
+	}
+	target, err = resolveContainerPath(ctx, containerID, target)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving target_path: %v", err)), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultCloneTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := gitCloneInContainer(ctx, containerID, repoURL, ref, depth, target, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error cloning repository: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// gitCloneInContainer makes sure git can reach the network and is installed, installing it with
+// apt in root sandboxes that lack it, and runs the clone
+func gitCloneInContainer(ctx context.Context, containerID string, repoURL string, ref string, depth string, target string, timeout time.Duration) (*cloneResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if info.HostConfig.NetworkMode.IsNone() {
+		return nil, fmt.Errorf("%w (network: none); "+
+			"create it with network set to bridge or a named network to clone repositories", ErrNetworkDisabled)
+	}
+
+	lookup, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", "command -v git"},
+	}, execIO{}, defaultExecTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if lookup.ExitCode != 0 {
+		if !isRootUser(info.Config.User) {
+			return nil, fmt.Errorf("git is not available in image %s; use an image with git, or a sandbox created with run_as_root so it can be installed", info.Config.Image)
+		}
+		install, err := installPackagesInContainer(ctx, containerID, "apt", packageManagers["apt"], []string{"git", "ca-certificates"}, defaultInstallTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("git is not available in image %s and installing it failed: %w", info.Config.Image, err)
+		}
+		if !install.Success {
+			return nil, fmt.Errorf("git is not available in image %s and installing it failed with exit code %d: %s", info.Config.Image, install.ExitCode, strings.TrimSpace(install.Log))
+		}
+	}
+
+	// Every value is a positional parameter of the script, so none of them is parsed by the shell
+	output, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", gitCloneScript, "sh", repoURL, target, ref, depth},
+		// Never wait for credentials on a terminal that nobody is looking at
+		Env: []string{"GIT_TERMINAL_PROMPT=0"},
+	}, execIO{}, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cloneResult{
+		RepoURL:  repoURL,
+		Ref:      ref,
+		Path:     target,
+		Success:  output.ExitCode == 0,
+		ExitCode: output.ExitCode,
+		Log:      output.Stdout + output.Stderr,
+	}
+	if m := gitCommitPattern.FindStringSubmatch(output.Stdout); result.Success && m != nil {
+		result.Commit = m[1]
+	}
+	return result, nil
+}
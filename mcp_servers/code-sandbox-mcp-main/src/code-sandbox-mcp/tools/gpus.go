@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+)
+
+// parseGPUs converts the gpus argument into the device request docker run --gpus would make:
+// "all", a number of GPUs, or specific devices as "device=0,1" or GPU UUIDs. Unset means no GPU.
+func parseGPUs(arg interface{}) (*container.DeviceRequest, error) {
+	request := &container.DeviceRequest{
+		Driver:       "nvidia",
+		Capabilities: [][]string{{"gpu"}},
+	}
+
+	switch v := arg.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if v < 1 || v != float64(int(v)) {
+			return nil, fmt.Errorf("gpus must be a positive whole number, \"all\" or \"device=<ids>\"")
+		}
+		request.Count = int(v)
+	case string:
+		v = strings.TrimSpace(v)
+		if ids, ok := strings.CutPrefix(v, "device="); ok {
+			for _, id := range strings.Split(ids, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					request.DeviceIDs = append(request.DeviceIDs, id)
+				}
+			}
+			if len(request.DeviceIDs) == 0 {
+				return nil, fmt.Errorf("gpus device= needs at least one device index or UUID")
+			}
+		} else if v == "all" {
+			// A count of -1 is how the Docker API spells all GPUs
+			request.Count = -1
+		} else if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			request.Count = n
+		} else {
+			return nil, fmt.Errorf("invalid gpus %q, must be \"all\", a count or \"device=<ids>\"", v)
+		}
+	default:
+		return nil, fmt.Errorf("gpus must be \"all\", a count or \"device=<ids>\"")
+	}
+	return request, nil
+}
+
+// checkGPUSupport makes sure the daemon can hand GPUs to containers, which takes the NVIDIA
+// Container Toolkit registering its runtime, so a missing driver fails here with a clear error
+// instead of deep inside container start
+func checkGPUSupport(ctx context.Context, cli DockerAPI) error {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query the Docker daemon's runtimes: %w", err)
+	}
+	return gpuSupport(info, containerEngine(ctx, cli))
+}
+
+// gpuSupport explains why the daemon described by info can't pass GPUs through, or returns nil if it can.
+// Podman has no nvidia runtime: it injects GPUs from the CDI specs the NVIDIA Container Toolkit
+// generates, which the API doesn't report, so a missing spec only shows at create time there.
+func gpuSupport(info system.Info, engine string) error {
+	if engine == enginePodman {
+		return nil
+	}
+	if _, ok := info.Runtimes["nvidia"]; !ok {
+		return fmt.Errorf("gpus was requested but the Docker daemon has no nvidia runtime; " +
+			"install the NVIDIA Container Toolkit on the Docker host and configure it with nvidia-ctk runtime configure")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// allowedImages holds the images clients may run, read once at startup from CODE_SANDBOX_ALLOWED_IMAGES.
+// When restrictImages is false the variable was unset or empty and every image is allowed.
+var allowedImages, restrictImages = envImageList("CODE_SANDBOX_ALLOWED_IMAGES")
+
+// envImageList reads a comma-separated list of image references from the named environment variable
+// and reports whether the variable was set. Malformed entries are reported and skipped, so a typo
+// narrows the allowlist rather than opening it up.
+func envImageList(name string) ([]reference.Named, bool) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return nil, false
+	}
+
+	var refs []reference.Named
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ref, err := reference.ParseNormalizedNamed(entry)
+		if err != nil {
+			logger.Warn("ignoring invalid image in allowlist", "name", name, "image", entry, "error", err)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		logger.Warn("allowlist contains no valid images, so no image is allowed", "name", name)
+	}
+	return refs, true
+}
+
+// checkImageAllowed returns an error listing the permitted images when image isn't on the allowlist.
+// Allowlist entries without a tag or digest permit every tag of that repository; the others must match exactly.
+func checkImageAllowed(image string) error {
+	if !restrictImages {
+		return nil
+	}
+
+	ref, err := parseImageReference(image)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range allowedImages {
+		if imageMatches(allowed, ref) {
+			return nil
+		}
+	}
+
+	permitted := "none"
+	if len(allowedImages) > 0 {
+		permitted = strings.Join(AllowedImages(), ", ")
+	}
+	return fmt.Errorf("image %s is not allowed on this server, permitted images: %s", image, permitted)
+}
+
+// imageMatches reports whether ref is permitted by the allowlist entry allowed.
+// A reference without a tag means :latest, as it does for docker pull.
+func imageMatches(allowed, ref reference.Named) bool {
+	if allowed.Name() != ref.Name() {
+		return false
+	}
+	if reference.IsNameOnly(allowed) {
+		return true
+	}
+	return reference.TagNameOnly(allowed).String() == reference.TagNameOnly(ref).String()
+}
+
+// AllowedImages returns the allowlist in its familiar form, e.g. "python:3.12", or nil when every image is allowed
+func AllowedImages() []string {
+	if !restrictImages {
+		return nil
+	}
+	images := make([]string, 0, len(allowedImages))
+	for _, ref := range allowedImages {
+		images = append(images, reference.FamiliarString(ref))
+	}
+	return images
+}
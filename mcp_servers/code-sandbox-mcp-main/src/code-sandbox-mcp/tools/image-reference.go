@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// normalizeImage validates an image reference with Docker's reference parser and returns it in
+// its familiar form with the implied :latest tag made explicit, so docker.io/library/ubuntu and
+// ubuntu both become ubuntu:latest. References with a digest are kept as they are.
+func normalizeImage(image string) (string, error) {
+	ref, err := parseImageReference(image)
+	if err != nil {
+		return "", err
+	}
+	return reference.FamiliarString(reference.TagNameOnly(ref)), nil
+}
+
+// parseImageReference parses an image reference such as python:3.12 or
+// registry.internal/team/app@sha256:..., explaining the usual mistakes when it is malformed
+func parseImageReference(image string) (reference.Named, error) {
+	if strings.TrimSpace(image) == "" {
+		return nil, fmt.Errorf("invalid image reference: the image must not be empty")
+	}
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err == nil {
+		return ref, nil
+	}
+
+	hint := ""
+	switch {
+	case strings.Contains(image, "://"):
+		hint = "; leave out the scheme, e.g. registry.internal/app:1.0 instead of https://registry.internal/app:1.0"
+	case strings.ContainsAny(image, " \t\n"):
+		hint = "; references must not contain whitespace"
+	}
+	return nil, fmt.Errorf("invalid image reference %q: %v%s", image, err, hint)
+}
@@ -1,83 +1,1197 @@
 package tools
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/errdefs"
 	"github.com/mark3labs/mcp-go/mcp"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+const (
+	// defaultMemoryMB is the memory limit applied when neither the caller nor CODE_SANDBOX_DEFAULT_MEMORY_MB sets one
+	defaultMemoryMB = 512
+	// defaultCPULimit is the number of CPUs a sandbox may use when neither the caller nor
+	// CODE_SANDBOX_DEFAULT_CPU_LIMIT sets a limit
+	defaultCPULimit = 1.0
+	// defaultPidsLimit caps the number of processes in a sandbox so a fork bomb can't exhaust the
+	// host, unless CODE_SANDBOX_DEFAULT_PIDS_LIMIT sets another cap
+	defaultPidsLimit = 256
+	// defaultWorkdir is the working directory code runs in when the caller doesn't choose one
+	defaultWorkdir = "/app"
+	// defaultWorkdirMode is the permission mode of the working directory when the caller doesn't choose one
+	defaultWorkdirMode = 0755
+	// defaultNetwork keeps sandboxes offline unless network access is explicitly requested
+	defaultNetwork = "none"
+	// defaultReadyTimeout is how long a new sandbox may take to accept exec commands
+	defaultReadyTimeout = 10 * time.Second
+	// defaultTmpfsSizeMB is the size limit of the /tmp tmpfs, and of the working directory with a read-only root filesystem
+	defaultTmpfsSizeMB = 64
+
+	// sandboxUID and sandboxGID are the unprivileged user and group code runs as unless run_as_root is set
+	sandboxUID = 1000
+	sandboxGID = 1000
+)
+
+// containerOptions holds the settings used to create a sandbox container
+type containerOptions struct {
+	Name      string
+	Image     string
+	Workdir   string
+	AllowPull bool
+	MemoryMB  float64
+	CPULimit  float64
+	PidsLimit int64
+	Ulimits   []*container.Ulimit
+	RunAsRoot bool
+	CapAdd    []string
+	Network   string
+	Runtime   string
+	Seccomp   string
+	Env       []string
+	Mounts    []mount.Mount
+	// Networks are the user-defined networks the sandbox joins besides Network, reachable under
+	// NetworkAliases as well as its name and ID
+	Networks       []string
+	NetworkAliases []string
+	// DNS and ExtraHosts set the sandbox's DNS servers and extra /etc/hosts entries; both are
+	// dropped for network none
+	DNS        []string
+	ExtraHosts []string
+	// WorkdirMode is the permission mode of the working directory; zero keeps the default, and
+	// leaves the directory of a run_as_root sandbox as the daemon created it
+	WorkdirMode int64
+	// SessionID attributes the sandbox to a client session, recorded in clientSessionLabel
+	SessionID string
+	// Metadata is stored in labels under metadataLabelPrefix, so it lasts as long as the container
+	Metadata map[string]string
+	// AutoRemove has Docker delete the container as soon as it stops
+	AutoRemove bool
+	// RestartPolicy has Docker restart the container when its main process exits; the zero value
+	// never restarts it
+	RestartPolicy container.RestartPolicy
+	// GPUs requests GPU devices from the nvidia driver; nil means the sandbox gets none
+	GPUs *container.DeviceRequest
+	// StorageMB caps the size of the writable container layer; zero leaves it unlimited
+	StorageMB float64
+	// Platform selects the image variant and runs it, under emulation if it isn't the host's;
+	// nil uses the host's platform
+	Platform *ocispec.Platform
+	// IdleTimeout has the reaper remove the sandbox after this long without tool activity instead
+	// of after CODE_SANDBOX_IDLE_TTL; zero keeps the server-wide setting
+	IdleTimeout time.Duration
+	// Cmd and Entrypoint replace the default `sleep infinity` and the image's entrypoint.
+	// UseImageCmd runs the image's own command instead of either.
+	Cmd         []string
+	Entrypoint  []string
+	UseImageCmd bool
+	// RunOnce runs the command to completion instead of keeping the sandbox alive for exec
+	RunOnce bool
+
+	ReadonlyRootfs bool
+	TmpfsTmp       bool
+	TmpfsSizeMB    float64
+	// Secrets maps file names in /run/secrets to their contents, written after the sandbox starts
+	Secrets map[string]string
+	// Warnings are reported in the result, e.g. for options ignored because of other options
+	Warnings []string
+	// OnPullProgress, when set, receives progress updates while a missing image is pulled
+	OnPullProgress func(pullProgress)
+}
+
+// initializeResult is the structured result of creating a sandbox
+type initializeResult struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+	Image       string `json:"image"`
+	ImageDigest string `json:"image_digest,omitempty"`
+	Status      string `json:"status"`
+	// Warnings name the options that were ignored
+	Warnings []string `json:"warnings,omitempty"`
+}
+
 // InitializeEnvironment creates a new container for code execution
 func InitializeEnvironment(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	opts, err := parseContainerOptions(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Pull progress is only reported when the client asked for progress updates
+	if request.Params.Meta != nil && request.Params.Meta.ProgressToken != nil {
+		opts.OnPullProgress = pullProgressNotifier(ctx, request.Params.Meta.ProgressToken)
+	}
+
+	// A dry run only reports what would be created, so callers can fail fast on bad options
+	if dryRun, _ := request.Params.Arguments["dry_run"].(bool); dryRun {
+		result, err := validateContainer(ctx, opts)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+		}
+		return newToolResultJSON(result)
+	}
+
+	if opts.RunOnce {
+		return runOnce(ctx, request, opts)
+	}
+
+	// Hand out a warm pool sandbox when the options match, or create and start the container
+	containerId, pooled := takePooledContainer(ctx, opts)
+	if !pooled {
+		if containerId, err = createContainer(ctx, opts); err != nil {
+			return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+
+	touchContainer(containerId)
+
+	// The digest pins the exact image the sandbox runs, whether it was pulled now or earlier
+	digest := ""
+	if cli, err := DockerClient(); err == nil {
+		digest = imageDigest(ctx, cli, opts.Image)
+	}
+
+	// The summary keeps the "container_id: <id>" line that existing clients look for
+	return newToolResultJSONWithSummary(initializeResult{
+		ContainerID: containerId,
+		Name:        opts.Name,
+		SessionID:   opts.SessionID,
+		Image:       opts.Image,
+		ImageDigest: digest,
+		Status:      "running",
+		Warnings:    opts.Warnings,
+	}, fmt.Sprintf("container_id: %s", containerId))
+}
+
+// parseContainerOptions reads the container settings from the tool arguments, applying defaults for anything unset
+func parseContainerOptions(args map[string]interface{}) (*containerOptions, error) {
+	// A security preset only fills in the options the caller left unset
+	given := args
+	args, err := applySecurityPreset(args)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the requested Docker image or use default
-	image, ok := request.Params.Arguments["image"].(string)
+	image, ok := args["image"].(string)
 	if !ok || image == "" {
-		// Default to a slim debian image with Python pre-installed
-		image = "python:3.12-slim-bookworm"
+		// Default to the configured image, a slim debian image with Python unless overridden
+		image = defaultImage
+	}
+	// Garbage is rejected here rather than surfacing as a confusing error from the image inspect
+	if image, err = normalizeImage(image); err != nil {
+		return nil, err
+	}
+
+	// Sandboxes without a name get a random one from Docker and are referred to by ID
+	name, err := parseContainerName(args["name"])
+	if err != nil {
+		return nil, err
+	}
+
+	workdir, err := parseWorkdir(args["workdir"])
+	if err != nil {
+		return nil, err
+	}
+	workdirMode, err := parseWorkdirMode(args["workdir_mode"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Pulling is opt-in so offline and air-gapped setups keep working unchanged
+	allowPull, _ := args["allow_pull"].(bool)
+
+	memoryMB, err := positiveNumberArg(args, "memory_mb", defaultResources.MemoryMB)
+	if err != nil {
+		return nil, err
+	}
+
+	cpuLimit, err := positiveNumberArg(args, "cpu_limit", defaultResources.CPULimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// A limit below one would be truncated to zero, which Docker treats as unlimited
+	pidsLimit, err := positiveNumberArg(args, "pids_limit", float64(defaultResources.PidsLimit))
+	if err != nil {
+		return nil, err
+	}
+	if pidsLimit < 1 {
+		return nil, fmt.Errorf("pids_limit must be at least 1")
+	}
+	if err := checkResourceMaximums(memoryMB, cpuLimit, int64(pidsLimit)); err != nil {
+		return nil, err
+	}
+
+	// File descriptor and other rlimits, independent of the pids limit above
+	ulimits, err := parseUlimits(args["ulimits"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Code runs as an unprivileged user unless root is explicitly requested
+	runAsRoot, _ := args["run_as_root"].(bool)
+
+	capAdd, err := parseCapabilities(args["cap_add"])
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := parseNetwork(args["network"])
+	if err != nil {
+		return nil, err
+	}
+	networks, networkAliases, err := parseNetworks(args)
+	if err != nil {
+		return nil, err
+	}
+	// Joining networks implies network access: without an explicit network the sandbox is created
+	// on the first one, and an explicit none contradicts them. A preset's network isn't explicit.
+	if len(networks) > 0 {
+		if given["network"] == nil {
+			network = networks[0]
+		} else if network == "none" {
+			return nil, fmt.Errorf("network none can't be combined with networks")
+		}
+	}
+
+	// Without a network the sandbox has nothing to resolve names for, so the settings are dropped
+	// instead of failing the request
+	dns, extraHosts, err := parseNameResolution(args)
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	if network == "none" && (dns != nil || extraHosts != nil) {
+		logger.Warn("ignoring dns and extra_hosts of a sandbox without network", "dns", len(dns), "extra_hosts", len(extraHosts))
+		warnings = append(warnings, "dns and extra_hosts were ignored because the sandbox has network none")
+		dns, extraHosts = nil, nil
+	}
+
+	// An empty runtime leaves the choice to the daemon's default, normally runc
+	runtime, ok := args["runtime"].(string)
+	if !ok && args["runtime"] != nil {
+		return nil, fmt.Errorf("runtime must be a string")
+	}
+	runtime = strings.TrimSpace(runtime)
+
+	seccomp, err := parseSeccompProfile(args["seccomp_profile"])
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := parseEnv(args["env"])
+	if err != nil {
+		return nil, err
+	}
+	// Variables from env_file come first so the explicit env entries override them
+	fileEnv, err := parseEnvFile(args["env_file"])
+	if err != nil {
+		return nil, err
+	}
+	env = mergeEnv(fileEnv, env)
+
+	mounts, err := parseMounts(args["mounts"])
+	if err != nil {
+		return nil, err
+	}
+	volumeMounts, err := parseVolumeMounts(args["volumes"])
+	if err != nil {
+		return nil, err
+	}
+	mounts = append(mounts, volumeMounts...)
+
+	readonlyRootfs, _ := args["readonly_rootfs"].(bool)
+
+	// Scratch files in /tmp go to a size-capped tmpfs instead of the container layer unless disabled
+	tmpfsTmp := true
+	if v, ok := args["tmpfs_tmp"].(bool); ok {
+		tmpfsTmp = v
+	}
+
+	tmpfsSizeMB, err := positiveNumberArg(args, "tmpfs_size_mb", defaultTmpfsSizeMB)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := parseSessionID(args["session_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := parseMetadata(args["metadata"])
+	if err != nil {
+		return nil, err
+	}
+
+	autoRemove, _ := args["auto_remove"].(bool)
+
+	restartPolicy, err := parseRestartPolicy(args["restart_policy"])
+	if err != nil {
+		return nil, err
+	}
+	// Docker refuses both, since a container can't be restarted once it has been removed
+	if autoRemove && !restartPolicy.IsNone() {
+		return nil, fmt.Errorf("restart_policy %s can't be combined with auto_remove: an auto-removed sandbox is deleted when it stops, so it can never be restarted", restartPolicy.Name)
+	}
+
+	storageMB, err := positiveNumberArg(args, "storage_mb", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	idleTimeout, err := positiveNumberArg(args, "idle_timeout_seconds", 0)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create and start the container
-	containerId, err := createContainer(ctx, image)
+	platform, err := parsePlatform(args["platform"])
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+		return nil, err
+	}
+
+	gpus, err := parseGPUs(args["gpus"])
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd, entrypoint []string
+	if args["cmd"] != nil {
+		if cmd, err = parseCommandArgument(args["cmd"]); err != nil {
+			return nil, fmt.Errorf("invalid cmd: %w", err)
+		}
+	}
+	if args["entrypoint"] != nil {
+		if entrypoint, err = parseEntrypoint(args["entrypoint"]); err != nil {
+			return nil, err
+		}
+	}
+	useImageCmd, _ := args["use_image_cmd"].(bool)
+	if useImageCmd && cmd != nil {
+		return nil, fmt.Errorf("cmd and use_image_cmd can't be combined")
+	}
+	runOnce, _ := args["run_once"].(bool)
+	if runOnce && cmd == nil && !useImageCmd {
+		return nil, fmt.Errorf("run_once needs a cmd to run, or use_image_cmd")
+	}
+	if runOnce && !restartPolicy.IsNone() {
+		return nil, fmt.Errorf("restart_policy %s can't be combined with run_once, which runs the command exactly once", restartPolicy.Name)
+	}
+
+	// Secrets are written once the sandbox runs, which a one-shot command wouldn't wait for
+	secrets, err := parseSecrets(args["secrets"])
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) > 0 {
+		if runOnce {
+			return nil, fmt.Errorf("secrets can't be combined with run_once, whose command would start before they are written")
+		}
+		if err := checkSecretsMounts(mounts); err != nil {
+			return nil, err
+		}
+	}
+
+	return &containerOptions{
+		Name:           name,
+		Image:          image,
+		Workdir:        workdir,
+		WorkdirMode:    workdirMode,
+		AllowPull:      allowPull,
+		MemoryMB:       memoryMB,
+		CPULimit:       cpuLimit,
+		PidsLimit:      int64(pidsLimit),
+		Ulimits:        ulimits,
+		RunAsRoot:      runAsRoot,
+		CapAdd:         capAdd,
+		Network:        network,
+		Networks:       networks,
+		NetworkAliases: networkAliases,
+		DNS:            dns,
+		ExtraHosts:     extraHosts,
+		Runtime:        runtime,
+		Seccomp:        seccomp,
+		Env:            env,
+		Mounts:         mounts,
+		SessionID:      sessionID,
+		Metadata:       metadata,
+		AutoRemove:     autoRemove,
+		RestartPolicy:  restartPolicy,
+		StorageMB:      storageMB,
+		IdleTimeout:    time.Duration(idleTimeout * float64(time.Second)),
+		Platform:       platform,
+		GPUs:           gpus,
+		Cmd:            cmd,
+		Entrypoint:     entrypoint,
+		UseImageCmd:    useImageCmd,
+		RunOnce:        runOnce,
+		ReadonlyRootfs: readonlyRootfs,
+		TmpfsTmp:       tmpfsTmp,
+		TmpfsSizeMB:    tmpfsSizeMB,
+		Secrets:        secrets,
+		Warnings:       warnings,
+	}, nil
+}
+
+// sandboxLabels returns the labels of a new sandbox: the managed labels, its own idle timeout if it
+// has one, and its metadata
+func sandboxLabels(opts *containerOptions) map[string]string {
+	labels := managedLabels(opts.SessionID)
+	if opts.IdleTimeout > 0 {
+		labels[idleTimeoutLabel] = strconv.FormatInt(int64(max(opts.IdleTimeout.Seconds(), 1)), 10)
+	}
+	for key, value := range opts.Metadata {
+		labels[metadataLabelPrefix+key] = value
+	}
+	return labels
+}
+
+// parseWorkdirMode validates the workdir_mode argument, an octal permission mode such as "0770".
+// The owner must keep full access, or the sandbox couldn't use its own working directory.
+func parseWorkdirMode(arg interface{}) (int64, error) {
+	if arg == nil {
+		return 0, nil
+	}
+	s, ok := arg.(string)
+	if !ok {
+		return 0, fmt.Errorf("workdir_mode must be an octal string such as \"0755\"")
+	}
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseInt(s, 8, 64)
+	if err != nil || mode < 0 || mode > 07777 {
+		return 0, fmt.Errorf("workdir_mode must be an octal permission mode between 0000 and 7777, got %q", s)
+	}
+	if mode&0700 != 0700 {
+		return 0, fmt.Errorf("workdir_mode %q must give the owner read, write and execute permission", s)
+	}
+	return mode, nil
+}
+
+// workdirMode returns the permission mode the working directory is given
+func (opts *containerOptions) workdirMode() int64 {
+	if opts.WorkdirMode == 0 {
+		return defaultWorkdirMode
+	}
+	return opts.WorkdirMode
+}
+
+// parseEntrypoint validates the entrypoint argument: a single executable, or an array of the
+// executable and its leading arguments. An empty array clears the image's entrypoint.
+func parseEntrypoint(arg interface{}) ([]string, error) {
+	switch v := arg.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("entrypoint must not be empty")
+		}
+		return []string{v}, nil
+	case []interface{}:
+		// Docker only resets the image's entrypoint when given a single empty string
+		entrypoint := []string{""}
+		if len(v) > 0 {
+			entrypoint = make([]string, 0, len(v))
+		}
+		for _, part := range v {
+			partStr, ok := part.(string)
+			if !ok {
+				return nil, fmt.Errorf("each element of entrypoint must be a string")
+			}
+			entrypoint = append(entrypoint, partStr)
+		}
+		return entrypoint, nil
+	default:
+		return nil, fmt.Errorf("entrypoint must be a string or an array of strings")
+	}
+}
+
+// parseWorkdir validates the workdir argument, which must be an absolute path inside the container
+func parseWorkdir(arg interface{}) (string, error) {
+	if arg == nil {
+		return defaultWorkdir, nil
+	}
+	workdir, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("workdir must be a string")
+	}
+	if workdir == "" {
+		return defaultWorkdir, nil
+	}
+	if !path.IsAbs(workdir) {
+		return "", fmt.Errorf("workdir must be an absolute path such as /workspace, got %q", workdir)
+	}
+	return path.Clean(workdir), nil
+}
+
+// parseCapabilities validates the cap_add argument and normalizes each entry to its
+// upper-case name without the CAP_ prefix, e.g. "cap_net_raw" becomes "NET_RAW"
+func parseCapabilities(arg interface{}) ([]string, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cap_add must be an array of capability names")
+	}
+
+	caps := make([]string, 0, len(list))
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("each cap_add entry must be a string")
+		}
+		name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "CAP_")
+		if !knownCapabilities[name] {
+			return nil, fmt.Errorf("unknown capability %q in cap_add", name)
+		}
+		caps = append(caps, name)
+	}
+	return caps, nil
+}
+
+// parseNetwork validates the network argument, which is "none", "bridge" or the name of an existing
+// Docker network. Modes that share a network namespace with the host or another container are rejected.
+func parseNetwork(arg interface{}) (string, error) {
+	if arg == nil {
+		return defaultNetwork, nil
+	}
+	network, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("network must be a string")
+	}
+	network = strings.TrimSpace(network)
+	if network == "" {
+		return defaultNetwork, nil
+	}
+	if network == "host" || strings.HasPrefix(network, "container:") {
+		return "", fmt.Errorf("network %q is not allowed, use none, bridge or a named network", network)
+	}
+	return network, nil
+}
+
+// parseEnv converts the env argument, either an object of names to values or an array of
+// KEY=VALUE strings, into the KEY=VALUE list Docker expects
+func parseEnv(arg interface{}) ([]string, error) {
+	var env []string
+	switch v := arg.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		// Sort so the container config doesn't depend on map iteration order
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, ok := v[name].(string)
+			if !ok {
+				return nil, fmt.Errorf("env value for %s must be a string", name)
+			}
+			env = append(env, name+"="+value)
+		}
+	case []interface{}:
+		for _, item := range v {
+			entry, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("each env entry must be a KEY=VALUE string")
+			}
+			if !strings.Contains(entry, "=") {
+				return nil, fmt.Errorf("env entry %q must be in KEY=VALUE form", entry)
+			}
+			env = append(env, entry)
+		}
+	default:
+		return nil, fmt.Errorf("env must be an object or an array of KEY=VALUE strings")
+	}
+
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if name == "" {
+			return nil, fmt.Errorf("env entry %q has an empty name", entry)
+		}
+		if strings.ContainsAny(name, " \t\n") {
+			return nil, fmt.Errorf("env name %q must not contain whitespace", name)
+		}
+	}
+	return env, nil
+}
+
+// parseMounts converts the mounts argument, a list of host_path:container_path[:ro|rw] strings,
+// into bind mounts. Mounts are read-only unless rw is given so sandboxed code can't modify host files by default.
+func parseMounts(arg interface{}) ([]mount.Mount, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mounts must be an array of host_path:container_path[:ro|rw] strings")
+	}
+
+	mounts := make([]mount.Mount, 0, len(list))
+	for _, item := range list {
+		spec, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("each mounts entry must be a string")
+		}
+
+		// Parse from the right so Windows host paths such as C:\src keep their drive letter
+		rest, readOnly := spec, true
+		if i := strings.LastIndex(rest, ":"); i >= 0 {
+			switch rest[i+1:] {
+			case "ro":
+				rest = rest[:i]
+			case "rw":
+				rest, readOnly = rest[:i], false
+			}
+		}
+		i := strings.LastIndex(rest, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("mount %q must be in host_path:container_path[:ro|rw] form", spec)
+		}
+		hostPath, containerPath := rest[:i], rest[i+1:]
+
+		if !filepath.IsAbs(hostPath) {
+			return nil, fmt.Errorf("mount %q: host path must be absolute", spec)
+		}
+		if !path.IsAbs(containerPath) {
+			return nil, fmt.Errorf("mount %q: container path must be absolute", spec)
+		}
+
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   filepath.Clean(hostPath),
+			Target:   path.Clean(containerPath),
+			ReadOnly: readOnly,
+		})
+	}
+	return mounts, nil
+}
+
+// isMountTarget reports whether one of the mounts is mounted at target
+func isMountTarget(mounts []mount.Mount, target string) bool {
+	for _, m := range mounts {
+		if m.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEnv reports whether a KEY=VALUE list sets the named variable
+func hasEnv(env []string, name string) bool {
+	for _, entry := range env {
+		if strings.HasPrefix(entry, name+"=") {
+			return true
+		}
 	}
+	return false
+}
 
-	return mcp.NewToolResultText(fmt.Sprintf("container_id: %s", containerId)), nil
+// knownCapabilities lists the Linux capabilities that may be requested through cap_add
+var knownCapabilities = map[string]bool{
+	"AUDIT_CONTROL": true, "AUDIT_READ": true, "AUDIT_WRITE": true, "BLOCK_SUSPEND": true,
+	"BPF": true, "CHECKPOINT_RESTORE": true, "CHOWN": true, "DAC_OVERRIDE": true,
+	"DAC_READ_SEARCH": true, "FOWNER": true, "FSETID": true, "IPC_LOCK": true,
+	"IPC_OWNER": true, "KILL": true, "LEASE": true, "LINUX_IMMUTABLE": true,
+	"MAC_ADMIN": true, "MAC_OVERRIDE": true, "MKNOD": true, "NET_ADMIN": true,
+	"NET_BIND_SERVICE": true, "NET_BROADCAST": true, "NET_RAW": true, "PERFMON": true,
+	"SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true,
+	"SYSLOG": true, "SYS_ADMIN": true, "SYS_BOOT": true, "SYS_CHROOT": true,
+	"SYS_MODULE": true, "SYS_NICE": true, "SYS_PACCT": true, "SYS_PTRACE": true,
+	"SYS_RAWIO": true, "SYS_RESOURCE": true, "SYS_TIME": true, "SYS_TTY_CONFIG": true,
+	"WAKE_ALARM": true,
 }
 
 // createContainer creates a new Docker container and returns its ID
-func createContainer(ctx context.Context, image string) (string, error) {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
+func createContainer(ctx context.Context, opts *containerOptions) (string, error) {
+	start := time.Now()
+	id, err := setUpContainer(ctx, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to create Docker client: %w", err)
+		containersFailed.Inc()
+		logger.Error("failed to create container", "image", opts.Image, "name", opts.Name, "duration", time.Since(start), "error", err)
+		return "", err
 	}
-	defer cli.Close()
+	containersCreated.Inc()
+	logger.Info("container started", "container_id", id, "image", opts.Image, "name", opts.Name, "duration", time.Since(start))
+	return id, nil
+}
 
-	// Ensure the image exists locally. We intentionally avoid any network pull here
+// setUpContainer creates, prepares and starts a container, removing it again if any step fails
+func setUpContainer(ctx context.Context, opts *containerOptions) (string, error) {
+	cli, err := preflightContainer(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	// Ensure the image exists locally. By default we avoid any network pull here
 	// to guarantee we only use pre-loaded images (offline or air-gapped environments).
-	// If the image is missing, return a clear error so the caller can handle it.
-	_, _, err = cli.ImageInspectWithRaw(ctx, image)
+	// If the image is missing and pulling wasn't requested, return a clear error so the caller can handle it.
+	present, err := findImage(ctx, cli, opts)
+	if err != nil {
+		return "", err
+	}
+	if !present {
+		if err := pullImage(ctx, cli, opts.Image, opts.Platform, opts.OnPullProgress); err != nil {
+			return "", err
+		}
+	}
+
+	config, hostConfig := containerConfig(opts)
+	createID, err := newExecMarker()
+	if err != nil {
+		return "", err
+	}
+	config.Labels[createIDLabel] = createID
+
+	// Create the container. A create that failed with a transient error may have created the
+	// container anyway, e.g. when the daemon's reply was lost, so a retry adopts that container
+	// instead of failing on its name or leaving it behind.
+	var resp container.CreateResponse
+	attempts := 0
+	err = withDockerRetry(ctx, "create container", func() error {
+		if attempts++; attempts > 1 {
+			id, err := findCreatedContainer(ctx, cli, createID)
+			if err != nil || id != "" {
+				resp.ID = id
+				return err
+			}
+		}
+		var err error
+		resp, err = cli.ContainerCreate(
+			ctx,
+			config,
+			hostConfig,
+			networkingConfig(opts),
+			opts.Platform,
+			opts.Name,
+		)
+		return err
+	})
+	if err != nil {
+		if errdefs.IsConflict(err) {
+			return "", fmt.Errorf("the name %s is already in use by another container, choose a different name or stop that sandbox first", opts.Name)
+		}
+		// overlay2 only enforces quotas on xfs mounted with pquota, which can't be seen in advance
+		if opts.StorageMB > 0 && strings.Contains(err.Error(), "storage-opt") {
+			return "", fmt.Errorf("storage_mb is not supported by the Docker daemon's storage setup: %w", err)
+		}
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	logger.Debug("container created", "container_id", resp.ID, "image", opts.Image)
+
+	// A sandbox that can't be set up is of no use to anyone, so don't leave it behind
+	ready := false
+	defer func() {
+		if !ready {
+			discardContainer(resp.ID)
+		}
+	}()
+
+	// The daemon creates the working directory as root, so hand it over to the sandbox user,
+	// or only set its mode for a root sandbox that asked for one. A tmpfs working directory is
+	// mounted with the right owner and mode instead, and a bind-mounted one is left alone so the
+	// ownership of host files never changes.
+	if (!opts.RunAsRoot || opts.WorkdirMode != 0) && !opts.ReadonlyRootfs && !isMountTarget(opts.Mounts, config.WorkingDir) {
+		uid, gid := sandboxUID, sandboxGID
+		if opts.RunAsRoot {
+			uid, gid = 0, 0
+		}
+		if err := chownWorkdir(ctx, cli, resp.ID, config.WorkingDir, uid, gid, opts.workdirMode()); err != nil {
+			return "", err
+		}
+	}
+	// A new volume is owned by root too, so writable ones are handed over the same way. Only the
+	// volume's top directory changes, and files an earlier sandbox wrote keep their owner.
+	if !opts.RunAsRoot {
+		for _, m := range opts.Mounts {
+			if m.Type != mount.TypeVolume || m.ReadOnly {
+				continue
+			}
+			if err := chownWorkdir(ctx, cli, resp.ID, m.Target, sandboxUID, sandboxGID, defaultWorkdirMode); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := connectNetworks(ctx, cli, resp.ID, opts); err != nil {
+		return "", err
+	}
+
+	// Start the container
+	if err := withDockerRetry(ctx, "start container", func() error {
+		return cli.ContainerStart(ctx, resp.ID, container.StartOptions{})
+	}); err != nil {
+		if opts.Platform != nil && isExecFormatError(err) {
+			return "", emulationError(opts.Platform, err)
+		}
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	// Only hand out the sandbox once it runs commands, so the next tool call can't race its startup.
+	// A one-shot command may already have finished, and nothing is exec'd into it anyway.
+	if !opts.RunOnce {
+		if err := waitUntilReady(ctx, cli, resp.ID, envDuration("CODE_SANDBOX_READY_TIMEOUT", defaultReadyTimeout)); err != nil {
+			if opts.Platform != nil && isExecFormatError(err) {
+				return "", emulationError(opts.Platform, err)
+			}
+			return "", err
+		}
+	}
+
+	if len(opts.Secrets) > 0 {
+		if err := writeSecrets(ctx, cli, resp.ID, opts.Secrets); err != nil {
+			return "", err
+		}
+	}
+
+	ready = true
+	return resp.ID, nil
+}
+
+// findCreatedContainer returns the ID of the container created with createID, or "" if there is none
+func findCreatedContainer(ctx context.Context, cli DockerAPI, createID string) (string, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", createIDLabel+"="+createID)),
+	})
 	if err != nil {
-		return "", fmt.Errorf("docker image %s not found locally. Please build or load it before initializing a sandbox", image)
+		return "", fmt.Errorf("failed to look for a container created by an earlier attempt: %w", err)
+	}
+	if len(containers) == 0 {
+		return "", nil
+	}
+	logger.Info("found the container created by a failed create", "container_id", containers[0].ID)
+	return containers[0].ID, nil
+}
+
+// waitUntilReady runs `true` in the container until it succeeds, retrying briefly while the
+// container is still starting up, and gives up with the last failure once timeout has passed
+func waitUntilReady(ctx context.Context, cli DockerAPI, containerID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+			Cmd: []string{"true"},
+		}, execIO{}, 2*time.Second)
+		if err == nil && result.ExitCode == 0 {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("readiness probe exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("sandbox did not become ready within %s: %w", timeout, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// preflightContainer runs the checks that don't depend on the image being present: the image
+// allowlist, and whether the daemon supports the requested runtime, quota, GPUs, network and volumes.
+// It returns the Docker client to create the sandbox with.
+func preflightContainer(ctx context.Context, opts *containerOptions) (DockerAPI, error) {
+	// Refuse images the operator hasn't permitted before touching Docker at all
+	if err := checkImageAllowed(opts.Image); err != nil {
+		return nil, err
+	}
+	// The allowlist names the images clients ask for; Docker only ever sees the mirrored reference
+	opts.Image = mirrorImage(opts.Image, registryMirror)
+
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Runtime != "" {
+		if err := checkRuntime(ctx, cli, opts.Runtime); err != nil {
+			return nil, err
+		}
+	}
+	if opts.StorageMB > 0 {
+		if err := checkStorageQuota(ctx, cli); err != nil {
+			return nil, err
+		}
+	}
+	if opts.GPUs != nil {
+		if err := checkGPUSupport(ctx, cli); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Platform != nil {
+		if err := checkPlatform(ctx, cli, opts.Platform); err != nil {
+			return nil, err
+		}
 	}
+	if err := checkNetwork(ctx, cli, opts.Network); err != nil {
+		return nil, err
+	}
+	if err := checkNetworks(ctx, cli, opts.Networks); err != nil {
+		return nil, err
+	}
+	if err := checkVolumeMounts(ctx, cli, opts.Mounts); err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
 
+// findImage reports whether the sandbox image is available locally, built for the requested platform
+// if there is one. A missing image is an error unless allow_pull lets it be pulled.
+func findImage(ctx context.Context, cli DockerAPI, opts *containerOptions) (bool, error) {
+	start := time.Now()
+	info, _, err := cli.ImageInspectWithRaw(ctx, opts.Image)
+	imageInspectDuration.Observe(time.Since(start))
+	switch {
+	case err == nil && (opts.Platform == nil || platformMatches(imagePlatform(info), opts.Platform)):
+		return true, nil
+	case opts.AllowPull:
+		return false, nil
+	case err == nil:
+		// The local image store keeps one platform per tag, so pulling replaces the local one
+		return false, fmt.Errorf("docker %w for platform %s: %s is only available for %s. Pull it for %s first, or set allow_pull",
+			ErrImageNotFoundLocally, platformString(opts.Platform), opts.Image, platformString(imagePlatform(info)), platformString(opts.Platform))
+	default:
+		return false, imageNotFoundError(opts.Image)
+	}
+}
+
+// containerConfig builds the container and host configuration of a sandbox from its options
+func containerConfig(opts *containerOptions) (*container.Config, *container.HostConfig) {
 	// Create container config with a working directory
 	config := &container.Config{
-		Image:      image,
-		WorkingDir: "/app",
+		Image:      opts.Image,
+		WorkingDir: opts.Workdir,
 		Tty:        true,
 		OpenStdin:  true,
 		StdinOnce:  false,
 		Cmd:        []string{"sleep", "infinity"}, // keep container alive for exec commands
+		Entrypoint: opts.Entrypoint,
+		Env:        opts.Env,
+		Labels:     sandboxLabels(opts),
+	}
+	switch {
+	case opts.UseImageCmd:
+		config.Cmd = nil
+	case opts.Cmd != nil:
+		config.Cmd = opts.Cmd
+	}
+	// A one-shot command's output is read back from the logs, which keep stdout and stderr apart
+	// only without a TTY
+	if opts.RunOnce {
+		config.Tty = false
+		config.OpenStdin = false
 	}
 
-	// Create host config
+	// Run as an unprivileged user by default. HOME points at the working directory so
+	// tools that write to the home directory (pip --user, caches) have somewhere to go,
+	// unless the caller sets HOME through env.
+	if !opts.RunAsRoot {
+		config.User = fmt.Sprintf("%d:%d", sandboxUID, sandboxGID)
+		if !hasEnv(config.Env, "HOME") {
+			// Append to a copy, since the options may be shared by concurrently created sandboxes
+			config.Env = append(append([]string(nil), config.Env...), "HOME="+config.WorkingDir)
+		}
+	}
+
+	// Create host config with resource limits so an untrusted workload can't exhaust the host.
+	// Swap is capped at the memory limit so the limit can't be bypassed by swapping, and the
+	// process count is capped so a fork bomb fails inside the sandbox instead of starving the host.
+	// Every capability is dropped and privilege escalation through setuid binaries is blocked;
+	// callers can add back specific capabilities with cap_add. Without a network argument the
+	// container gets no network interfaces besides loopback.
+	memoryBytes := int64(opts.MemoryMB * 1024 * 1024)
 	hostConfig := &container.HostConfig{
-		// Add any resource constraints here if needed
+		NetworkMode: container.NetworkMode(opts.Network),
+		DNS:         opts.DNS,
+		ExtraHosts:  opts.ExtraHosts,
+		Runtime:     opts.Runtime,
+		CapDrop:     []string{"ALL"},
+		CapAdd:      opts.CapAdd,
+		SecurityOpt: []string{"no-new-privileges"},
+		Mounts:      opts.Mounts,
+		Resources: container.Resources{
+			Memory:     memoryBytes,
+			MemorySwap: memoryBytes,
+			NanoCPUs:   int64(opts.CPULimit * 1e9),
+			PidsLimit:  &opts.PidsLimit,
+			Ulimits:    opts.Ulimits,
+		},
+	}
+
+	// A read-only root filesystem keeps code from tampering with the image. The working directory
+	// and /tmp become size-limited tmpfs mounts so programs still have somewhere to write.
+	hostConfig.ReadonlyRootfs = opts.ReadonlyRootfs
+	hostConfig.Tmpfs = tmpfsMounts(config.WorkingDir, opts)
+
+	// Unless cmd replaces it, the main process sleeps forever, so an auto-removed sandbox only goes
+	// away once it is stopped.
+	// A one-shot sandbox is removed after its logs have been read instead
+	hostConfig.AutoRemove = opts.AutoRemove && !opts.RunOnce
+	hostConfig.RestartPolicy = opts.RestartPolicy
+
+	// GPUs are only passed through when asked for, like docker run --gpus
+	if opts.GPUs != nil {
+		hostConfig.DeviceRequests = []container.DeviceRequest{*opts.GPUs}
+	}
+
+	// The quota covers the container layer only; tmpfs mounts have their own size limits
+	if opts.StorageMB > 0 {
+		hostConfig.StorageOpt = map[string]string{"size": fmt.Sprintf("%dk", max(int64(opts.StorageMB*1024), 1))}
 	}
 
-	// Create the container
-	resp, err := cli.ContainerCreate(
-		ctx,
-		config,
-		hostConfig,
-		nil,
-		nil,
-		"",
-	)
+	// Without a seccomp option the daemon applies its default profile
+	if opts.Seccomp != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+opts.Seccomp)
+	}
+	return config, hostConfig
+}
+
+// checkRuntime makes sure the daemon knows the requested OCI runtime, such as runsc for gVisor,
+// so a missing runtime is reported instead of the sandbox silently running under another one
+func checkRuntime(ctx context.Context, cli DockerAPI, runtime string) error {
+	info, err := cli.Info(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to create container: %w", err)
+		return fmt.Errorf("failed to query the Docker daemon's runtimes: %w", err)
+	}
+	if _, ok := info.Runtimes[runtime]; ok {
+		return nil
 	}
 
-	// Start the container
-	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", fmt.Errorf("failed to start container: %w", err)
+	available := make([]string, 0, len(info.Runtimes))
+	for name := range info.Runtimes {
+		available = append(available, name)
 	}
+	sort.Strings(available)
+	return fmt.Errorf("runtime %q is not available on the Docker daemon, available runtimes: %s", runtime, strings.Join(available, ", "))
+}
 
-	return resp.ID, nil
+// checkNetwork makes sure a named network exists, so a typo fails before anything is created
+func checkNetwork(ctx context.Context, cli DockerAPI, name string) error {
+	if name == "none" || name == "bridge" || name == "default" {
+		return nil
+	}
+	if _, err := cli.NetworkInspect(ctx, name, network.InspectOptions{}); err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("network %s does not exist on the Docker daemon", name)
+		}
+		return fmt.Errorf("failed to inspect network: %w", err)
+	}
+	return nil
+}
+
+// quotaStorageDrivers are the storage drivers that can limit the size of a container layer
+var quotaStorageDrivers = map[string]bool{"overlay2": true, "btrfs": true, "zfs": true, "devicemapper": true}
+
+// checkStorageQuota makes sure the daemon's storage driver can enforce storage_mb, so the limit is
+// never silently ignored
+func checkStorageQuota(ctx context.Context, cli DockerAPI) error {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query the Docker daemon's storage driver: %w", err)
+	}
+	return storageQuotaSupport(info, containerEngine(ctx, cli))
+}
+
+// storageQuotaSupport explains why the daemon described by info can't limit container layer sizes,
+// or returns nil if it can. overlay2 additionally needs an xfs backing filesystem, and so does
+// Podman's overlay driver, which is the only one it supports quotas on.
+func storageQuotaSupport(info system.Info, engine string) error {
+	if engine == enginePodman {
+		if info.Driver != "overlay" {
+			return fmt.Errorf("storage_mb is not supported by the Podman storage driver %q; it needs overlay on xfs with pquota", info.Driver)
+		}
+	} else if !quotaStorageDrivers[info.Driver] {
+		return fmt.Errorf("storage_mb is not supported by the Docker storage driver %q; it needs overlay2 on xfs with pquota, btrfs, zfs or devicemapper", info.Driver)
+	}
+	if info.Driver == "overlay2" || info.Driver == "overlay" {
+		for _, status := range info.DriverStatus {
+			if status[0] == "Backing Filesystem" && status[1] != "xfs" {
+				return fmt.Errorf("storage_mb is not supported by the storage driver %s on %s; it needs an xfs backing filesystem mounted with pquota", info.Driver, status[1])
+			}
+		}
+	}
+	return nil
+}
+
+// tmpfsMounts returns the tmpfs mounts of a sandbox: /tmp unless tmpfs_tmp is turned off, and with a
+// read-only root filesystem always /tmp and the working directory, plus /run/secrets for a sandbox
+// with secrets, or nil when there are none.
+// The working directory allows executing files so compiled programs and scripts can run from it,
+// while /tmp stays noexec as Docker mounts it by default.
+func tmpfsMounts(workdir string, opts *containerOptions) map[string]string {
+	// Sizes are given in KiB so fractional megabytes don't round down to size=0, which means unlimited
+	sizeKB := int64(opts.TmpfsSizeMB * 1024)
+	if sizeKB < 1 {
+		sizeKB = 1
+	}
+	size := fmt.Sprintf("size=%dk", sizeKB)
+
+	mounts := map[string]string{}
+	if opts.TmpfsTmp || opts.ReadonlyRootfs {
+		mounts["/tmp"] = "rw,noexec,nosuid," + size + ",mode=1777"
+	}
+	if opts.ReadonlyRootfs {
+		workdirOpts := "rw,exec," + size
+		if !opts.RunAsRoot {
+			workdirOpts += fmt.Sprintf(",uid=%d,gid=%d,mode=%04o", sandboxUID, sandboxGID, opts.workdirMode())
+		} else if opts.WorkdirMode != 0 {
+			workdirOpts += fmt.Sprintf(",mode=%04o", opts.WorkdirMode)
+		}
+		mounts[workdir] = workdirOpts
+	}
+	if len(opts.Secrets) > 0 {
+		mounts[secretsDir] = secretsTmpfsOptions(opts.RunAsRoot)
+	}
+	if len(mounts) == 0 {
+		return nil
+	}
+	return mounts
+}
+
+// chownWorkdir makes the working directory owned by the given user and gives it the given mode.
+// It copies a directory entry with the desired ownership over the existing directory, which the
+// daemon applies on extraction, so no privileged command has to run inside the container.
+func chownWorkdir(ctx context.Context, cli DockerAPI, containerID string, workdir string, uid int, gid int, mode int64) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     strings.TrimPrefix(workdir, "/") + "/",
+		Mode:     mode,
+		Uid:      uid,
+		Gid:      gid,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	if err := cli.CopyToContainer(ctx, containerID, "/", &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", workdir, err)
+	}
+	return nil
 }
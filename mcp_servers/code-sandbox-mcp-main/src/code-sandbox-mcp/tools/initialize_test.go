@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// initializeSandbox creates a sandbox through the initialize tool and returns its ID and the
+// create call
+func initializeSandbox(t *testing.T, f *fakeDocker, args map[string]interface{}) (string, fakeCreate) {
+	t.Helper()
+	useFakeDocker(t, f)
+	var result initializeResult
+	decodeResult(t, InitializeEnvironment, args, &result)
+	if len(f.creates) == 0 {
+		t.Fatal("no container was created")
+	}
+	created := f.creates[len(f.creates)-1]
+	if created.Config.Labels[createIDLabel] == "" {
+		t.Error("the sandbox has no create ID label")
+	}
+	return result.ContainerID, created
+}
+
+// TestSandboxHardening checks the settings every sandbox gets unless the caller loosens them
+func TestSandboxHardening(t *testing.T) {
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{})
+	host := created.HostConfig
+
+	if len(host.CapDrop) != 1 || host.CapDrop[0] != "ALL" {
+		t.Errorf("CapDrop = %v, want [ALL]", host.CapDrop)
+	}
+	if len(host.CapAdd) != 0 {
+		t.Errorf("CapAdd = %v, want none", host.CapAdd)
+	}
+	if !contains(host.SecurityOpt, "no-new-privileges") {
+		t.Errorf("SecurityOpt = %v, want no-new-privileges", host.SecurityOpt)
+	}
+	if host.PidsLimit == nil || *host.PidsLimit != defaultResources.PidsLimit {
+		t.Errorf("PidsLimit = %v, want %d", host.PidsLimit, defaultResources.PidsLimit)
+	}
+	if host.Memory == 0 || host.MemorySwap != host.Memory {
+		t.Errorf("Memory = %d, MemorySwap = %d, want swap capped at the memory limit", host.Memory, host.MemorySwap)
+	}
+	if host.Privileged {
+		t.Error("the sandbox is privileged")
+	}
+}
+
+// TestSandboxCapAdd checks that cap_add adds capabilities back without lifting the other settings
+func TestSandboxCapAdd(t *testing.T) {
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{"cap_add": []interface{}{"NET_RAW"}})
+	host := created.HostConfig
+	if len(host.CapAdd) != 1 || host.CapAdd[0] != "NET_RAW" {
+		t.Errorf("CapAdd = %v, want [NET_RAW]", host.CapAdd)
+	}
+	if len(host.CapDrop) != 1 || host.CapDrop[0] != "ALL" || !contains(host.SecurityOpt, "no-new-privileges") {
+		t.Errorf("CapDrop = %v, SecurityOpt = %v", host.CapDrop, host.SecurityOpt)
+	}
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TestNetworkIsolation checks that a sandbox is created without a network unless one is asked for
+func TestNetworkIsolation(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		mode string
+	}{
+		{"default", map[string]interface{}{}, "none"},
+		{"none", map[string]interface{}{"network": "none"}, "none"},
+		{"bridge", map[string]interface{}{"network": "bridge"}, "bridge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, created := initializeSandbox(t, newFakeDocker(), tt.args)
+			if got := string(created.HostConfig.NetworkMode); got != tt.mode {
+				t.Errorf("NetworkMode = %q, want %q", got, tt.mode)
+			}
+		})
+	}
+}
+
+// TestSandboxEnv checks that variables given at create time, as an object or a KEY=VALUE list, end up
+// in the container's environment
+func TestSandboxEnv(t *testing.T) {
+	for _, env := range []interface{}{
+		map[string]interface{}{"API_URL": "http://api.test", "MODE": "a=b"},
+		[]interface{}{"API_URL=http://api.test", "MODE=a=b"},
+	} {
+		_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{"env": env})
+		for _, want := range []string{"API_URL=http://api.test", "MODE=a=b"} {
+			if !contains(created.Config.Env, want) {
+				t.Errorf("env %v: Env = %v, want %s", env, created.Config.Env, want)
+			}
+		}
+	}
+}
+
+func TestSandboxEnvRejectsMalformedEntries(t *testing.T) {
+	tests := []struct {
+		env  interface{}
+		want string
+	}{
+		{[]interface{}{"NO_EQUALS"}, "must be in KEY=VALUE form"},
+		{[]interface{}{"=value"}, "has an empty name"},
+		{[]interface{}{"MY VAR=1"}, "must not contain whitespace"},
+		{map[string]interface{}{"MY VAR": "1"}, "must not contain whitespace"},
+		{map[string]interface{}{"N": 1.0}, "env value for N must be a string"},
+		{"A=1", "env must be an object or an array"},
+	}
+	for _, tt := range tests {
+		useFakeDocker(t, newFakeDocker())
+		text := callTool(t, InitializeEnvironment, map[string]interface{}{"env": tt.env}, true)
+		if !strings.Contains(text, tt.want) {
+			t.Errorf("env %v: error = %q, want %q", tt.env, text, tt.want)
+		}
+	}
+}
+
+// TestPidsLimit checks that the sandbox is created with the default process limit or the requested one
+func TestPidsLimit(t *testing.T) {
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{"pids_limit": 32.0})
+	if limit := created.HostConfig.PidsLimit; limit == nil || *limit != 32 {
+		t.Errorf("PidsLimit = %v, want 32", limit)
+	}
+}
+
+func TestPidsLimitMustBePositive(t *testing.T) {
+	for _, limit := range []float64{0, 0.5, -1} {
+		useFakeDocker(t, newFakeDocker())
+		callTool(t, InitializeEnvironment, map[string]interface{}{"pids_limit": limit}, true)
+	}
+}
+
+// TestTmpfsTmp checks that /tmp is a size-capped tmpfs rather than part of the container layer,
+// unless tmpfs_tmp turns it off
+func TestTmpfsTmp(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"default", map[string]interface{}{}, fmt.Sprintf("rw,noexec,nosuid,size=%dk,mode=1777", int(defaultTmpfsSizeMB*1024))},
+		{"capped", map[string]interface{}{"tmpfs_size_mb": 16.0}, "rw,noexec,nosuid,size=16384k,mode=1777"},
+		{"fractional cap", map[string]interface{}{"tmpfs_size_mb": 0.5}, "rw,noexec,nosuid,size=512k,mode=1777"},
+		{"turned off", map[string]interface{}{"tmpfs_tmp": false}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, created := initializeSandbox(t, newFakeDocker(), tt.args)
+			if got := created.HostConfig.Tmpfs["/tmp"]; got != tt.want {
+				t.Errorf("tmpfs options of /tmp = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStorageQuota checks that storage_mb becomes the size limit of the container layer
+func TestStorageQuota(t *testing.T) {
+	tests := []struct {
+		storageMB float64
+		want      string
+	}{
+		{10, "10240k"},
+		{0.5, "512k"},
+		// A fraction of a KiB still gets a limit rather than size 0, which would mean unlimited
+		{0.0001, "1k"},
+	}
+	for _, tt := range tests {
+		f := newFakeDocker()
+		f.info.DriverStatus = [][2]string{{"Backing Filesystem", "xfs"}}
+		_, created := initializeSandbox(t, f, map[string]interface{}{"storage_mb": tt.storageMB})
+		if got := created.HostConfig.StorageOpt["size"]; got != tt.want {
+			t.Errorf("storage_mb %g: StorageOpt size = %q, want %q", tt.storageMB, got, tt.want)
+		}
+	}
+
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{})
+	if created.HostConfig.StorageOpt != nil {
+		t.Errorf("StorageOpt = %v without storage_mb", created.HostConfig.StorageOpt)
+	}
+}
+
+// TestStorageQuotaUnsupported checks that a quota the daemon can't enforce is refused before
+// anything is created, instead of being silently ignored
+func TestStorageQuotaUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		fs     string
+		want   string
+	}{
+		{"vfs", "vfs", "", `storage_mb is not supported by the Docker storage driver "vfs"`},
+		{"overlay2 on ext4", "overlay2", "extfs", "storage_mb is not supported by the storage driver overlay2 on extfs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDocker()
+			f.info.Driver = tt.driver
+			if tt.fs != "" {
+				f.info.DriverStatus = [][2]string{{"Backing Filesystem", tt.fs}}
+			}
+			useFakeDocker(t, f)
+			text := callTool(t, InitializeEnvironment, map[string]interface{}{"storage_mb": 10.0}, true)
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("error = %q, want %q", text, tt.want)
+			}
+			if len(f.creates) != 0 {
+				t.Error("a container was created")
+			}
+		})
+	}
+}
+
+// TestWorkdirWritableBySandboxUser checks that the working directory is handed over to the non-root
+// sandbox user at initialize, which the daemon would otherwise leave owned by root
+func TestWorkdirWritableBySandboxUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		workdir string
+		mode    int64
+	}{
+		{"default", map[string]interface{}{}, "/app", defaultWorkdirMode},
+		{"custom workdir", map[string]interface{}{"workdir": "/home/sandbox/project"}, "/home/sandbox/project", defaultWorkdirMode},
+		{"workdir mode", map[string]interface{}{"workdir_mode": "0700"}, "/app", 0o700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDocker()
+			id, created := initializeSandbox(t, f, tt.args)
+			if created.Config.User != fmt.Sprintf("%d:%d", sandboxUID, sandboxGID) {
+				t.Fatalf("User = %q, want the sandbox user", created.Config.User)
+			}
+			hdr := f.dirs[id][tt.workdir]
+			if hdr == nil {
+				t.Fatalf("%s wasn't handed over", tt.workdir)
+			}
+			if hdr.Uid != sandboxUID || hdr.Gid != sandboxGID || hdr.Mode != tt.mode {
+				t.Errorf("%s is %d:%d mode %04o, want %d:%d mode %04o", tt.workdir, hdr.Uid, hdr.Gid, hdr.Mode, sandboxUID, sandboxGID, tt.mode)
+			}
+		})
+	}
+
+	// A read-only root filesystem gets a tmpfs working directory mounted with the owner instead
+	f := newFakeDocker()
+	id, created := initializeSandbox(t, f, map[string]interface{}{"readonly_rootfs": true})
+	want := fmt.Sprintf("uid=%d,gid=%d,mode=%04o", sandboxUID, sandboxGID, defaultWorkdirMode)
+	if opts := created.HostConfig.Tmpfs["/app"]; !strings.Contains(opts, want) {
+		t.Errorf("tmpfs options of /app = %q, want %q", opts, want)
+	}
+	if len(f.dirs[id]) != 0 {
+		t.Errorf("the tmpfs working directory was copied over: %v", f.dirs[id])
+	}
+}
+
+func TestWorkdirModeApplied(t *testing.T) {
+	f := newFakeDocker()
+	id, _ := initializeSandbox(t, f, map[string]interface{}{"workdir_mode": "0770"})
+	hdr := f.dirs[id]["/app"]
+	if hdr == nil {
+		t.Fatal("the working directory wasn't handed over")
+	}
+	if hdr.Uid != sandboxUID || hdr.Gid != sandboxGID || hdr.Mode != 0o770 {
+		t.Errorf("working directory is %d:%d mode %04o, want %d:%d mode 0770", hdr.Uid, hdr.Gid, hdr.Mode, sandboxUID, sandboxGID)
+	}
+}
+
+func TestWorkdirModeRejectsOwnerWithoutAccess(t *testing.T) {
+	for _, mode := range []string{"0600", "0500", "9999", "rwx"} {
+		useFakeDocker(t, newFakeDocker())
+		callTool(t, InitializeEnvironment, map[string]interface{}{"workdir_mode": mode}, true)
+	}
+}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultInstallTimeout bounds how long installing packages may take when no timeout is requested
+const defaultInstallTimeout = 5 * time.Minute
+
+// packageManager describes how to install packages with one package manager
+type packageManager struct {
+	// Binary is looked up on the PATH to check that the manager is present in the image
+	Binary string
+	// Script installs the packages passed to it as positional parameters ("$@")
+	Script string
+	// NeedsRoot is set for managers that install system-wide and can't run as the sandbox user
+	NeedsRoot bool
+}
+
+// packageManagers maps the manager argument to how that manager is run
+var packageManagers = map[string]packageManager{
+	"pip": {
+		Binary: "pip",
+		// --user installs into HOME, which is writable for the unprivileged sandbox user
+		Script: `if [ "$(id -u)" -ne 0 ]; then set -- --user "$@"; fi; pip install --no-input --no-cache-dir "$@"`,
+	},
+	"apt": {
+		Binary:    "apt-get",
+		Script:    `apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends "$@"`,
+		NeedsRoot: true,
+	},
+	"npm": {
+		Binary: "npm",
+		Script: `npm install --no-audit --no-fund "$@"`,
+	},
+}
+
+// installResult is the structured result of installing packages in a container
+type installResult struct {
+	Manager  string   `json:"manager"`
+	Packages []string `json:"packages"`
+	Success  bool     `json:"success"`
+	ExitCode int      `json:"exit_code"`
+	Log      string   `json:"log"`
+	// Note explains an install killed because the sandbox ran out of memory
+	Note string `json:"note,omitempty"`
+}
+
+// InstallPackages installs packages into a running container with pip, apt or npm
+func InstallPackages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	managerName, ok := request.Params.Arguments["manager"].(string)
+	if !ok || managerName == "" {
+		return newToolResultError("manager is required"), nil
+	}
+	manager, ok := packageManagers[managerName]
+	if !ok {
+		return newToolResultError(fmt.Sprintf("unsupported manager %q, must be pip, apt or npm", managerName)), nil
+	}
+
+	packages, err := parsePackageNames(request.Params.Arguments["packages"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultInstallTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := installPackagesInContainer(ctx, containerID, managerName, manager, packages, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error installing packages: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// parsePackageNames validates the packages argument. Names are passed to the package manager
+// as separate arguments, never through the shell, and may not look like command-line options.
+func parsePackageNames(arg interface{}) ([]string, error) {
+	list, ok := arg.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("packages must be a non-empty array of package names")
+	}
+
+	packages := make([]string, 0, len(list))
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("each package must be a non-empty string")
+		}
+		if strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("invalid package name %q", name)
+		}
+		packages = append(packages, name)
+	}
+	return packages, nil
+}
+
+// installPackagesInContainer checks that the sandbox can install packages with the manager and runs the install
+func installPackagesInContainer(ctx context.Context, containerID string, managerName string, manager packageManager, packages []string, timeout time.Duration) (*installResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+
+	// Every manager downloads from a registry, which can't work without a network
+	if info.HostConfig.NetworkMode.IsNone() {
+		return nil, fmt.Errorf("%w (network: none); "+
+			"create it with network set to bridge or a named network to install packages", ErrNetworkDisabled)
+	}
+	if manager.NeedsRoot && !isRootUser(info.Config.User) {
+		return nil, fmt.Errorf("%s installs system-wide and needs a sandbox created with run_as_root", managerName)
+	}
+
+	lookup, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", `command -v "$1"`, "sh", manager.Binary},
+	}, execIO{}, defaultExecTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if lookup.ExitCode != 0 {
+		return nil, fmt.Errorf("package manager %s is not available in image %s", managerName, info.Config.Image)
+	}
+
+	// Packages become the script's positional parameters, so they are never parsed by the shell
+	cmd := append([]string{"sh", "-c", manager.Script, "sh"}, packages...)
+	output, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		Cmd: cmd,
+	}, execIO{}, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &installResult{
+		Manager:  managerName,
+		Packages: packages,
+		Success:  output.ExitCode == 0,
+		ExitCode: output.ExitCode,
+		Log:      output.Stdout + output.Stderr,
+		Note:     output.Note,
+	}, nil
+}
+
+// isRootUser reports whether a container user setting, such as "1000:1000", runs as root
+func isRootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || name == "0"
+}
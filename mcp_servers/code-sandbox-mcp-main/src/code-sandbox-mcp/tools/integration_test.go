@@ -0,0 +1,324 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The integration tests run against a real daemon, Docker or Podman, reached through DOCKER_HOST.
+// They only run with CODE_SANDBOX_INTEGRATION=1, and need the default image present locally
+// unless CODE_SANDBOX_INTEGRATION_PULL=1 lets it be pulled.
+
+// fetchExample is a command that fails unless the sandbox can reach an external host. The default
+// image has Python but neither curl nor ping.
+const fetchExample = `python3 -c "import urllib.request; urllib.request.urlopen('http://example.com', timeout=10)"`
+
+// requireIntegration skips the test unless integration tests were asked for
+func requireIntegration(t *testing.T) {
+	t.Helper()
+	if os.Getenv("CODE_SANDBOX_INTEGRATION") != "1" {
+		t.Skip("set CODE_SANDBOX_INTEGRATION=1 to run against a real Docker or Podman daemon")
+	}
+	t.Cleanup(func() { _ = CloseDockerClient() })
+}
+
+// integrationSandbox creates a sandbox with args on the real daemon and removes it when the test ends
+func integrationSandbox(t *testing.T, args map[string]interface{}) string {
+	t.Helper()
+	requireIntegration(t)
+
+	withPull := map[string]interface{}{"allow_pull": os.Getenv("CODE_SANDBOX_INTEGRATION_PULL") == "1"}
+	for key, value := range args {
+		withPull[key] = value
+	}
+	var sandbox initializeResult
+	decodeResult(t, InitializeEnvironment, withPull, &sandbox)
+	t.Cleanup(func() {
+		callTool(t, StopContainer, map[string]interface{}{"container_id": sandbox.ContainerID, "stop_timeout_seconds": 0.0}, false)
+	})
+	return sandbox.ContainerID
+}
+
+// integrationRun runs command in the sandbox, with any further run_command arguments in args
+func integrationRun(t *testing.T, id string, command string, args map[string]interface{}) commandResult {
+	t.Helper()
+	withCommand := map[string]interface{}{"container_id": id, "command": command}
+	for key, value := range args {
+		withCommand[key] = value
+	}
+	var result commandResult
+	decodeResult(t, RunCommand, withCommand, &result)
+	return result
+}
+
+// TestIntegrationLifecycle creates, uses and removes a sandbox
+func TestIntegrationLifecycle(t *testing.T) {
+	requireIntegration(t)
+	var report serverCapabilities
+	decodeResult(t, GetServerCapabilities, map[string]interface{}{}, &report)
+	t.Logf("running against %s %s", report.Engine, report.DockerVersion)
+
+	id := integrationSandbox(t, nil)
+	if result := integrationRun(t, id, "echo hello", nil); result.ExitCode != 0 || result.Stdout != "hello\n" {
+		t.Errorf("exec: exit code %d, stdout %q, stderr %q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+}
+
+// TestIntegrationStrictPreset checks that a strict sandbox can't reach the network, runs as a
+// non-root user and can only write to its tmpfs mounts
+func TestIntegrationStrictPreset(t *testing.T) {
+	id := integrationSandbox(t, map[string]interface{}{"security_preset": "strict"})
+
+	if result := integrationRun(t, id, fetchExample, nil); result.ExitCode == 0 {
+		t.Error("a strict sandbox reached an external host")
+	}
+	if result := integrationRun(t, id, "id -u", nil); result.Stdout == "0\n" || result.ExitCode != 0 {
+		t.Errorf("id -u: exit code %d, stdout %q, want a non-root UID", result.ExitCode, result.Stdout)
+	}
+	if result := integrationRun(t, id, "touch /usr/local/bin/tampered", nil); result.ExitCode == 0 {
+		t.Error("a strict sandbox could write to its image")
+	}
+	for _, dir := range []string{"/app", "/tmp"} {
+		if result := integrationRun(t, id, "touch "+dir+"/scratch", nil); result.ExitCode != 0 {
+			t.Errorf("writing to %s failed: %s", dir, result.Stderr)
+		}
+	}
+}
+
+// TestIntegrationNetworkIsolation checks that a sandbox without a network can't reach an external
+// host, while one on the bridge network can, which shows the host itself is online
+func TestIntegrationNetworkIsolation(t *testing.T) {
+	online := integrationSandbox(t, map[string]interface{}{"network": "bridge"})
+	if result := integrationRun(t, online, fetchExample, nil); result.ExitCode != 0 {
+		t.Skipf("the host can't reach example.com: %s", result.Stderr)
+	}
+
+	offline := integrationSandbox(t, nil)
+	if result := integrationRun(t, offline, fetchExample, nil); result.ExitCode == 0 {
+		t.Error("a sandbox with network none reached an external host")
+	}
+}
+
+// TestIntegrationPidsLimit checks that a fork loop fails inside the sandbox once it reaches the
+// pids limit, instead of running unbounded, while a loop within the limit succeeds
+func TestIntegrationPidsLimit(t *testing.T) {
+	id := integrationSandbox(t, map[string]interface{}{"pids_limit": 32.0})
+	timeout := map[string]interface{}{"timeout_seconds": 30.0}
+
+	if result := integrationRun(t, id, "for i in $(seq 10); do sleep 2 & done; wait", timeout); result.ExitCode != 0 {
+		t.Fatalf("fork loop within the limit: exit code %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+	result := integrationRun(t, id, "for i in $(seq 100); do sleep 2 & done; wait", timeout)
+	if result.ExitCode == 0 || !strings.Contains(strings.ToLower(result.Stderr), "fork") {
+		t.Errorf("fork loop past the limit: exit code %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+}
+
+// TestIntegrationStorageQuota checks that writing past storage_mb fails, on daemons whose storage
+// setup can enforce it
+func TestIntegrationStorageQuota(t *testing.T) {
+	requireIntegration(t)
+	var report serverCapabilities
+	decodeResult(t, GetServerCapabilities, map[string]interface{}{}, &report)
+	if !report.StorageQuota {
+		t.Skipf("the %s storage driver %s can't enforce storage_mb", report.Engine, report.StorageDriver)
+	}
+
+	id := integrationSandbox(t, map[string]interface{}{"storage_mb": 10.0})
+	if result := integrationRun(t, id, "dd if=/dev/zero of=/app/small bs=1M count=5", nil); result.ExitCode != 0 {
+		t.Fatalf("a write within the quota failed: %s", result.Stderr)
+	}
+	result := integrationRun(t, id, "dd if=/dev/zero of=/app/big bs=1M count=20", nil)
+	if result.ExitCode == 0 || !strings.Contains(result.Stderr, "No space left on device") {
+		t.Errorf("a write past the quota: exit code %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+}
+
+// TestIntegrationWorkdirWritable checks that the sandbox user can create files in its working
+// directory, and that another unprivileged user only gets what workdir_mode grants others
+func TestIntegrationWorkdirWritable(t *testing.T) {
+	requireIntegration(t)
+	for _, args := range []map[string]interface{}{
+		{},
+		{"workdir": "/home/sandbox/project"},
+		{"workdir_mode": "0700"},
+		{"readonly_rootfs": true},
+	} {
+		id := integrationSandbox(t, args)
+		if result := integrationRun(t, id, "touch created", nil); result.ExitCode != 0 {
+			t.Errorf("%v: touch failed with %d: %s", args, result.ExitCode, result.Stderr)
+		}
+		result := integrationRun(t, id, "touch other", map[string]interface{}{"user": "2000:2000"})
+		if result.ExitCode == 0 {
+			t.Errorf("%v: a user other than the sandbox user could write to the working directory", args)
+		}
+	}
+}
+
+// TestIntegrationResetSandbox checks that a reset deletes the files earlier commands wrote
+func TestIntegrationResetSandbox(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{})
+	if result := integrationRun(t, id, "mkdir -p /app/out && echo result > /app/out/result.txt && touch /tmp/scratch", nil); result.ExitCode != 0 {
+		t.Fatalf("writing files failed: %s", result.Stderr)
+	}
+
+	var reset resetResult
+	decodeResult(t, ResetSandbox, map[string]interface{}{"container_id": id}, &reset)
+
+	result := integrationRun(t, id, "ls -A /app /tmp", nil)
+	if result.ExitCode != 0 || strings.TrimSpace(strings.NewReplacer("/app:", "", "/tmp:", "").Replace(result.Stdout)) != "" {
+		t.Errorf("files survived the reset: %q %s", result.Stdout, result.Stderr)
+	}
+	if result := integrationRun(t, id, "touch /app/again", nil); result.ExitCode != 0 {
+		t.Errorf("the working directory isn't writable after the reset: %s", result.Stderr)
+	}
+}
+
+// TestIntegrationTmpfsTmp checks that /tmp is mounted as a tmpfs of the requested size, and is part
+// of the container layer when tmpfs_tmp is turned off
+func TestIntegrationTmpfsTmp(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{"tmpfs_size_mb": 16.0})
+	result := integrationRun(t, id, "grep ' /tmp ' /proc/mounts", nil)
+	if !strings.HasPrefix(result.Stdout, "tmpfs /tmp tmpfs ") || !strings.Contains(result.Stdout, "size=16384k") {
+		t.Errorf("/tmp is mounted as %q, want a tmpfs of 16384k", result.Stdout)
+	}
+
+	id = integrationSandbox(t, map[string]interface{}{"tmpfs_tmp": false})
+	if result := integrationRun(t, id, "grep ' /tmp ' /proc/mounts", nil); result.ExitCode == 0 {
+		t.Errorf("/tmp is still a mount point: %q", result.Stdout)
+	}
+}
+
+// TestIntegrationSecrets checks that secrets can be read by the sandbox user only, as read-only files
+func TestIntegrationSecrets(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{"secrets": map[string]interface{}{"api_key": "s3cr3t"}})
+	if result := integrationRun(t, id, "cat /run/secrets/api_key", nil); result.Stdout != "s3cr3t" {
+		t.Errorf("api_key = %q (stderr %q)", result.Stdout, result.Stderr)
+	}
+	if result := integrationRun(t, id, "stat -c %a:%u /run/secrets/api_key", nil); result.Stdout != "400:1000\n" {
+		t.Errorf("api_key mode and owner = %q, want 400:1000", result.Stdout)
+	}
+	if result := integrationRun(t, id, "cat /run/secrets/api_key", map[string]interface{}{"user": "65534"}); result.ExitCode == 0 {
+		t.Error("another user could read a secret")
+	}
+	if result := integrationRun(t, id, "grep ' /run/secrets ' /proc/mounts", nil); !strings.HasPrefix(result.Stdout, "tmpfs ") {
+		t.Errorf("/run/secrets is mounted as %q, want a tmpfs", result.Stdout)
+	}
+
+	id = integrationSandbox(t, map[string]interface{}{"run_as_root": true, "secrets": map[string]interface{}{"token": "t"}})
+	if result := integrationRun(t, id, "stat -c %a:%u /run/secrets/token", nil); result.Stdout != "400:0\n" {
+		t.Errorf("token mode and owner = %q, want 400:0", result.Stdout)
+	}
+}
+
+// TestIntegrationSandboxEnv checks that commands see the variables given at create time
+func TestIntegrationSandboxEnv(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{"env": map[string]interface{}{"API_URL": "http://api.test", "MODE": "a=b"}})
+	if result := integrationRun(t, id, "printenv API_URL MODE", nil); result.Stdout != "http://api.test\na=b\n" {
+		t.Errorf("printenv = %q (stderr %q)", result.Stdout, result.Stderr)
+	}
+}
+
+// TestIntegrationExecEnvOverride checks that env wins over the sandbox's environment for one command only
+func TestIntegrationExecEnvOverride(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{"env": map[string]interface{}{"MODE": "prod", "API_URL": "http://api.test"}})
+	result := integrationRun(t, id, "printenv MODE API_URL DEBUG", map[string]interface{}{"env": map[string]interface{}{"MODE": "debug", "DEBUG": "1"}})
+	if result.Stdout != "debug\nhttp://api.test\n1\n" {
+		t.Errorf("with overrides: stdout = %q", result.Stdout)
+	}
+	if result := integrationRun(t, id, "printenv MODE API_URL DEBUG", nil); result.Stdout != "prod\nhttp://api.test\n" {
+		t.Errorf("after the override: stdout = %q", result.Stdout)
+	}
+}
+
+// TestIntegrationExecUserAndWorkdirOverride checks that user and workdir change who and where a
+// single command runs
+func TestIntegrationExecUserAndWorkdirOverride(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{})
+	if result := integrationRun(t, id, "mkdir src", nil); result.ExitCode != 0 {
+		t.Fatalf("mkdir failed: %s", result.Stderr)
+	}
+
+	tests := []struct {
+		command string
+		args    map[string]interface{}
+		want    string
+	}{
+		{"whoami", map[string]interface{}{"user": "root"}, "root\n"},
+		{"id -u", map[string]interface{}{"user": "65534:65534"}, "65534\n"},
+		{"id -u", nil, fmt.Sprintf("%d\n", sandboxUID)},
+		{"pwd", map[string]interface{}{"workdir": "/tmp"}, "/tmp\n"},
+		{"pwd", map[string]interface{}{"workdir": "src"}, "/app/src\n"},
+		{"pwd", nil, "/app\n"},
+	}
+	for _, tt := range tests {
+		if result := integrationRun(t, id, tt.command, tt.args); result.Stdout != tt.want {
+			t.Errorf("%s with %v: stdout = %q, want %q (stderr %q)", tt.command, tt.args, result.Stdout, tt.want, result.Stderr)
+		}
+	}
+}
+
+// TestIntegrationRunCommandPipesStdin checks that stdin reaches the command and is closed, so cat
+// echoes it back and exits
+func TestIntegrationRunCommandPipesStdin(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{})
+	input := "line one\nline two\n"
+	if result := integrationRun(t, id, "cat", map[string]interface{}{"stdin": input}); result.Stdout != input || result.ExitCode != 0 {
+		t.Errorf("stdout = %q, exit code %d, want %q echoed back", result.Stdout, result.ExitCode, input)
+	}
+}
+
+// TestIntegrationOOMKill checks that a command allocating past memory_mb is reported as OOM
+// killed, and that a plain SIGKILL afterwards isn't
+func TestIntegrationOOMKill(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{"memory_mb": 64.0})
+
+	result := integrationRun(t, id, `python3 -c "b = bytearray(200 * 1024 * 1024)"`, nil)
+	if result.ExitCode != oomExitCode || !result.OOMKilled {
+		t.Fatalf("allocation past the limit: exit code %d, oom_killed %v, stderr %q", result.ExitCode, result.OOMKilled, result.Stderr)
+	}
+	result = integrationRun(t, id, "kill -9 $$", nil)
+	if result.ExitCode != oomExitCode || result.OOMKilled {
+		t.Errorf("SIGKILL after the OOM kill: exit code %d, oom_killed %v, note %q", result.ExitCode, result.OOMKilled, result.Note)
+	}
+}
+
+// TestIntegrationNonRootUser checks that commands of a default sandbox run as the unprivileged
+// sandbox user, and as root only with run_as_root
+func TestIntegrationNonRootUser(t *testing.T) {
+	requireIntegration(t)
+	id := integrationSandbox(t, map[string]interface{}{})
+	if result := integrationRun(t, id, "id -u", nil); result.Stdout != fmt.Sprintf("%d\n", sandboxUID) {
+		t.Errorf("id -u = %q, want %d (stderr %q)", result.Stdout, sandboxUID, result.Stderr)
+	}
+
+	id = integrationSandbox(t, map[string]interface{}{"run_as_root": true})
+	if result := integrationRun(t, id, "id -u", nil); result.Stdout != "0\n" {
+		t.Errorf("with run_as_root: id -u = %q, want 0", result.Stdout)
+	}
+}
+
+// TestIntegrationPrivilegedCommandFails checks that a default sandbox can't mount a filesystem,
+// even when a command runs as root, because every capability is dropped
+func TestIntegrationPrivilegedCommandFails(t *testing.T) {
+	requireIntegration(t)
+	for _, args := range []map[string]interface{}{{}, {"run_as_root": true}} {
+		id := integrationSandbox(t, args)
+		for _, user := range []string{"", "0"} {
+			result := integrationRun(t, id, "mount -t tmpfs none /mnt", map[string]interface{}{"user": user})
+			if result.ExitCode == 0 {
+				t.Errorf("%v, user %q: mount succeeded", args, user)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultKillSignal is sent when the caller doesn't choose a signal
+const defaultKillSignal = "SIGKILL"
+
+// killSignals are the signals sandbox_kill accepts. Docker takes any name the container's
+// platform knows, but a typo would otherwise only surface as a daemon error.
+var killSignals = map[string]bool{
+	"SIGHUP":   true,
+	"SIGINT":   true,
+	"SIGQUIT":  true,
+	"SIGABRT":  true,
+	"SIGKILL":  true,
+	"SIGUSR1":  true,
+	"SIGUSR2":  true,
+	"SIGALRM":  true,
+	"SIGTERM":  true,
+	"SIGCONT":  true,
+	"SIGSTOP":  true,
+	"SIGTSTP":  true,
+	"SIGWINCH": true,
+}
+
+// killResult is the structured result of signalling a sandbox
+type killResult struct {
+	ContainerID string `json:"container_id"`
+	Signal      string `json:"signal"`
+	Status      string `json:"status"`
+}
+
+// KillContainer sends a signal to the main process of a container without removing the container
+func KillContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	signal, err := parseSignal(request.Params.Arguments["signal"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := killContainer(ctx, containerID, signal)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// parseSignal validates the signal argument. Names are accepted in any case and with or
+// without the SIG prefix, e.g. "usr1", and returned in their canonical form.
+func parseSignal(arg interface{}) (string, error) {
+	if arg == nil {
+		return defaultKillSignal, nil
+	}
+	s, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("signal must be a string such as SIGTERM")
+	}
+	if s == "" {
+		return defaultKillSignal, nil
+	}
+
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if !killSignals[name] {
+		return "", fmt.Errorf("unknown signal %q, expected one of SIGHUP, SIGINT, SIGQUIT, SIGABRT, SIGKILL, SIGUSR1, SIGUSR2, SIGALRM, SIGTERM, SIGCONT, SIGSTOP, SIGTSTP or SIGWINCH", s)
+	}
+	return name, nil
+}
+
+// killContainer signals the main process of a managed container and reports the state it is in afterwards
+func killContainer(ctx context.Context, containerID string, signal string) (*killResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Like sandbox_stop, only touch containers this server created
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to signal it", containerID)
+	}
+
+	if err := cli.ContainerKill(ctx, containerID, signal); err != nil {
+		if errdefs.IsConflict(err) {
+			return nil, fmt.Errorf("container %s is not running, so it can't be signalled", containerID)
+		}
+		return nil, fmt.Errorf("failed to signal container: %w", err)
+	}
+
+	info, err = cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	return &killResult{
+		ContainerID: info.ID,
+		Signal:      signal,
+		Status:      info.State.Status,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+)
+
+const (
+	// managedLabel marks containers created by this server so they can be told apart from unrelated containers
+	managedLabel = "code-sandbox-mcp.managed"
+	// createdAtLabel records when the container was created, in RFC 3339 format
+	createdAtLabel = "code-sandbox-mcp.created-at"
+	// serverSessionLabel identifies the server process that created the container
+	serverSessionLabel = "code-sandbox-mcp.server-session"
+	// clientSessionLabel attributes the container to the end user or session named by the client
+	clientSessionLabel = "code-sandbox-mcp.client-session"
+	// idleTimeoutLabel holds the sandbox's own idle timeout in seconds, overriding CODE_SANDBOX_IDLE_TTL
+	idleTimeoutLabel = "code-sandbox-mcp.idle-timeout"
+	// metadataLabelPrefix starts the labels holding the metadata given to sandbox_initialize, one per key
+	metadataLabelPrefix = "code-sandbox-mcp.metadata."
+	// imageLabel marks images created by committing a sandbox, so they can be cleaned up later.
+	// Images never carry managedLabel: that would mark any container run from them as a sandbox.
+	imageLabel = "code-sandbox-mcp.image"
+	// sourceContainerLabel records the ID of the sandbox an image was committed from
+	sourceContainerLabel = "code-sandbox-mcp.source-container"
+	// createIDLabel holds a random ID per sandbox_initialize, so a create that is retried after an
+	// ambiguous failure can find the container the failed attempt may have created
+	createIDLabel = "code-sandbox-mcp.create-id"
+)
+
+// serverSessionID is a random identifier for this server process, generated at startup
+var serverSessionID = newServerSessionID()
+
+// newServerSessionID returns a random hex identifier, or "unknown" if no randomness is available
+func newServerSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// sessionIDPattern limits client session IDs to characters that are safe in a label value and a filter
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@-]{0,127}$`)
+
+// parseSessionID validates the optional session_id argument, returning "" when it is unset
+func parseSessionID(arg interface{}) (string, error) {
+	if arg == nil {
+		return "", nil
+	}
+	id, ok := arg.(string)
+	if !ok || !sessionIDPattern.MatchString(id) {
+		return "", fmt.Errorf("session_id must be 1 to 128 letters, digits, '_', '.', '@' or '-', starting with a letter or digit")
+	}
+	return id, nil
+}
+
+// managedLabels returns the labels attached to every container this server creates, attributing
+// it to clientSession when one is given
+func managedLabels(clientSession string) map[string]string {
+	labels := map[string]string{
+		managedLabel:       "true",
+		createdAtLabel:     time.Now().UTC().Format(time.RFC3339),
+		serverSessionLabel: serverSessionID,
+	}
+	if clientSession != "" {
+		labels[clientSessionLabel] = clientSession
+	}
+	return labels
+}
+
+// imageLabelChange returns the Dockerfile LABEL instruction applied to every image committed from
+// a sandbox with the given labels. The container's own labels are inherited by the image, so
+// managedLabel is cleared, and so are the per-sandbox settings and metadata a later sandbox run
+// from the image must not inherit.
+func imageLabelChange(containerID string, labels map[string]string) string {
+	change := fmt.Sprintf(`LABEL %s=true %s=%s %s=%s %s=%s %s=false %s="" %s="" %s=""`,
+		imageLabel, sourceContainerLabel, containerID,
+		createdAtLabel, time.Now().UTC().Format(time.RFC3339),
+		serverSessionLabel, serverSessionID,
+		managedLabel, clientSessionLabel, idleTimeoutLabel, createIDLabel)
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if strings.HasPrefix(key, metadataLabelPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		change += fmt.Sprintf(` %s=""`, key)
+	}
+	return change
+}
+
+// isManaged reports whether a container with the given labels was created by this server
+func isManaged(labels map[string]string) bool {
+	return labels[managedLabel] == "true"
+}
+
+// managedFilter returns a filter that matches only containers created by this server, and with a
+// clientSession only those attributed to it
+func managedFilter(clientSession string) filters.Args {
+	f := filters.NewArgs(filters.Arg("label", managedLabel+"=true"))
+	if clientSession != "" {
+		f.Add("label", clientSessionLabel+"="+clientSession)
+	}
+	return f
+}
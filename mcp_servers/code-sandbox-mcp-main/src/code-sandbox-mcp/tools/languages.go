@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// codeLanguage describes how to run code written in one language
+type codeLanguage struct {
+	// Interpreter is the command the code file is passed to
+	Interpreter string
+	// Extension is the file extension used for the code file
+	Extension string
+	// Image is used for ephemeral sandboxes when the caller doesn't choose one; empty means the
+	// server's default image, which is Python based unless CODE_SANDBOX_DEFAULT_IMAGE says otherwise
+	Image string
+}
+
+// codeLanguages is the single table of supported languages. Adding a language only needs a new entry here.
+var codeLanguages = map[string]codeLanguage{
+	"python": {Interpreter: "python3", Extension: "py"},
+	"bash":   {Interpreter: "bash", Extension: "sh"},
+	"node":   {Interpreter: "node", Extension: "js", Image: "node:20-slim"},
+	"ruby":   {Interpreter: "ruby", Extension: "rb", Image: "ruby:3.3-slim"},
+}
+
+// interpreterFor returns how to run code in the given language.
+// Unknown languages are reported together with the list of supported ones.
+func interpreterFor(lang string) (codeLanguage, error) {
+	language, ok := codeLanguages[strings.ToLower(strings.TrimSpace(lang))]
+	if !ok {
+		return codeLanguage{}, fmt.Errorf("unsupported language %q, supported languages: %s", lang, strings.Join(SupportedLanguages(), ", "))
+	}
+	return language, nil
+}
+
+// image returns the image of ephemeral sandboxes for the language
+func (l codeLanguage) image() string {
+	if l.Image == "" {
+		return defaultImage
+	}
+	return l.Image
+}
+
+// SupportedLanguages returns the names of the supported languages in alphabetical order
+func SupportedLanguages() []string {
+	names := make([]string, 0, len(codeLanguages))
+	for name := range codeLanguages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
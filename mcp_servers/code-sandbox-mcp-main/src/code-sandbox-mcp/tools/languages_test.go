@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguageImageFallsBackToDefaultImage(t *testing.T) {
+	prev := defaultImage
+	defaultImage = "registry.internal/hardened:1"
+	t.Cleanup(func() { defaultImage = prev })
+
+	for _, name := range []string{"python", "bash"} {
+		language, err := interpreterFor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := language.image(); got != defaultImage {
+			t.Errorf("%s image = %s, want the default image %s", name, got, defaultImage)
+		}
+	}
+	if node, _ := interpreterFor("node"); node.image() != "node:20-slim" {
+		t.Errorf("node image = %s, want its own image", node.image())
+	}
+}
+
+func TestInterpreterFor(t *testing.T) {
+	want := map[string]struct{ interpreter, extension string }{
+		"python": {"python3", "py"},
+		"bash":   {"bash", "sh"},
+		"node":   {"node", "js"},
+		"ruby":   {"ruby", "rb"},
+	}
+	if len(codeLanguages) != len(want) {
+		t.Errorf("the language table has %d entries, the test knows %d; add the new ones here", len(codeLanguages), len(want))
+	}
+	for name := range codeLanguages {
+		w, ok := want[name]
+		if !ok {
+			t.Errorf("language %s is not covered by the test", name)
+			continue
+		}
+		// Names are matched regardless of case and surrounding space
+		for _, given := range []string{name, strings.ToUpper(name), " " + name + " "} {
+			language, err := interpreterFor(given)
+			if err != nil {
+				t.Errorf("interpreterFor(%q): %v", given, err)
+				continue
+			}
+			if language.Interpreter != w.interpreter || language.Extension != w.extension {
+				t.Errorf("interpreterFor(%q) = %s with .%s, want %s with .%s", given, language.Interpreter, language.Extension, w.interpreter, w.extension)
+			}
+		}
+	}
+}
+
+func TestInterpreterForUnknownLanguage(t *testing.T) {
+	_, err := interpreterFor("cobol")
+	if err == nil {
+		t.Fatal("an unknown language was accepted")
+	}
+	for _, name := range SupportedLanguages() {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q doesn't list supported language %s", err, name)
+		}
+	}
+}
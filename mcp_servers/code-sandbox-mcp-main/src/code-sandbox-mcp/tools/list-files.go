@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultListDepth is how deep a recursive listing goes when max_depth isn't given
+	defaultListDepth = 5
+	// maxListDepth bounds max_depth
+	maxListDepth = 20
+	// maxListEntries caps the entries of one listing so a huge tree can't flood the response
+	maxListEntries = 1000
+)
+
+// fileEntry describes one entry of a directory listing
+type fileEntry struct {
+	// Name is the path of the entry relative to the listed directory, e.g. src/main.py
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Size    int64  `json:"size"`
+	Mode    string `json:"mode"`
+	ModTime string `json:"mod_time"`
+	// LinkTarget is where a symlink points
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// listFilesResult is the structured result of listing a directory
+type listFilesResult struct {
+	Path    string      `json:"path"`
+	Entries []fileEntry `json:"entries"`
+	// Truncated is set when the directory holds more than maxListEntries entries within the depth
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ListFiles lists the entries of a directory in a container, optionally recursing into subdirectories
+func ListFiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// Default to the working directory
+	path, _ := request.Params.Arguments["path"].(string)
+	if path == "" {
+		path = "."
+	}
+	path, err = resolveContainerPath(ctx, containerID, path)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving path: %v", err)), nil
+	}
+
+	depth := 1
+	if recursive, _ := request.Params.Arguments["recursive"].(bool); recursive {
+		maxDepth, err := positiveNumberArg(request.Params.Arguments, "max_depth", defaultListDepth)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		if maxDepth > maxListDepth {
+			return newToolResultError(fmt.Sprintf("max_depth must be at most %d", maxListDepth)), nil
+		}
+		depth = int(maxDepth)
+	}
+
+	result, err := listFiles(ctx, containerID, path, depth)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error listing files: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// listFiles lists dir from the headers of its tar archive, down to depth levels below it.
+// The file contents are skipped rather than read, and the stream is closed once the listing is full.
+func listFiles(ctx context.Context, containerID string, dir string, depth int) (*listFilesResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, stat, err := getArchive(ctx, cli, containerID, dir)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("directory not found: %s", dir)
+		}
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer reader.Close()
+
+	if !stat.Mode.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory; use read_file_sandbox to read a file", dir)
+	}
+
+	result := &listFilesResult{Path: dir, Entries: []fileEntry{}}
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		// Entries are named after the directory itself, e.g. app/src/main.py, and the first one is the directory
+		_, name, found := strings.Cut(strings.TrimSuffix(header.Name, "/"), "/")
+		if !found || name == "" {
+			continue
+		}
+		if strings.Count(name, "/")+1 > depth {
+			continue
+		}
+
+		if len(result.Entries) == maxListEntries {
+			result.Truncated = true
+			break
+		}
+		result.Entries = append(result.Entries, fileEntry{
+			Name:       name,
+			Type:       fileEntryType(header.Typeflag),
+			Size:       header.Size,
+			Mode:       fmt.Sprintf("%04o", header.FileInfo().Mode().Perm()),
+			ModTime:    header.ModTime.UTC().Format("2006-01-02T15:04:05Z"),
+			LinkTarget: header.Linkname,
+		})
+	}
+	return result, nil
+}
+
+// fileEntryType names the kind of a tar entry
+func fileEntryType(flag byte) string {
+	switch flag {
+	case tar.TypeReg:
+		return "file"
+	case tar.TypeDir:
+		return "dir"
+	case tar.TypeSymlink:
+		return "symlink"
+	case tar.TypeLink:
+		return "hardlink"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sandboxInfo describes a sandbox container in the ListSandboxes result
+type sandboxInfo struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+	Image       string `json:"image"`
+	Created     string `json:"created"`
+	State       string `json:"state"`
+	Status      string `json:"status"`
+}
+
+// ListSandboxes lists the containers created by this server, optionally only those of one client session
+func ListSandboxes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	clientSession, err := parseSessionID(request.Params.Arguments["session_id"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	sandboxes, err := listManagedContainers(ctx, clientSession)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	result := make([]sandboxInfo, 0, len(sandboxes))
+	for _, c := range sandboxes {
+		// Warm pool sandboxes belong to nobody until sandbox_initialize hands them out
+		if isPooled(c.ID) {
+			continue
+		}
+		result = append(result, sandboxInfo{
+			ContainerID: c.ID,
+			Name:        sandboxName(c.Names),
+			SessionID:   c.Labels[clientSessionLabel],
+			Image:       c.Image,
+			Created:     time.Unix(c.Created, 0).UTC().Format(time.RFC3339),
+			State:       c.State,
+			Status:      c.Status,
+		})
+	}
+
+	return newToolResultJSON(result)
+}
+
+// listManagedContainers returns every container carrying the ownership label, including stopped ones,
+// limited to those of clientSession unless it is ""
+func listManagedContainers(ctx context.Context, clientSession string) ([]container.Summary, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: managedFilter(clientSession),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	return containers, nil
+}
+
+// sandboxName returns the name a sandbox was given in sandbox_initialize, without the namespace prefix,
+// or "" for sandboxes that only have a random name from Docker
+func sandboxName(names []string) string {
+	for _, n := range names {
+		if name, ok := strings.CutPrefix(strings.TrimPrefix(n, "/"), containerNamePrefix); ok {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger writes the server's structured log to stderr, which is never used for MCP traffic. Its
+// level comes from CODE_SANDBOX_LOG_LEVEL and its format from CODE_SANDBOX_LOG_FORMAT, read once
+// at startup. Log records carry IDs, images, exit codes and durations, never environment values,
+// commands, file contents or registry credentials, which may all hold secrets.
+var logger = newLogger(os.Getenv("CODE_SANDBOX_LOG_LEVEL"), os.Getenv("CODE_SANDBOX_LOG_FORMAT"))
+
+// Logger returns the server's logger, so the rest of the binary logs the same way
+func Logger() *slog.Logger {
+	return logger
+}
+
+// newLogger returns a logger for the given level (debug, info, warn or error; default info) and
+// format (text or json; default text). Invalid values are reported through the logger itself and
+// replaced by the defaults.
+func newLogger(level, format string) *slog.Logger {
+	var problems []string
+
+	var lvl slog.Level
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "", "info":
+		lvl = slog.LevelInfo
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelInfo
+		problems = append(problems, fmt.Sprintf("CODE_SANDBOX_LOG_LEVEL=%q, must be debug, info, warn or error", level))
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+		problems = append(problems, fmt.Sprintf("CODE_SANDBOX_LOG_FORMAT=%q, must be text or json", format))
+	}
+
+	l := slog.New(handler)
+	for _, p := range problems {
+		l.Warn("ignoring invalid logging setting", "setting", p)
+	}
+	return l
+}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxMetadataEntries caps the keys of one sandbox's metadata, counting both kinds
+	maxMetadataEntries = 64
+	// maxMetadataValueBytes caps the length of one metadata value
+	maxMetadataValueBytes = 1024
+)
+
+// metadataKeyPattern limits metadata keys to characters that are safe in a label key
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,62}$`)
+
+// Metadata comes in two kinds. Metadata given to sandbox_initialize is stored in labels, so it is
+// durable and survives server restarts, but labels can't change after create. Metadata set later
+// with sandbox_set_metadata is kept in this server's memory on top of the labels and is lost when
+// the server restarts.
+var sandboxMetadata = struct {
+	sync.Mutex
+	values map[string]map[string]string
+}{values: make(map[string]map[string]string)}
+
+// metadataResult is the structured result of reading or changing a sandbox's metadata
+type metadataResult struct {
+	ContainerID string `json:"container_id"`
+	// Metadata holds every key, with values set by sandbox_set_metadata overriding those from labels
+	Metadata map[string]string `json:"metadata"`
+	// Durable lists the keys whose value is stored in a label and survives a server restart
+	Durable []string `json:"durable"`
+}
+
+// parseMetadata validates the metadata argument of sandbox_initialize, an object of keys to string values
+func parseMetadata(arg interface{}) (map[string]string, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	obj, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata must be an object of keys to string values")
+	}
+	if len(obj) > maxMetadataEntries {
+		return nil, fmt.Errorf("metadata can have at most %d keys", maxMetadataEntries)
+	}
+	metadata := make(map[string]string, len(obj))
+	for key, v := range obj {
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("metadata value for %s must be a string", key)
+		}
+		if err := checkMetadataEntry(key, value); err != nil {
+			return nil, err
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+// checkMetadataEntry validates one metadata key and value
+func checkMetadataEntry(key, value string) error {
+	if !metadataKeyPattern.MatchString(key) {
+		return fmt.Errorf("metadata key %q must be 1 to 63 letters, digits, '_', '.' or '-', starting with a letter or digit", key)
+	}
+	if len(value) > maxMetadataValueBytes {
+		return fmt.Errorf("metadata value for %s is longer than %d bytes", key, maxMetadataValueBytes)
+	}
+	return nil
+}
+
+// metadataFromLabels returns the durable metadata stored in a container's labels
+func metadataFromLabels(labels map[string]string) map[string]string {
+	metadata := map[string]string{}
+	for label, value := range labels {
+		if key, ok := strings.CutPrefix(label, metadataLabelPrefix); ok && key != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// forgetMetadata drops the in-memory metadata of a removed container
+func forgetMetadata(containerID string) {
+	sandboxMetadata.Lock()
+	defer sandboxMetadata.Unlock()
+	for id := range sandboxMetadata.values {
+		if strings.HasPrefix(id, containerID) {
+			delete(sandboxMetadata.values, id)
+		}
+	}
+}
+
+// SetMetadata sets or removes metadata keys of a sandbox. The changes are kept in memory and lost
+// when the server restarts; only metadata given to sandbox_initialize is durable.
+func SetMetadata(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	changes, ok := request.Params.Arguments["metadata"].(map[string]interface{})
+	if !ok || len(changes) == 0 {
+		return newToolResultError("metadata is required: an object of keys to string values, or null to remove a key"), nil
+	}
+
+	result, err := setMetadata(ctx, containerID, changes)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// GetMetadata returns the metadata of a sandbox
+func GetMetadata(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := setMetadata(ctx, containerID, nil)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// setMetadata applies changes to the in-memory metadata of a sandbox, where a nil value removes a
+// key, and returns its metadata afterwards. With no changes it only reads the metadata.
+func setMetadata(ctx context.Context, containerID string, changes map[string]interface{}) (*metadataResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to use its metadata", containerID)
+	}
+	durable := metadataFromLabels(info.Config.Labels)
+
+	sandboxMetadata.Lock()
+	defer sandboxMetadata.Unlock()
+
+	// Changes are checked in full first, so a bad key leaves the metadata as it was
+	updated := map[string]string{}
+	for key, value := range sandboxMetadata.values[info.ID] {
+		updated[key] = value
+	}
+	for key, v := range changes {
+		if v == nil {
+			if _, ok := updated[key]; !ok {
+				if _, ok := durable[key]; ok {
+					return nil, fmt.Errorf("metadata key %s was set when the sandbox was created and is stored in a label, which can't be removed", key)
+				}
+			}
+			delete(updated, key)
+			continue
+		}
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("metadata value for %s must be a string, or null to remove it", key)
+		}
+		if err := checkMetadataEntry(key, value); err != nil {
+			return nil, err
+		}
+		updated[key] = value
+	}
+
+	result := &metadataResult{ContainerID: info.ID, Metadata: map[string]string{}, Durable: []string{}}
+	for key, value := range durable {
+		result.Metadata[key] = value
+	}
+	for key, value := range updated {
+		result.Metadata[key] = value
+	}
+	if len(result.Metadata) > maxMetadataEntries {
+		return nil, fmt.Errorf("metadata can have at most %d keys", maxMetadataEntries)
+	}
+	for key := range durable {
+		if _, overridden := updated[key]; !overridden {
+			result.Durable = append(result.Durable, key)
+		}
+	}
+	sort.Strings(result.Durable)
+
+	if len(updated) == 0 {
+		delete(sandboxMetadata.values, info.ID)
+	} else {
+		sandboxMetadata.values[info.ID] = updated
+	}
+	return result, nil
+}
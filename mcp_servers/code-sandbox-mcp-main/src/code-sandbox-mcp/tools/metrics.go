@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The metrics are written in the Prometheus text exposition format by hand, which is all a
+// scraper needs and keeps the server free of a client library dependency.
+
+// counter is a monotonically increasing count
+type counter struct{ n atomic.Uint64 }
+
+func (c *counter) Inc() { c.n.Add(1) }
+
+// histogram counts observations, in seconds, into cumulative buckets
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets ...float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe records how long something took
+func (h *histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+var (
+	containersCreated = &counter{}
+	containersStopped = &counter{}
+	containersFailed  = &counter{}
+	// execDuration spans quick commands up to the longest exec timeouts
+	execDuration = newHistogram(0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300)
+	// imageInspectDuration is a local daemon call, normally well below a second
+	imageInspectDuration = newHistogram(0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 5)
+)
+
+// writeMetrics writes every metric in the Prometheus text format. The active sandbox gauge is
+// counted from the running containers that carry the ownership label, so it also covers sandboxes
+// created before a restart or by another server sharing the daemon.
+func writeMetrics(ctx context.Context, w io.Writer) {
+	writeCounter(w, "code_sandbox_containers_created_total", "Sandboxes created and started.", containersCreated)
+	writeCounter(w, "code_sandbox_containers_stopped_total", "Sandboxes stopped and removed, including idle and discarded ones.", containersStopped)
+	writeCounter(w, "code_sandbox_containers_failed_total", "Sandboxes that failed to be created or started.", containersFailed)
+	writeHistogram(w, "code_sandbox_exec_duration_seconds", "Duration of commands run in sandboxes.", execDuration)
+	writeHistogram(w, "code_sandbox_image_inspect_duration_seconds", "Latency of looking up a sandbox image in the local image store.", imageInspectDuration)
+
+	containers, err := listManagedContainers(ctx, "")
+	if err != nil {
+		// Leaving the gauge out makes the scrape show it as missing rather than as zero sandboxes
+		logger.Warn("failed to count active sandboxes for metrics", "error", err)
+		return
+	}
+	active := 0
+	for _, c := range containers {
+		if c.State == "running" {
+			active++
+		}
+	}
+	fmt.Fprintf(w, "# HELP code_sandbox_active_sandboxes Running containers created by code-sandbox-mcp.\n")
+	fmt.Fprintf(w, "# TYPE code_sandbox_active_sandboxes gauge\n")
+	fmt.Fprintf(w, "code_sandbox_active_sandboxes %d\n", active)
+}
+
+func writeCounter(w io.Writer, name, help string, c *counter) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, c.n.Load())
+}
+
+func writeHistogram(w io.Writer, name, help string, h *histogram) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, le := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// StartMetricsServer serves the metrics at /metrics on CODE_SANDBOX_METRICS_ADDR, e.g. :9464 or
+// 127.0.0.1:9464. Nothing is served when it is unset. The server shuts down when ctx is cancelled.
+func StartMetricsServer(ctx context.Context) {
+	addr := strings.TrimSpace(os.Getenv("CODE_SANDBOX_METRICS_ADDR"))
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		scrapeCtx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(scrapeCtx, w)
+	})
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		logger.Info("serving metrics", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server failed", "addr", addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+// predefinedNetworks are the networks every daemon has. They aren't user-defined, so they have no
+// embedded DNS server and can't give the sandbox a name other containers resolve.
+var predefinedNetworks = map[string]bool{"bridge": true, "host": true, "none": true}
+
+// networkAliasPattern limits aliases to DNS names other containers can look up
+var networkAliasPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]{0,251}[A-Za-z0-9])?$`)
+
+// parseNetworks validates the networks argument, a list of existing user-defined networks the
+// sandbox joins, and network_aliases, the extra hostnames it is reachable by on each of them.
+// Duplicate networks are dropped.
+func parseNetworks(args map[string]interface{}) ([]string, []string, error) {
+	networks, err := stringListArg(args, "networks")
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := map[string]bool{}
+	joined := make([]string, 0, len(networks))
+	for _, name := range networks {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("networks entries must not be empty")
+		}
+		if predefinedNetworks[name] || strings.HasPrefix(name, "container:") {
+			return nil, nil, fmt.Errorf("networks only takes user-defined networks, not %q; use network for bridge or none", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			joined = append(joined, name)
+		}
+	}
+
+	aliases, err := stringListArg(args, "network_aliases")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(aliases) > 0 && len(joined) == 0 {
+		return nil, nil, fmt.Errorf("network_aliases needs networks to apply them to")
+	}
+	for _, alias := range aliases {
+		if !networkAliasPattern.MatchString(alias) {
+			return nil, nil, fmt.Errorf("invalid network alias %q: it must be a DNS name of letters, digits, '.' and '-'", alias)
+		}
+	}
+	return joined, aliases, nil
+}
+
+// maxNameResolutionEntries caps each of dns and extra_hosts, which end up in the sandbox's resolv.conf and hosts files
+const maxNameResolutionEntries = 32
+
+// parseNameResolution validates the dns argument, a list of DNS server IP addresses, and
+// extra_hosts, a list of hostname:ip entries added to /etc/hosts. IPv6 addresses follow the first
+// colon as is, e.g. db:fd00::5. Duplicates are dropped.
+func parseNameResolution(args map[string]interface{}) ([]string, []string, error) {
+	servers, err := stringListArg(args, "dns")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(servers) > maxNameResolutionEntries {
+		return nil, nil, fmt.Errorf("dns can have at most %d entries", maxNameResolutionEntries)
+	}
+	seen := map[string]bool{}
+	dns := make([]string, 0, len(servers))
+	for _, server := range servers {
+		ip := net.ParseIP(strings.TrimSpace(server))
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid dns server %q: it must be an IPv4 or IPv6 address", server)
+		}
+		if !seen[ip.String()] {
+			seen[ip.String()] = true
+			dns = append(dns, ip.String())
+		}
+	}
+
+	entries, err := stringListArg(args, "extra_hosts")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) > maxNameResolutionEntries {
+		return nil, nil, fmt.Errorf("extra_hosts can have at most %d entries", maxNameResolutionEntries)
+	}
+	seen = map[string]bool{}
+	hosts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		hostname, addr, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !networkAliasPattern.MatchString(hostname) {
+			return nil, nil, fmt.Errorf("invalid extra_hosts entry %q: it must be hostname:ip with a DNS name of letters, digits, '.' and '-'", entry)
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid extra_hosts entry %q: %q is not an IPv4 or IPv6 address", entry, addr)
+		}
+		host := hostname + ":" + ip.String()
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	if len(dns) == 0 {
+		dns = nil
+	}
+	if len(hosts) == 0 {
+		hosts = nil
+	}
+	return dns, hosts, nil
+}
+
+// stringListArg reads an optional array of strings, returning nil when it is unset
+func stringListArg(args map[string]interface{}, key string) ([]string, error) {
+	raw, ok := args[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", key)
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("each %s entry must be a string", key)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// checkNetworks makes sure every network the sandbox joins exists and is user-defined. Names and
+// IDs are matched exactly: the daemon's name filter matches substrings, so the whole list is read.
+func checkNetworks(ctx context.Context, cli DockerAPI, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	list, err := cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	for _, name := range names {
+		found := false
+		for _, n := range list {
+			if n.Name == name || n.ID == name {
+				if predefinedNetworks[n.Name] {
+					return fmt.Errorf("network %s is not a user-defined network, so other containers can't resolve the sandbox on it", name)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("network %s does not exist on the Docker daemon; create it with docker network create first", name)
+		}
+	}
+	return nil
+}
+
+// networkingConfig returns the endpoint of the network the sandbox is created on, when that is
+// one of the networks it joins, so its aliases apply from the start. Other networks are joined
+// by connectNetworks.
+func networkingConfig(opts *containerOptions) *network.NetworkingConfig {
+	for _, name := range opts.Networks {
+		if name == opts.Network {
+			return &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+				name: {Aliases: opts.NetworkAliases},
+			}}
+		}
+	}
+	return nil
+}
+
+// connectNetworks joins a created container to the networks it wasn't created on. This runs before
+// the container starts, so the sandbox can reach every network as soon as its command runs, and
+// also works with daemons whose API only takes one network at create time.
+func connectNetworks(ctx context.Context, cli DockerAPI, containerID string, opts *containerOptions) error {
+	for _, name := range opts.Networks {
+		if name == opts.Network {
+			continue
+		}
+		if err := cli.NetworkConnect(ctx, name, containerID, &network.EndpointSettings{Aliases: opts.NetworkAliases}); err != nil {
+			return fmt.Errorf("failed to connect the sandbox to network %s: %w", name, err)
+		}
+	}
+	return nil
+}
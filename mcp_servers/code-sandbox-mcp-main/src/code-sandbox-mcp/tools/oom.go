@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// oomExitCode is the exit code of a process killed with SIGKILL, which is how the kernel's OOM
+// killer ends a process that pushes its cgroup past the memory limit
+const oomExitCode = 128 + 9
+
+// oomEventGrace is how long after a SIGKILL exit the daemon is given to publish the matching oom
+// event, which can reach it after the exec has already been reported as exited
+const oomEventGrace = 500 * time.Millisecond
+
+// oomNote explains a SIGKILL exit of a run_once container when it ran out of memory, or returns ""
+// otherwise. Docker's OOM flag covers the whole container, which is the command's own here since
+// the container runs nothing else.
+func oomNote(ctx context.Context, cli DockerAPI, containerID string) string {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil || info.State == nil || !info.State.OOMKilled {
+		return ""
+	}
+	return oomMessage(info.HostConfig)
+}
+
+// execOOMNote explains a SIGKILL exit of an exec started at start when the container was OOM killed
+// while it ran, or returns "" otherwise. The container's OOM flag stays set until it restarts, so
+// the daemon's oom events are asked instead, and only one during the exec counts.
+func execOOMNote(ctx context.Context, cli DockerAPI, containerID string, start time.Time) string {
+	killed, err := oomKilledSince(ctx, cli, containerID, start)
+	if err != nil {
+		logger.Debug("failed to look up oom events", "container_id", containerID, "error", err)
+		return ""
+	}
+	if !killed {
+		return ""
+	}
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return oomMessage(nil)
+	}
+	return oomMessage(info.HostConfig)
+}
+
+// oomKilledSince reports whether the daemon saw an oom event of the container after since.
+// The daemon filters events by its own clock, so since is moved onto it using the daemon's
+// current time, which also bounds the query so it returns instead of following new events.
+func oomKilledSince(ctx context.Context, cli DockerAPI, containerID string, since time.Time) (bool, error) {
+	before := time.Now()
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return false, err
+	}
+	daemonNow, err := time.Parse(time.RFC3339Nano, info.SystemTime)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the daemon's time %q: %w", info.SystemTime, err)
+	}
+	offset := daemonNow.Sub(before.Add(time.Since(before) / 2))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages, errs := cli.Events(ctx, events.ListOptions{
+		Since: eventTimestamp(since.Add(offset)),
+		Until: eventTimestamp(daemonNow.Add(oomEventGrace)),
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("container", containerID),
+			filters.Arg("event", string(events.ActionOOM)),
+		),
+	})
+	for {
+		select {
+		case msg := <-messages:
+			if msg.Action == events.ActionOOM {
+				return true, nil
+			}
+		case err := <-errs:
+			if err == nil || errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+}
+
+// eventTimestamp formats t the way the events API takes since and until, as seconds with nanoseconds
+func eventTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// oomMessage is the note of an OOM kill, naming the memory limit of the container
+func oomMessage(hostConfig *container.HostConfig) string {
+	limit := "no limit"
+	if hostConfig != nil && hostConfig.Memory > 0 {
+		limit = fmt.Sprintf("limit %d MB", hostConfig.Memory/1024/1024)
+	}
+	return fmt.Sprintf("process killed due to out-of-memory (%s); raise memory_mb or reduce the program's memory use", limit)
+}
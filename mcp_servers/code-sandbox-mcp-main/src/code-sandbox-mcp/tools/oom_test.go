@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// oomFake makes f run "alloc" as a program the kernel OOM kills and "kill" as one killed with a
+// plain SIGKILL; anything else succeeds
+func oomFake(f *fakeDocker) {
+	f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+		switch strings.Join(opts.Cmd, " ") {
+		case "sh -c alloc":
+			f.oomKill(containerID)
+			return fakeExecResult{ExitCode: oomExitCode}
+		case "sh -c kill":
+			return fakeExecResult{ExitCode: oomExitCode}
+		}
+		return fakeExecResult{}
+	}
+}
+
+// TestOOMKillReported checks that a command the kernel OOM killed is reported as such, rather
+// than with a bare exit code, while a plain SIGKILL isn't, even after an earlier OOM kill left
+// the container's OOM flag set
+func TestOOMKillReported(t *testing.T) {
+	// The daemon's clock may be off from the server's, which the events query has to allow for
+	for _, offset := range []time.Duration{0, time.Hour, -time.Hour} {
+		f := newFakeDocker()
+		f.clockOffset = offset
+		oomFake(f)
+		id, _ := initializeSandbox(t, f, map[string]interface{}{"memory_mb": 64.0})
+
+		run := func(command string) commandResult {
+			var result commandResult
+			decodeResult(t, RunCommand, map[string]interface{}{"container_id": id, "command": command}, &result)
+			return result
+		}
+
+		if result := run("kill"); result.ExitCode != oomExitCode || result.OOMKilled || result.Note != "" {
+			t.Errorf("offset %v: plain SIGKILL: exit code %d, oom_killed %v, note %q", offset, result.ExitCode, result.OOMKilled, result.Note)
+		}
+
+		result := run("alloc")
+		if result.ExitCode != oomExitCode || !result.OOMKilled {
+			t.Fatalf("offset %v: OOM kill: exit code %d, oom_killed %v", offset, result.ExitCode, result.OOMKilled)
+		}
+		if !strings.Contains(result.Note, "process killed due to out-of-memory (limit 64 MB)") {
+			t.Errorf("offset %v: note = %q", offset, result.Note)
+		}
+
+		if result := run("kill"); result.OOMKilled || result.Note != "" {
+			t.Errorf("offset %v: SIGKILL after an OOM kill: oom_killed %v, note %q", offset, result.OOMKilled, result.Note)
+		}
+	}
+}
+
+// TestOOMKillOfOtherContainer checks that an oom event of another container isn't attributed to the command
+func TestOOMKillOfOtherContainer(t *testing.T) {
+	f := newFakeDocker()
+	id, _ := initializeSandbox(t, f, map[string]interface{}{})
+	other := f.addContainer("other", nil)
+	f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+		f.oomKill(other)
+		return fakeExecResult{ExitCode: oomExitCode}
+	}
+
+	var result commandResult
+	decodeResult(t, RunCommand, map[string]interface{}{"container_id": id, "command": "kill"}, &result)
+	if result.OOMKilled {
+		t.Errorf("another container's OOM kill was reported: %q", result.Note)
+	}
+}
+
+// TestOOMKillReportedByExec checks that the exec tool names the OOM kill in its output too
+func TestOOMKillReportedByExec(t *testing.T) {
+	f := newFakeDocker()
+	oomFake(f)
+	id, _ := initializeSandbox(t, f, map[string]interface{}{"memory_mb": 32.0})
+
+	text := callTool(t, Exec, map[string]interface{}{
+		"container_id": id,
+		"commands":     []interface{}{"alloc"},
+	}, false)
+	if !strings.Contains(text, "out-of-memory (limit 32 MB)") {
+		t.Errorf("output doesn't report the OOM kill:\n%s", text)
+	}
+}
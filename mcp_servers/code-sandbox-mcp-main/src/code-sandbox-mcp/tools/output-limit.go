@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// defaultMaxOutputBytes caps the output a tool returns when no max_output_bytes is requested, so a
+// chatty program can't flood the MCP channel. It can be changed with CODE_SANDBOX_MAX_OUTPUT_BYTES.
+var defaultMaxOutputBytes = int(envInt("CODE_SANDBOX_MAX_OUTPUT_BYTES", 1024*1024))
+
+// MaxOutputBytes returns the default cap on the output returned by a tool
+func MaxOutputBytes() int {
+	return defaultMaxOutputBytes
+}
+
+// maxOutputBytesArg reads the optional max_output_bytes argument, falling back to def when unset
+func maxOutputBytesArg(args map[string]interface{}, def int) (int, error) {
+	n, err := positiveNumberArg(args, "max_output_bytes", float64(def))
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// truncationNotice is the line that replaces the output left out of a stream
+func truncationNotice(omitted int64) string {
+	return fmt.Sprintf("... output truncated, %d bytes omitted", omitted)
+}
+
+// headWriter keeps the first limit bytes written to it and counts the rest without storing them.
+// Writes never fail, so the producer is drained to the end and doesn't block on a full pipe.
+type headWriter struct {
+	limit   int
+	buf     bytes.Buffer
+	omitted int64
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	room := w.limit - w.buf.Len()
+	if room >= len(p) {
+		w.buf.Write(p)
+		return len(p), nil
+	}
+	w.buf.Write(p[:room])
+	w.omitted += int64(len(p) - room)
+	return len(p), nil
+}
+
+// String returns the kept output, followed by a notice when some of it was left out
+func (w *headWriter) String() string {
+	if w.omitted == 0 {
+		return w.buf.String()
+	}
+	out := w.buf.String()
+	if out != "" && out[len(out)-1] != '\n' {
+		out += "\n"
+	}
+	return out + truncationNotice(w.omitted) + "\n"
+}
+
+// tailWriter keeps the last limit bytes written to it and counts the earlier ones it dropped.
+// It holds at most twice the limit before discarding, so memory stays bounded however long the stream is.
+type tailWriter struct {
+	limit   int
+	buf     []byte
+	omitted int64
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if over := len(w.buf) - w.limit; over > w.limit {
+		w.buf = append(w.buf[:0], w.buf[over:]...)
+		w.omitted += int64(over)
+	}
+	return len(p), nil
+}
+
+// String returns the kept output, preceded by a notice when earlier output was left out
+func (w *tailWriter) String() string {
+	out := w.buf
+	omitted := w.omitted
+	if over := len(out) - w.limit; over > 0 {
+		out = out[over:]
+		omitted += int64(over)
+	}
+	if omitted == 0 {
+		return string(out)
+	}
+	return fmt.Sprintf("%s before this point\n%s", truncationNotice(omitted), out)
+}
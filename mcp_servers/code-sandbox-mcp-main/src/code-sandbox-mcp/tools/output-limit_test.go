@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		writes  []string
+		kept    string
+		omitted int64
+	}{
+		{"under the limit", 10, []string{"abc", "def"}, "abcdef", 0},
+		{"exactly the limit", 6, []string{"abc", "def"}, "abcdef", 0},
+		{"one byte over", 5, []string{"abc", "def"}, "abcde", 1},
+		{"limit reached before a write", 3, []string{"abc", "def"}, "abc", 3},
+		{"zero limit", 0, []string{"abc"}, "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &headWriter{limit: tt.limit}
+			for _, s := range tt.writes {
+				// Writes always report the whole buffer, so the producer keeps going
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if got := w.buf.String(); got != tt.kept {
+				t.Errorf("kept = %q, want %q", got, tt.kept)
+			}
+			if w.omitted != tt.omitted {
+				t.Errorf("omitted = %d, want %d", w.omitted, tt.omitted)
+			}
+		})
+	}
+}
+
+func TestHeadWriterString(t *testing.T) {
+	w := &headWriter{limit: 4}
+	w.Write([]byte("ab\n"))
+	if got := w.String(); got != "ab\n" {
+		t.Errorf("untruncated output = %q", got)
+	}
+	w.Write([]byte("cdef"))
+	if want := "ab\nc\n" + truncationNotice(3) + "\n"; w.String() != want {
+		t.Errorf("truncated output = %q, want %q", w.String(), want)
+	}
+}
+
+func TestTailWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		writes []string
+		want   string
+	}{
+		{"under the limit", 10, []string{"abc", "def"}, "abcdef"},
+		{"exactly the limit", 6, []string{"abc", "def"}, "abcdef"},
+		{"one byte over", 5, []string{"abc", "def"}, truncationNotice(1) + " before this point\nbcdef"},
+		{"past twice the limit", 2, []string{"abc", "def", "g"}, truncationNotice(5) + " before this point\nfg"},
+		{"one large write", 3, []string{strings.Repeat("x", 100) + "end"}, truncationNotice(100) + " before this point\nend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &tailWriter{limit: tt.limit}
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTailWriterStaysBounded checks that a long stream never holds more than twice the limit
+func TestTailWriterStaysBounded(t *testing.T) {
+	w := &tailWriter{limit: 8}
+	for i := 0; i < 1000; i++ {
+		w.Write([]byte("0123456"))
+		if len(w.buf) > 2*w.limit {
+			t.Fatalf("buffer grew to %d bytes after %d writes", len(w.buf), i+1)
+		}
+	}
+	if got, want := w.String(), truncationNotice(7000-8)+" before this point\n"+"60123456"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// pauseResult is the structured result of pausing or unpausing a sandbox
+type pauseResult struct {
+	ContainerID string `json:"container_id"`
+	Status      string `json:"status"`
+	Paused      bool   `json:"paused"`
+}
+
+// PauseContainer freezes every process in a container, keeping its memory and filesystem as they are
+func PauseContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return setPausedTool(ctx, request, true)
+}
+
+// UnpauseContainer resumes the processes of a container frozen by PauseContainer
+func UnpauseContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return setPausedTool(ctx, request, false)
+}
+
+// setPausedTool is the shared body of the pause and unpause tools
+func setPausedTool(ctx context.Context, request mcp.CallToolRequest, pause bool) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := setContainerPaused(ctx, containerID, pause)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// setContainerPaused pauses or unpauses a managed container and reports the state it ends up in.
+// Pausing a container that isn't running, or unpausing one that isn't paused, is an error rather
+// than a no-op, so the caller learns that its idea of the sandbox's state was wrong.
+func setContainerPaused(ctx context.Context, containerID string, pause bool) (*pauseResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	action := "unpause"
+	if pause {
+		action = "pause"
+	}
+
+	// Like sandbox_stop, only touch containers this server created
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to %s it", containerID, action)
+	}
+	if info.State == nil {
+		return nil, fmt.Errorf("container %s has no state to %s", containerID, action)
+	}
+
+	switch {
+	case pause && info.State.Paused:
+		return nil, fmt.Errorf("container %s is already paused", containerID)
+	case pause && !info.State.Running:
+		return nil, fmt.Errorf("container %s is not running (state: %s), so it can't be paused", containerID, info.State.Status)
+	case !pause && !info.State.Paused:
+		return nil, fmt.Errorf("container %s is not paused (state: %s)", containerID, info.State.Status)
+	}
+
+	if pause {
+		err = cli.ContainerPause(ctx, containerID)
+	} else {
+		err = cli.ContainerUnpause(ctx, containerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s container: %w", action, err)
+	}
+
+	info, err = cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	return &pauseResult{
+		ContainerID: info.ID,
+		Status:      info.State.Status,
+		Paused:      info.State.Paused,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// platformPattern matches os/arch[/variant] platform strings such as linux/amd64 or linux/arm/v7
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_-]+(/[a-z0-9]+)?$`)
+
+// archAliases maps the architecture names reported by uname, and accepted in platform strings,
+// to the names used in image manifests
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armhf":   "arm",
+	"armv7l":  "arm",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// normalizeArch returns the manifest name of an architecture
+func normalizeArch(arch string) string {
+	if alias, ok := archAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+// parsePlatform validates the platform argument, returning nil when it is unset so the daemon
+// picks the host's platform
+func parsePlatform(arg interface{}) (*ocispec.Platform, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	s, ok := arg.(string)
+	if !ok {
+		return nil, fmt.Errorf("platform must be a string such as linux/amd64")
+	}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return nil, nil
+	}
+	if !platformPattern.MatchString(s) {
+		return nil, fmt.Errorf("platform %q must be in os/arch[/variant] form, e.g. linux/amd64 or linux/arm64", s)
+	}
+
+	parts := strings.Split(s, "/")
+	p := &ocispec.Platform{OS: parts[0], Architecture: normalizeArch(parts[1])}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// platformString formats a platform the way it is given to docker --platform
+func platformString(p *ocispec.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// imagePlatform returns the platform a local image was built for
+func imagePlatform(info image.InspectResponse) *ocispec.Platform {
+	return &ocispec.Platform{OS: info.Os, Architecture: normalizeArch(info.Architecture), Variant: info.Variant}
+}
+
+// platformMatches reports whether an image built for have can be used for want. A request
+// without a variant accepts any variant of the architecture.
+func platformMatches(have, want *ocispec.Platform) bool {
+	return have.OS == want.OS && have.Architecture == want.Architecture &&
+		(want.Variant == "" || have.Variant == want.Variant)
+}
+
+// checkPlatform makes sure the Docker host can run containers of the requested platform at all.
+// Other architectures run under emulation, which needs qemu binfmt handlers on the host that the
+// daemon doesn't report, so a missing emulator only shows once the sandbox starts.
+func checkPlatform(ctx context.Context, cli DockerAPI, p *ocispec.Platform) error {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query the Docker daemon's platform: %w", err)
+	}
+	if info.OSType != "" && info.OSType != p.OS {
+		return fmt.Errorf("platform %s can't run on this Docker host, which runs %s containers", platformString(p), info.OSType)
+	}
+	return nil
+}
+
+// emulationError explains a sandbox of a foreign platform failing to start because the host
+// can't execute its binaries
+func emulationError(p *ocispec.Platform, err error) error {
+	return fmt.Errorf("platform %s differs from the Docker host's and needs emulation, which isn't set up on it; "+
+		"install the qemu binfmt handlers, e.g. with docker run --privileged --rm tonistiigi/binfmt --install all: %w", platformString(p), err)
+}
+
+// isExecFormatError reports whether err comes from the kernel refusing to run a binary built for another architecture
+func isExecFormatError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "exec format error")
+}
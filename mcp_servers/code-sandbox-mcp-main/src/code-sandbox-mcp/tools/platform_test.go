@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestPlatformForwardedToCreate checks that the requested platform reaches ContainerCreate, and
+// that no platform is passed when none was asked for, leaving the choice to the daemon
+func TestPlatformForwardedToCreate(t *testing.T) {
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{"platform": "linux/" + runtime.GOARCH})
+	if p := created.Platform; p == nil || p.OS != "linux" || p.Architecture != runtime.GOARCH {
+		t.Errorf("create platform = %+v, want linux/%s", p, runtime.GOARCH)
+	}
+
+	_, created = initializeSandbox(t, newFakeDocker(), map[string]interface{}{})
+	if created.Platform != nil {
+		t.Errorf("create platform = %+v, want none", created.Platform)
+	}
+}
+
+// TestPlatformMissingLocally checks that an image built for another platform isn't used in its place
+func TestPlatformMissingLocally(t *testing.T) {
+	arch := "riscv64"
+	if runtime.GOARCH == arch {
+		arch = "s390x"
+	}
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	text := callTool(t, InitializeEnvironment, map[string]interface{}{"platform": "linux/" + arch}, true)
+	if !strings.Contains(text, "is only available for linux/"+runtime.GOARCH) {
+		t.Errorf("error = %q, want the local image's platform named", text)
+	}
+	if len(f.creates) != 0 {
+		t.Error("a container was created")
+	}
+}
+
+// TestPlatformWithoutEmulation checks that a foreign binary failing to start is explained as
+// missing emulation, and the half-created sandbox is removed
+func TestPlatformWithoutEmulation(t *testing.T) {
+	f := newFakeDocker()
+	f.startErrs = []error{errors.New("failed to create task for container: exec /bin/sleep: exec format error")}
+	useFakeDocker(t, f)
+
+	text := callTool(t, InitializeEnvironment, map[string]interface{}{"platform": "linux/" + runtime.GOARCH}, true)
+	if !strings.Contains(text, "needs emulation") || !strings.Contains(text, "binfmt") {
+		t.Errorf("error = %q, want an explanation of the missing emulation", text)
+	}
+	if len(f.containers) != 0 {
+		t.Error("the sandbox that failed to start was left behind")
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		arg  interface{}
+		want string
+		err  bool
+	}{
+		{"linux/amd64", "linux/amd64", false},
+		{"linux/arm64/v8", "linux/arm64/v8", false},
+		{"windows", "", true},
+		{42.0, "", true},
+	}
+	for _, tt := range tests {
+		p, err := parsePlatform(tt.arg)
+		if (err != nil) != tt.err {
+			t.Errorf("parsePlatform(%v) error = %v, want error %v", tt.arg, err, tt.err)
+			continue
+		}
+		if err == nil && platformString(p) != tt.want {
+			t.Errorf("parsePlatform(%v) = %s, want %s", tt.arg, platformString(p), tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+)
+
+// The container engines that can serve the Docker API the tools talk to
+const (
+	engineDocker = "docker"
+	enginePodman = "podman"
+)
+
+// engineCache remembers which engine serves the current client, which doesn't change while the client exists
+var engineCache struct {
+	sync.Mutex
+	cli    DockerAPI
+	engine string
+}
+
+// versionEngine tells Podman's Docker-compatible service apart from dockerd by the components it reports
+func versionEngine(version types.Version) string {
+	for _, c := range version.Components {
+		if strings.EqualFold(c.Name, "Podman Engine") {
+			return enginePodman
+		}
+	}
+	if strings.Contains(strings.ToLower(version.Platform.Name), "podman") {
+		return enginePodman
+	}
+	return engineDocker
+}
+
+// containerEngine returns the engine behind cli, asking it once. If it can't be asked, dockerd is
+// assumed without caching the answer, so the next call asks again.
+func containerEngine(ctx context.Context, cli DockerAPI) string {
+	engineCache.Lock()
+	defer engineCache.Unlock()
+	if engineCache.cli == cli && engineCache.engine != "" {
+		return engineCache.engine
+	}
+
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return engineDocker
+	}
+	engineCache.cli, engineCache.engine = cli, versionEngine(version)
+	return engineCache.engine
+}
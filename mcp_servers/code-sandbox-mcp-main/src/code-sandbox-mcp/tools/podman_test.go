@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+)
+
+// podmanVersion is what Podman's Docker-compatible service reports as its version
+var podmanVersion = types.Version{
+	Version:    "5.2.0",
+	APIVersion: "1.41",
+	Platform:   struct{ Name string }{Name: "linux/amd64/fedora-40"},
+	Components: []types.ComponentVersion{{Name: "Podman Engine", Version: "5.2.0"}},
+}
+
+func TestVersionEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		version types.Version
+		want    string
+	}{
+		{"dockerd", types.Version{Version: "27.0.0", Components: []types.ComponentVersion{{Name: "Engine"}, {Name: "containerd"}}}, engineDocker},
+		{"podman component", podmanVersion, enginePodman},
+		{"podman platform", types.Version{Platform: struct{ Name string }{Name: "Podman Engine"}}, enginePodman},
+		{"nothing reported", types.Version{}, engineDocker},
+	}
+	for _, tt := range tests {
+		if got := versionEngine(tt.version); got != tt.want {
+			t.Errorf("%s: engine = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newFakePodman returns a fake daemon that reports itself like Podman
+func newFakePodman() *fakeDocker {
+	f := newFakeDocker()
+	f.version = podmanVersion
+	f.info.Driver = "overlay"
+	f.info.DriverStatus = [][2]string{{"Backing Filesystem", "xfs"}}
+	f.info.Runtimes = map[string]system.RuntimeWithStatus{"crun": {}, "runc": {}}
+	return f
+}
+
+// TestPodmanLifecycle checks that a sandbox can be created, used and removed through a Podman
+// socket, and that the engine is reported
+func TestPodmanLifecycle(t *testing.T) {
+	f := newFakePodman()
+	f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+		return fakeExecResult{Stdout: "from podman\n"}
+	}
+	id, created := initializeSandbox(t, f, map[string]interface{}{"runtime": "crun"})
+	if created.HostConfig.Runtime != "crun" || created.Config.User != fmt.Sprintf("%d:%d", sandboxUID, sandboxGID) {
+		t.Errorf("runtime = %q, user = %q, want crun as the sandbox user", created.HostConfig.Runtime, created.Config.User)
+	}
+
+	resetCapabilities := func() {
+		capabilitiesCache.Lock()
+		capabilitiesCache.report = nil
+		capabilitiesCache.Unlock()
+	}
+	resetCapabilities()
+	t.Cleanup(resetCapabilities)
+	var report serverCapabilities
+	decodeResult(t, GetServerCapabilities, map[string]interface{}{}, &report)
+	if report.Engine != enginePodman || !report.StorageQuota {
+		t.Errorf("engine = %s, storage quota = %v, want podman with quotas", report.Engine, report.StorageQuota)
+	}
+
+	var result commandResult
+	decodeResult(t, RunCommand, map[string]interface{}{"container_id": id, "command": "id -u"}, &result)
+	if result.Stdout != "from podman\n" {
+		t.Errorf("exec stdout = %q", result.Stdout)
+	}
+	// The exec runs as the container's user, which Podman resolves like dockerd
+	if opts := f.execOptions[len(f.execOptions)-1]; opts.User != "" || !strings.Contains(strings.Join(opts.Cmd, " "), "id -u") {
+		t.Errorf("exec ran %q as %q", opts.Cmd, opts.User)
+	}
+
+	callTool(t, StopContainer, map[string]interface{}{"container_id": id}, false)
+	if len(f.containers) != 0 {
+		t.Error("the sandbox wasn't removed")
+	}
+}
+
+// TestPodmanStorageQuota checks that storage_mb follows Podman's driver rules rather than dockerd's
+func TestPodmanStorageQuota(t *testing.T) {
+	_, created := initializeSandbox(t, newFakePodman(), map[string]interface{}{"storage_mb": 10.0})
+	if created.HostConfig.StorageOpt["size"] != "10240k" {
+		t.Errorf("StorageOpt = %v", created.HostConfig.StorageOpt)
+	}
+
+	f := newFakePodman()
+	f.info.Driver = "vfs"
+	useFakeDocker(t, f)
+	text := callTool(t, InitializeEnvironment, map[string]interface{}{"storage_mb": 10.0}, true)
+	if !strings.Contains(text, `not supported by the Podman storage driver "vfs"`) {
+		t.Errorf("error = %q", text)
+	}
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// pruneImagesResult summarises the images removed by sandbox_prune_images
+type pruneImagesResult struct {
+	Removed        int      `json:"removed"`
+	RemovedIDs     []string `json:"removed_image_ids"`
+	Untagged       []string `json:"untagged"`
+	SpaceReclaimed uint64   `json:"space_reclaimed_bytes"`
+}
+
+// PruneImages removes unused images created by this server. Dangling images carrying imageLabel are
+// always removed; tagged ones only with include_committed, and unlabelled dangling images only with
+// include_untracked. Images still used by a container are never removed.
+func PruneImages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	includeCommitted, _ := request.Params.Arguments["include_committed"].(bool)
+	includeUntracked, _ := request.Params.Arguments["include_untracked"].(bool)
+
+	result, err := pruneImages(ctx, includeCommitted, includeUntracked)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	summary := fmt.Sprintf("Removed %d image(s), reclaimed %d bytes", result.Removed, result.SpaceReclaimed)
+	return newToolResultJSONWithSummary(result, summary)
+}
+
+// pruneImages runs one prune per selection, since a single prune can't combine "dangling or labelled"
+func pruneImages(ctx context.Context, includeCommitted bool, includeUntracked bool) (*pruneImagesResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	dangling := filters.NewArgs(filters.Arg("dangling", "true"))
+	if !includeUntracked {
+		dangling.Add("label", imageLabel+"=true")
+	}
+	selections := []filters.Args{dangling}
+	if includeCommitted {
+		// dangling=false makes the prune consider every unused image, tagged or not
+		selections = append(selections, filters.NewArgs(
+			filters.Arg("dangling", "false"),
+			filters.Arg("label", imageLabel+"=true"),
+		))
+	}
+
+	result := &pruneImagesResult{RemovedIDs: []string{}, Untagged: []string{}}
+	for _, selection := range selections {
+		report, err := cli.ImagesPrune(ctx, selection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune images: %w", err)
+		}
+		for _, deleted := range report.ImagesDeleted {
+			if deleted.Deleted != "" {
+				result.RemovedIDs = append(result.RemovedIDs, deleted.Deleted)
+			}
+			if deleted.Untagged != "" {
+				result.Untagged = append(result.Untagged, deleted.Untagged)
+			}
+		}
+		result.SpaceReclaimed += report.SpaceReclaimed
+	}
+	result.Removed = len(result.RemovedIDs)
+	return result, nil
+}
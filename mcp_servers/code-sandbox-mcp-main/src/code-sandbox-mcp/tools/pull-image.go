@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// pullProgress is a snapshot of an image pull, reported whenever a layer changes state or the
+// overall percentage moves
+type pullProgress struct {
+	// Layer is the ID of the layer the update is about, and Status what happened to it
+	Layer  string
+	Status string
+	// Percent is the share of the bytes of every layer seen so far that has been downloaded
+	Percent float64
+}
+
+// pullImage pulls an image from its registry and waits for the pull to finish, calling onProgress,
+// when non-nil, as the layers download. Status lines from the registry are logged at debug level.
+func pullImage(ctx context.Context, cli DockerAPI, ref string, platform *ocispec.Platform, onProgress func(pullProgress)) error {
+	auth, err := registryAuthFor(ref)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, err)
+	}
+
+	start := time.Now()
+	opts := image.PullOptions{RegistryAuth: auth}
+	if platform != nil {
+		opts.Platform = platformString(platform)
+	}
+	reader, err := cli.ImagePull(ctx, ref, opts)
+	if err != nil {
+		return pullError(ref, err)
+	}
+	defer reader.Close()
+
+	type layer struct{ current, total int64 }
+	layers := map[string]*layer{}
+	lastPercent := -1.0
+
+	// Pull failures are reported inside the progress stream, not by ImagePull itself
+	dec := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to pull image %s: %w", ref, err)
+		}
+		if msg.Error != nil {
+			return pullError(ref, msg.Error)
+		}
+		if msg.ID == "" {
+			if msg.Status != "" {
+				logger.Debug("image pull status", "image", ref, "status", msg.Status)
+			}
+			continue
+		}
+
+		l, seen := layers[msg.ID]
+		if !seen {
+			l = &layer{}
+			layers[msg.ID] = l
+		}
+		switch {
+		case msg.Status == "Downloading" && msg.Progress != nil && msg.Progress.Total > 0:
+			l.current, l.total = msg.Progress.Current, msg.Progress.Total
+		case msg.Status == "Download complete" || msg.Status == "Pull complete" || msg.Status == "Already exists":
+			if l.total == 0 {
+				// Layers that are already present never report a size; count them as one finished byte
+				l.total = 1
+			}
+			l.current = l.total
+		}
+
+		if onProgress == nil {
+			continue
+		}
+		var current, total int64
+		for _, l := range layers {
+			current += l.current
+			total += l.total
+		}
+		percent := 0.0
+		if total > 0 {
+			percent = math.Floor(float64(current) * 100 / float64(total))
+		}
+		// Byte counts arrive many times a second, so only whole-percent steps are reported for them
+		if msg.Progress != nil && msg.Progress.Total > 0 && percent == lastPercent {
+			continue
+		}
+		lastPercent = percent
+		onProgress(pullProgress{Layer: msg.ID, Status: msg.Status, Percent: percent})
+	}
+
+	logger.Info("image pulled", "image", ref, "duration", time.Since(start))
+	return nil
+}
+
+// pullError explains a failed pull, calling out registries that require credentials, which
+// otherwise surface as an opaque message from the stream
+func pullError(ref string, err error) error {
+	msg := strings.ToLower(err.Error())
+	var jsonErr *jsonmessage.JSONError
+	authFailure := errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err) ||
+		(errors.As(err, &jsonErr) && jsonErr.Code == 401)
+	for _, hint := range []string{"unauthorized", "authentication required", "no basic auth credentials", "access denied", "denied:"} {
+		authFailure = authFailure || strings.Contains(msg, hint)
+	}
+	if authFailure {
+		return fmt.Errorf("failed to pull image %s: authentication required; the registry refused the request, "+
+			"so the image is private or doesn't exist. Private registries need CODE_SANDBOX_REGISTRY_AUTH "+
+			"or CODE_SANDBOX_REGISTRY_AUTH_DOCKER_CONFIG (registry said: %v)", ref, err)
+	}
+	return fmt.Errorf("failed to pull image %s: %w", ref, err)
+}
+
+// imageDigest returns the repo digest of a local image, such as sha256:..., or "" for images
+// that were built locally and never pushed or pulled
+func imageDigest(ctx context.Context, cli DockerAPI, ref string) string {
+	info, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return ""
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ""
+	}
+	for _, rd := range info.RepoDigests {
+		repo, d, ok := strings.Cut(rd, "@")
+		if !ok {
+			continue
+		}
+		if r, err := reference.ParseNormalizedNamed(repo); err == nil && r.Name() == named.Name() {
+			return d
+		}
+	}
+	return ""
+}
+
+// pullProgressNotifier returns a pull callback that sends each update to the client as a
+// notifications/progress message, with the overall percentage as progress out of a total of 100
+// and the layer it is about. Notifications are best effort, like those of sandbox_exec_stream.
+func pullProgressNotifier(ctx context.Context, token mcp.ProgressToken) func(pullProgress) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+
+	warned := false
+	return func(p pullProgress) {
+		err := srv.SendNotificationToClient(ctx, "notifications/progress", map[string]interface{}{
+			"progressToken": token,
+			"progress":      p.Percent,
+			"total":         100,
+			"message":       fmt.Sprintf("Pulling %s: %s", p.Layer, p.Status),
+			"layer":         p.Layer,
+		})
+		if err != nil && !warned {
+			logger.Warn("failed to send pull progress notification", "error", err)
+			warned = true
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// readFileResult is the structured result of reading a file from a container
+type readFileResult struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+	// OmittedBytes counts the bytes past max_output_bytes that were left out of Content
+	OmittedBytes int64 `json:"omitted_bytes,omitempty"`
+}
+
+// ReadFile reads a single file from a container's filesystem and returns its contents
+func ReadFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	path, ok := request.Params.Arguments["path"].(string)
+	if !ok || path == "" {
+		return newToolResultError("path is required"), nil
+	}
+
+	// If the path doesn't start with /, resolve it against the container working dir
+	path, err = resolveContainerPath(ctx, containerID, path)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error resolving path: %v", err)), nil
+	}
+
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	data, size, err := readFileFromContainer(ctx, containerID, path, maxOutputBytes)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error reading file: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+
+	// Text files are returned as-is, anything else is base64-encoded so no bytes are lost
+	result := readFileResult{
+		Path:         path,
+		Size:         size,
+		OmittedBytes: size - int64(len(data)),
+	}
+	if text := trimPartialRune(data, result.OmittedBytes > 0); utf8.Valid(text) {
+		result.Encoding = "utf8"
+		result.Content = string(text)
+		if result.OmittedBytes > 0 {
+			result.OmittedBytes = size - int64(len(text))
+			result.Content += "\n" + truncationNotice(result.OmittedBytes) + "\n"
+		}
+	} else {
+		result.Encoding = "base64"
+		result.Content = base64.StdEncoding.EncodeToString(data)
+	}
+
+	return newToolResultJSON(result)
+}
+
+// trimPartialRune drops the bytes of a UTF-8 character cut in half at the end of truncated data,
+// so truncating a text file doesn't make it look binary
+func trimPartialRune(data []byte, truncated bool) []byte {
+	if !truncated {
+		return data
+	}
+	for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+// readFileFromContainer returns up to maxBytes of the contents of a single regular file in the
+// container, together with the file's full size. Bytes past maxBytes are never read into memory.
+func readFileFromContainer(ctx context.Context, containerID string, srcPath string, maxBytes int) ([]byte, int64, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Docker returns the requested path as a tar stream
+	reader, stat, err := getArchive(ctx, cli, containerID, srcPath)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, 0, fmt.Errorf("file not found: %s", srcPath)
+		}
+		return nil, 0, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer reader.Close()
+
+	if stat.Mode.IsDir() {
+		return nil, 0, fmt.Errorf("%s is a directory; read the individual files inside it instead", srcPath)
+	}
+
+	// Read the first (and should be only) file from the archive
+	tr := tar.NewReader(reader)
+	header, err := tr.Next()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read tar header: %w", err)
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil, 0, fmt.Errorf("%s is not a regular file", srcPath)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(tr, int64(maxBytes)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file content: %w", err)
+	}
+
+	return data, header.Size, nil
+}
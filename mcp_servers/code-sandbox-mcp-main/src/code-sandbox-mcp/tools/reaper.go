@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	// defaultIdleTTL is how long a sandbox may go without any activity before the reaper removes it
+	defaultIdleTTL = 30 * time.Minute
+	// defaultReaperInterval is how often the reaper scans for idle sandboxes
+	defaultReaperInterval = time.Minute
+	// defaultOrphanTTL is how long a sandbox of another server session may go without activity
+	// recorded by this process before the reaper removes it
+	defaultOrphanTTL = 24 * time.Hour
+)
+
+// activity records the last time each container was used by a tool call
+var activity = struct {
+	sync.Mutex
+	lastActive map[string]time.Time
+}{lastActive: make(map[string]time.Time)}
+
+// touchContainer records that the container was just used, postponing its idle removal
+func touchContainer(containerID string) {
+	activity.Lock()
+	defer activity.Unlock()
+	activity.lastActive[containerID] = time.Now()
+}
+
+// forgetContainer drops the activity records, in-memory metadata, warm pool entry, background
+// commands and shell sessions of a removed container, including activity kept under a short ID
+func forgetContainer(containerID string) {
+	forgetMetadata(containerID)
+	forgetBackgroundExecs(containerID)
+	forgetSessions(containerID)
+	forgetPooled(containerID)
+	activity.Lock()
+	defer activity.Unlock()
+	for id := range activity.lastActive {
+		if strings.HasPrefix(containerID, id) {
+			delete(activity.lastActive, id)
+		}
+	}
+}
+
+// lastActivity returns the most recent time the container was used. Tools may refer to a
+// container by a short ID prefix, so every matching record is considered. Containers with no
+// recorded activity (e.g. created before the server restarted) fall back to their creation time.
+func lastActivity(c container.Summary) time.Time {
+	last := time.Unix(c.Created, 0)
+	if createdAt, err := time.Parse(time.RFC3339, c.Labels[createdAtLabel]); err == nil && createdAt.After(last) {
+		last = createdAt
+	}
+
+	activity.Lock()
+	defer activity.Unlock()
+	for id, t := range activity.lastActive {
+		if strings.HasPrefix(c.ID, id) && t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// idleTTL returns how long the container may stay idle: its own idle_timeout_seconds when it was
+// created with one, and def otherwise. Zero means it is never removed for being idle.
+func idleTTL(c container.Summary, def time.Duration) time.Duration {
+	seconds, err := strconv.ParseInt(c.Labels[idleTimeoutLabel], 10, 64)
+	if err != nil || seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// StartReaper starts a background goroutine that removes managed containers which have been
+// idle for longer than CODE_SANDBOX_IDLE_TTL (default 30m), or than their own idle_timeout_seconds,
+// scanning every CODE_SANDBOX_REAPER_INTERVAL (default 1m). Setting CODE_SANDBOX_IDLE_TTL to 0
+// only spares the sandboxes without an idle timeout of their own. Sandboxes created by another
+// server session are only removed after CODE_SANDBOX_ORPHAN_TTL (default 24h), 0 keeping them.
+// The goroutine stops when ctx is cancelled.
+func StartReaper(ctx context.Context) {
+	ttl := envDuration("CODE_SANDBOX_IDLE_TTL", defaultIdleTTL)
+	orphanTTL := envDuration("CODE_SANDBOX_ORPHAN_TTL", defaultOrphanTTL)
+	interval := envDuration("CODE_SANDBOX_REAPER_INTERVAL", defaultReaperInterval)
+	if interval == 0 {
+		interval = defaultReaperInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reapIdleContainers(ctx, ttl, orphanTTL)
+			}
+		}
+	}()
+}
+
+// reapIdleContainers force-removes every managed container that has been idle for longer than its
+// idle timeout, which is ttl unless the container has one of its own. Activity is only recorded in
+// memory, so another server sharing the daemon may be using a sandbox this process sees as idle;
+// sandboxes of other server sessions, e.g. left behind by a server that exited without cleaning
+// up, get orphanTTL instead.
+func reapIdleContainers(ctx context.Context, ttl, orphanTTL time.Duration) {
+	containers, err := listManagedContainers(ctx, "")
+	if err != nil {
+		logger.Error("reaper failed to list sandboxes", "error", err)
+		return
+	}
+
+	cli, err := DockerClient()
+	if err != nil {
+		logger.Error("reaper failed to list sandboxes", "error", err)
+		return
+	}
+
+	for _, c := range containers {
+		// Pooled sandboxes are idle by design until they are handed out
+		if isPooled(c.ID) {
+			continue
+		}
+		// A command started with sandbox_exec_start is activity for as long as it runs
+		if hasRunningBackgroundExec(c.ID) {
+			continue
+		}
+		limit := idleTTL(c, ttl)
+		if c.Labels[serverSessionLabel] != serverSessionID {
+			limit = orphanTTL
+		}
+		idle := time.Since(lastActivity(c))
+		if limit == 0 || idle < limit {
+			continue
+		}
+		if err := withDockerRetry(ctx, "remove container", func() error {
+			return cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{
+				RemoveVolumes: true,
+				Force:         true,
+			})
+		}); err != nil {
+			logger.Error("reaper failed to remove idle container", "container_id", c.ID, "error", err)
+			continue
+		}
+		forgetContainer(c.ID)
+		containersStopped.Inc()
+		logger.Info("container removed after inactivity", "container_id", c.ID, "idle", idle.Round(time.Second), "idle_timeout", limit)
+	}
+}
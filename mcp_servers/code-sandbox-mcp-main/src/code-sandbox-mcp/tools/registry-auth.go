@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// Registry credentials are opt-in: pulls are anonymous unless CODE_SANDBOX_REGISTRY_AUTH holds
+// credentials or CODE_SANDBOX_REGISTRY_AUTH_DOCKER_CONFIG points the server at the Docker CLI
+// config. Credentials are never logged, not even when they fail to parse.
+
+// dockerHubRegistry is the key Docker Hub credentials are stored under by docker login
+const dockerHubRegistry = "https://index.docker.io/v1/"
+
+// registryCredentials maps a registry host, such as ghcr.io, to its credentials. Entries use the
+// format of the auths section of ~/.docker/config.json: a base64 "user:password" auth, or a
+// username and password, or an identity token.
+type registryCredentials map[string]registry.AuthConfig
+
+var (
+	// envRegistryAuth holds the credentials given in CODE_SANDBOX_REGISTRY_AUTH
+	envRegistryAuth = parseRegistryAuthEnv("CODE_SANDBOX_REGISTRY_AUTH")
+	// useDockerConfig enables reading credentials from the Docker CLI config file
+	useDockerConfig = envBool("CODE_SANDBOX_REGISTRY_AUTH_DOCKER_CONFIG", false)
+)
+
+// parseRegistryAuthEnv reads registry credentials from the named environment variable, holding
+// a JSON object keyed by registry host. An unset variable yields no credentials.
+func parseRegistryAuthEnv(name string) registryCredentials {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	var creds registryCredentials
+	if err := json.Unmarshal([]byte(value), &creds); err != nil {
+		// The JSON error could quote part of the value, so only the variable is named
+		logger.Warn("ignoring invalid setting: it must be a JSON object mapping registry hosts to credentials", "name", name)
+		return nil
+	}
+	return creds
+}
+
+// dockerConfigCredentials reads the auths section of the Docker CLI config, in $DOCKER_CONFIG or
+// ~/.docker. Credential helpers (credsStore, credHelpers) aren't supported: they only hand out
+// credentials through external programs.
+func dockerConfigCredentials() (registryCredentials, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the Docker config: %w", err)
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the Docker config: %w", err)
+	}
+	var config struct {
+		Auths registryCredentials `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s", filepath.Join(dir, "config.json"))
+	}
+	return config.Auths, nil
+}
+
+// registryAuthFor returns the encoded X-Registry-Auth value for the registry ref is pulled from,
+// or "" when no credentials are configured for it. Credentials from the environment
+// take precedence over those in the Docker config.
+func registryAuthFor(ref string) (string, error) {
+	if envRegistryAuth == nil && !useDockerConfig {
+		return "", nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	host := reference.Domain(named)
+
+	sources := []registryCredentials{envRegistryAuth}
+	if useDockerConfig {
+		fromConfig, err := dockerConfigCredentials()
+		if err != nil {
+			return "", err
+		}
+		sources = append(sources, fromConfig)
+	}
+	for _, creds := range sources {
+		if auth, ok := creds.lookup(host); ok {
+			return encodeRegistryAuth(auth, host)
+		}
+	}
+	return "", nil
+}
+
+// lookup finds the credentials for host, accepting the different spellings of a registry used as
+// keys, such as https://ghcr.io or the Docker Hub index URL for docker.io
+func (c registryCredentials) lookup(host string) (registry.AuthConfig, bool) {
+	for key, auth := range c {
+		k := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+		k, _, _ = strings.Cut(k, "/")
+		if k == host || (host == "docker.io" && (k == "index.docker.io" || k == "registry-1.docker.io" || key == dockerHubRegistry)) {
+			return auth, true
+		}
+	}
+	return registry.AuthConfig{}, false
+}
+
+// encodeRegistryAuth turns stored credentials into the base64url-encoded JSON the Docker API
+// expects. A combined "user:password" auth is split the way docker login stores it.
+func encodeRegistryAuth(auth registry.AuthConfig, host string) (string, error) {
+	if auth.Auth != "" && auth.Username == "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", fmt.Errorf("invalid auth for registry %s: it must be base64 of user:password", host)
+		}
+		user, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return "", fmt.Errorf("invalid auth for registry %s: it must be base64 of user:password", host)
+		}
+		auth.Username, auth.Password = user, password
+	}
+	auth.Auth = ""
+	if auth.ServerAddress == "" {
+		auth.ServerAddress = host
+		if host == "docker.io" {
+			auth.ServerAddress = dockerHubRegistry
+		}
+	}
+	return registry.EncodeAuthConfig(auth)
+}
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// useRegistryAuth swaps in credentials for the duration of a test
+func useRegistryAuth(t *testing.T, env registryCredentials, dockerConfig bool) {
+	t.Helper()
+	oldEnv, oldConfig := envRegistryAuth, useDockerConfig
+	envRegistryAuth, useDockerConfig = env, dockerConfig
+	t.Cleanup(func() { envRegistryAuth, useDockerConfig = oldEnv, oldConfig })
+}
+
+// decodeRegistryAuth reverses the X-Registry-Auth encoding
+func decodeRegistryAuth(t *testing.T, header string) registry.AuthConfig {
+	t.Helper()
+	data, err := base64.URLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("header %q isn't base64url: %v", header, err)
+	}
+	var auth registry.AuthConfig
+	if err := json.Unmarshal(data, &auth); err != nil {
+		t.Fatalf("header doesn't hold JSON: %v", err)
+	}
+	return auth
+}
+
+func TestRegistryAuthFor(t *testing.T) {
+	combined := base64.StdEncoding.EncodeToString([]byte("hub-user:hub:pass"))
+	useRegistryAuth(t, registryCredentials{
+		"https://ghcr.io":  {Username: "gh-user", Password: "gh-token"},
+		dockerHubRegistry:  {Auth: combined},
+		"quay.io/some/ns":  {IdentityToken: "quay-token"},
+		"registry.example": {Username: "ex", Password: "pw", ServerAddress: "https://registry.example/v2/"},
+	}, false)
+
+	tests := []struct {
+		ref  string
+		want registry.AuthConfig
+	}{
+		{"ghcr.io/org/image:1", registry.AuthConfig{Username: "gh-user", Password: "gh-token", ServerAddress: "ghcr.io"}},
+		{"python:3.12", registry.AuthConfig{Username: "hub-user", Password: "hub:pass", ServerAddress: dockerHubRegistry}},
+		{"docker.io/library/node", registry.AuthConfig{Username: "hub-user", Password: "hub:pass", ServerAddress: dockerHubRegistry}},
+		{"quay.io/some/ns/image", registry.AuthConfig{IdentityToken: "quay-token", ServerAddress: "quay.io"}},
+		{"registry.example/image", registry.AuthConfig{Username: "ex", Password: "pw", ServerAddress: "https://registry.example/v2/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			header, err := registryAuthFor(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := decodeRegistryAuth(t, header); got != tt.want {
+				t.Errorf("auth = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	header, err := registryAuthFor("gcr.io/other/image")
+	if err != nil || header != "" {
+		t.Errorf("registry without credentials: header = %q, err = %v", header, err)
+	}
+}
+
+func TestRegistryAuthRejectsMalformedAuth(t *testing.T) {
+	for _, auth := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("no-colon"))} {
+		useRegistryAuth(t, registryCredentials{"ghcr.io": {Auth: auth}}, false)
+		header, err := registryAuthFor("ghcr.io/org/image")
+		if err == nil {
+			t.Errorf("auth %q: header = %q, want an error", auth, header)
+		}
+	}
+}
+
+// TestRegistryAuthFromDockerConfig checks that the Docker config is read only as a fallback
+// for registries the environment has no credentials for
+func TestRegistryAuthFromDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"auths": {"ghcr.io": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("cfg-user:cfg-pass")) + `"},
+		"https://index.docker.io/v1/": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("cfg-hub:x")) + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+	useRegistryAuth(t, registryCredentials{"docker.io": {Username: "env-hub", Password: "y"}}, true)
+
+	header, err := registryAuthFor("ghcr.io/org/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeRegistryAuth(t, header); got.Username != "cfg-user" || got.Password != "cfg-pass" {
+		t.Errorf("ghcr.io auth = %+v, want the Docker config credentials", got)
+	}
+
+	header, err = registryAuthFor("alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeRegistryAuth(t, header); got.Username != "env-hub" {
+		t.Errorf("docker.io auth = %+v, want the environment credentials", got)
+	}
+}
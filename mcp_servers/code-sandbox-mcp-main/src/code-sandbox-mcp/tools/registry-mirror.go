@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// dockerHubDomain is the registry domain that references without one, such as python:3.12, resolve to
+const dockerHubDomain = "docker.io"
+
+// registryMirror is the registry, with an optional path prefix, that Docker Hub images are pulled
+// through instead of docker.io, read once at startup from CODE_SANDBOX_REGISTRY_MIRROR. It is ""
+// when no mirror is configured.
+var registryMirror = envRegistryMirror("CODE_SANDBOX_REGISTRY_MIRROR")
+
+// envRegistryMirror reads a mirror such as "mirror.internal:5000" or "registry.internal/dockerhub"
+// from the named environment variable. A scheme and trailing slashes are dropped, as the daemon's
+// registry-mirrors setting takes URLs. An unset variable yields ""; a mirror that can't prefix an
+// image reference is reported and also yields "".
+func envRegistryMirror(name string) string {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return ""
+	}
+	mirror := strings.TrimRight(value, "/")
+	mirror = strings.TrimPrefix(strings.TrimPrefix(mirror, "https://"), "http://")
+
+	// Without a dot, a port or localhost the first component would be read as a Docker Hub namespace
+	host, _, _ := strings.Cut(mirror, "/")
+	ref, err := reference.ParseNormalizedNamed(mirror + "/library/busybox")
+	if err != nil || reference.Domain(ref) != host || host == dockerHubDomain {
+		logger.Warn("ignoring invalid setting: it must be a registry host such as mirror.internal:5000, optionally followed by a path", "name", name, "value", value)
+		return ""
+	}
+	return mirror
+}
+
+// mirrorImage rewrites a Docker Hub reference to pull through mirror, keeping its path, tag and
+// digest: python:3.12 becomes mirror/library/python:3.12 and bitnami/redis becomes
+// mirror/bitnami/redis. References to other registries, and everything when mirror is "", are
+// returned unchanged, as are malformed references, which fail later with a clearer error.
+func mirrorImage(image, mirror string) string {
+	if mirror == "" {
+		return image
+	}
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil || reference.Domain(ref) != dockerHubDomain {
+		return image
+	}
+
+	mirrored := mirror + "/" + reference.Path(ref)
+	if tagged, ok := ref.(reference.Tagged); ok {
+		mirrored += ":" + tagged.Tag()
+	}
+	if digested, ok := ref.(reference.Digested); ok {
+		mirrored += "@" + digested.Digest().String()
+	}
+	return mirrored
+}
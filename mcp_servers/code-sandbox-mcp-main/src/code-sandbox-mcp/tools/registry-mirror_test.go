@@ -0,0 +1,51 @@
+package tools
+
+import "testing"
+
+func TestMirrorImage(t *testing.T) {
+	const digest = "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name, image, mirror, want string
+	}{
+		{"short name", "python:3.12", "mirror.internal:5000", "mirror.internal:5000/library/python:3.12"},
+		{"short name without tag", "python", "mirror.internal:5000", "mirror.internal:5000/library/python"},
+		{"library image", "library/node:20-slim", "mirror.internal:5000", "mirror.internal:5000/library/node:20-slim"},
+		{"fully qualified library image", "docker.io/library/ruby:3.3", "mirror.internal:5000", "mirror.internal:5000/library/ruby:3.3"},
+		{"namespaced image", "bitnami/redis:7", "mirror.internal:5000", "mirror.internal:5000/bitnami/redis:7"},
+		{"digest", "python@" + digest, "mirror.internal:5000", "mirror.internal:5000/library/python@" + digest},
+		{"mirror with a path", "python:3.12", "registry.internal/dockerhub", "registry.internal/dockerhub/library/python:3.12"},
+		{"other registry", "ghcr.io/acme/tool:1", "mirror.internal:5000", "ghcr.io/acme/tool:1"},
+		{"registry with a port", "registry.internal:5000/sandbox:2", "mirror.internal:5000", "registry.internal:5000/sandbox:2"},
+		{"localhost", "localhost/sandbox", "mirror.internal:5000", "localhost/sandbox"},
+		{"no mirror", "python:3.12", "", "python:3.12"},
+		{"malformed", "Not An Image", "mirror.internal:5000", "Not An Image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mirrorImage(tt.image, tt.mirror); got != tt.want {
+				t.Errorf("mirrorImage(%q, %q) = %q, want %q", tt.image, tt.mirror, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvRegistryMirror(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"", ""},
+		{"mirror.internal:5000", "mirror.internal:5000"},
+		{"https://mirror.internal/", "mirror.internal"},
+		{"registry.internal/dockerhub", "registry.internal/dockerhub"},
+		{"localhost:5000", "localhost:5000"},
+		// Without a dot or port this would be a Docker Hub namespace
+		{"mirror", ""},
+		{"docker.io", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("CODE_SANDBOX_TEST_MIRROR", tt.value)
+		if got := envRegistryMirror("CODE_SANDBOX_TEST_MIRROR"); got != tt.want {
+			t.Errorf("envRegistryMirror with %q = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxReportSandboxes caps the number of sandboxes one report covers
+	maxReportSandboxes = 100
+	// reportParallelism is how many sandboxes are gathered at once; each takes a stats sample of about a second
+	reportParallelism = 8
+	// defaultReportLogLines is how many lines of each sandbox's logs a report includes when no tail is requested
+	defaultReportLogLines = 50
+	// maxReportLogBytes caps the log tail of each sandbox in a report
+	maxReportLogBytes = 16 * 1024
+	// reportProbeTimeout bounds reading the peak memory usage from inside a sandbox
+	reportProbeTimeout = 5 * time.Second
+)
+
+// reportStatusRemoved is the status of a sandbox that no longer exists when the report is made
+const reportStatusRemoved = "removed"
+
+// sandboxReport is the structured result of sandbox_report
+type sandboxReport struct {
+	GeneratedAt string              `json:"generated_at"`
+	SessionID   string              `json:"session_id,omitempty"`
+	Summary     reportSummary       `json:"summary"`
+	Sandboxes   []sandboxReportItem `json:"sandboxes"`
+}
+
+// reportSummary counts the sandboxes of a report by outcome. Succeeded and Failed only count
+// sandboxes that have exited, by their exit code.
+type reportSummary struct {
+	Total     int `json:"total"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	OOMKilled int `json:"oom_killed"`
+	Removed   int `json:"removed"`
+}
+
+// sandboxReportItem is the part of a report about one sandbox. Errors lists what couldn't be
+// gathered, e.g. the logs of a sandbox removed while the report was made; the rest is still filled in.
+type sandboxReportItem struct {
+	ContainerID string         `json:"container_id"`
+	Name        string         `json:"name,omitempty"`
+	Image       string         `json:"image,omitempty"`
+	State       containerState `json:"state"`
+	Usage       *reportUsage   `json:"usage,omitempty"`
+	LogTail     string         `json:"log_tail,omitempty"`
+	Errors      []string       `json:"errors,omitempty"`
+}
+
+// reportUsage is the resource usage of a running sandbox. CPUSeconds is the CPU time used since
+// it started; MemoryPeakBytes is only known where the kernel records it.
+type reportUsage struct {
+	CPUSeconds       float64 `json:"cpu_seconds"`
+	MemoryUsageBytes uint64  `json:"memory_usage_bytes"`
+	MemoryPeakBytes  uint64  `json:"memory_peak_bytes,omitempty"`
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes"`
+	Pids             uint64  `json:"pids"`
+}
+
+// GenerateReport gathers the state, resource usage and log tail of a set of sandboxes into one report
+func GenerateReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	clientSession, err := parseSessionID(args["session_id"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	var refs []string
+	if raw, ok := args["container_ids"]; ok && raw != nil {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return newToolResultError("container_ids must be an array of strings"), nil
+		}
+		for _, v := range list {
+			ref, ok := v.(string)
+			if !ok || ref == "" {
+				return newToolResultError("each element of container_ids must be a non-empty string"), nil
+			}
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 && clientSession == "" {
+		return newToolResultError("container_ids or session_id is required"), nil
+	}
+
+	logLines, err := positiveNumberArg(args, "log_tail_lines", defaultReportLogLines)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	report, err := generateReport(ctx, refs, clientSession, int(logLines))
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	if markdown, _ := args["markdown"].(bool); markdown {
+		return newToolResultJSONWithSummary(report, renderReportMarkdown(report))
+	}
+	return newToolResultJSON(report)
+}
+
+// generateReport reports on the sandboxes named in refs together with those of clientSession.
+// A sandbox that can't be found is reported as removed rather than failing the report.
+func generateReport(ctx context.Context, refs []string, clientSession string, logLines int) (*sandboxReport, error) {
+	ids := make([]string, 0, len(refs))
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		id, err := ResolveContainer(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if clientSession != "" {
+		containers, err := listManagedContainers(ctx, clientSession)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if !seen[c.ID] && !isPooled(c.ID) {
+				seen[c.ID] = true
+				ids = append(ids, c.ID)
+			}
+		}
+	}
+	if len(ids) > maxReportSandboxes {
+		return nil, fmt.Errorf("a report can cover at most %d sandboxes, got %d", maxReportSandboxes, len(ids))
+	}
+
+	// A fixed pool of workers takes the sandboxes in turn, as in sandbox_run_batch
+	items := make([]sandboxReportItem, len(ids))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < reportParallelism && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				items[i] = reportSandbox(ctx, ids[i], logLines)
+			}
+		}()
+	}
+	for i := range ids {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	report := &sandboxReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		SessionID:   clientSession,
+		Sandboxes:   items,
+	}
+	report.Summary.Total = len(items)
+	for _, item := range items {
+		switch {
+		case item.State.Status == reportStatusRemoved:
+			report.Summary.Removed++
+		case item.State.Running:
+			report.Summary.Running++
+		case item.State.Status == "exited" && item.State.ExitCode == 0:
+			report.Summary.Succeeded++
+		case item.State.Status == "exited":
+			report.Summary.Failed++
+		}
+		if item.State.OOMKilled {
+			report.Summary.OOMKilled++
+		}
+	}
+	return report, nil
+}
+
+// reportSandbox gathers the report of one sandbox. Only a failed inspect ends it early; the usage
+// and logs are best effort and their errors recorded in the item.
+func reportSandbox(ctx context.Context, containerID string, logLines int) sandboxReportItem {
+	item := sandboxReportItem{ContainerID: containerID}
+	cli, err := DockerClient()
+	if err != nil {
+		item.Errors = append(item.Errors, err.Error())
+		return item
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		err = inspectError(containerID, err)
+		if errors.Is(err, ErrContainerNotFound) {
+			item.State.Status = reportStatusRemoved
+			return item
+		}
+		item.Errors = append(item.Errors, err.Error())
+		return item
+	}
+	if !isManaged(info.Config.Labels) {
+		item.Errors = append(item.Errors, fmt.Sprintf("container %s was not created by code-sandbox-mcp, refusing to report on it", containerID))
+		return item
+	}
+	item.ContainerID = info.ID
+	item.Name = sandboxName([]string{info.Name})
+	item.Image = info.Config.Image
+	if info.State != nil {
+		item.State = describeState(info.State)
+	}
+
+	if item.State.Running {
+		usage, err := reportUsageOf(ctx, cli, info.ID)
+		if err != nil {
+			item.Errors = append(item.Errors, fmt.Sprintf("failed to get resource usage: %v", err))
+		} else {
+			item.Usage = usage
+		}
+	}
+
+	logs, err := ReadContainerLogs(ctx, info.ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(logLines),
+	}, maxReportLogBytes)
+	if err != nil {
+		item.Errors = append(item.Errors, fmt.Sprintf("failed to read logs: %v", err))
+	} else {
+		item.LogTail = logs
+	}
+	return item
+}
+
+// reportUsageOf takes one stats sample of a running sandbox. The peak memory usage comes from
+// cgroup v1 stats, or on cgroup v2 from memory.peak inside the sandbox where the kernel has it.
+func reportUsageOf(ctx context.Context, cli DockerAPI, containerID string) (*reportUsage, error) {
+	resp, err := cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+	stats := statsFromResponse(containerID, raw)
+	usage := &reportUsage{
+		CPUSeconds:       float64(raw.CPUStats.CPUUsage.TotalUsage) / 1e9,
+		MemoryUsageBytes: stats.MemoryUsageBytes,
+		MemoryPeakBytes:  raw.MemoryStats.MaxUsage,
+		MemoryLimitBytes: stats.MemoryLimitBytes,
+		Pids:             stats.Pids,
+	}
+	if usage.MemoryPeakBytes == 0 {
+		result, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+			Cmd: []string{"cat", "/sys/fs/cgroup/memory.peak"},
+		}, execIO{}, reportProbeTimeout)
+		if err == nil && result.ExitCode == 0 {
+			usage.MemoryPeakBytes, _ = strconv.ParseUint(strings.TrimSpace(result.Stdout), 10, 64)
+		}
+	}
+	return usage, nil
+}
+
+// renderReportMarkdown renders a report as a Markdown table of the sandboxes followed by their log tails
+func renderReportMarkdown(report *sandboxReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Sandbox report\n\nGenerated %s", report.GeneratedAt)
+	if report.SessionID != "" {
+		fmt.Fprintf(&b, " for session `%s`", report.SessionID)
+	}
+	s := report.Summary
+	fmt.Fprintf(&b, ".\n\n%d sandbox(es): %d running, %d succeeded, %d failed, %d removed, %d killed for running out of memory.\n\n",
+		s.Total, s.Running, s.Succeeded, s.Failed, s.Removed, s.OOMKilled)
+
+	b.WriteString("| Sandbox | Image | Status | Exit code | CPU seconds | Memory (peak) |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, item := range report.Sandboxes {
+		name := shortID(item.ContainerID)
+		if item.Name != "" {
+			name = item.Name + " (" + name + ")"
+		}
+		status := item.State.Status
+		if item.State.OOMKilled {
+			status += ", out of memory"
+		}
+		exitCode, cpu, memory := "", "", ""
+		if !item.State.Running && item.State.Status != reportStatusRemoved && item.State.Status != "" {
+			exitCode = strconv.Itoa(item.State.ExitCode)
+		}
+		if u := item.Usage; u != nil {
+			cpu = strconv.FormatFloat(u.CPUSeconds, 'f', 1, 64)
+			memory = formatMiB(u.MemoryUsageBytes)
+			if u.MemoryPeakBytes > 0 {
+				memory += " (" + formatMiB(u.MemoryPeakBytes) + ")"
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", markdownCell(name), markdownCell(item.Image), markdownCell(status), exitCode, cpu, memory)
+	}
+
+	for _, item := range report.Sandboxes {
+		if item.LogTail == "" && len(item.Errors) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n", shortID(item.ContainerID))
+		if len(item.Errors) > 0 {
+			b.WriteString("\n")
+		}
+		for _, e := range item.Errors {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+		if item.LogTail != "" {
+			// A fence longer than any run of backticks in the logs can't be closed by them
+			fence := "```"
+			for strings.Contains(item.LogTail, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&b, "\n%s\n%s\n%s\n", fence, strings.TrimRight(item.LogTail, "\n"), fence)
+		}
+	}
+	return b.String()
+}
+
+// shortID returns the 12-character form of a container ID that docker ps shows
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// formatMiB formats a byte count in MiB with one decimal
+func formatMiB(bytes uint64) string {
+	return strconv.FormatFloat(float64(bytes)/(1024*1024), 'f', 1, 64) + " MiB"
+}
+
+// markdownCell escapes the characters that would break a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resetTimeout bounds each step of a reset
+const resetTimeout = 30 * time.Second
+
+// resetResult is the structured result of resetting a sandbox
+type resetResult struct {
+	ContainerID string `json:"container_id"`
+	// ProcessesKilled counts the processes left behind by earlier commands and sessions
+	ProcessesKilled int `json:"processes_killed"`
+	SessionsClosed  int `json:"sessions_closed"`
+	// Cleaned lists the directories that were emptied
+	Cleaned []string `json:"cleaned"`
+	// KeptMounts lists the bind and volume mounts inside those directories, whose files are never deleted
+	KeptMounts []string `json:"kept_mounts,omitempty"`
+}
+
+// ResetSandbox returns a sandbox to a clean state for a fresh task without recreating it
+func ResetSandbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := resetSandbox(ctx, containerID)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// resetSandbox closes the container's shell sessions, kills every process started by an exec of
+// this server, and empties the working directory and /tmp. Bind and volume mounts are left alone,
+// so a reset never deletes host files or volume contents. The container's main process, its
+// configuration and everything else on its filesystem are kept.
+func resetSandbox(ctx context.Context, containerID string) (*resetResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to reset it", containerID)
+	}
+	if info.State == nil || !info.State.Running {
+		return nil, fmt.Errorf("container %s is not running, start it before resetting it", containerID)
+	}
+
+	result := &resetResult{ContainerID: info.ID, Cleaned: []string{}}
+	result.SessionsClosed = closeContainerSessions(info)
+
+	// Processes go first, so nothing recreates files while the directories are emptied
+	killed, err := killStrayProcesses(ctx, cli, info.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.ProcessesKilled = killed
+
+	dirs := []string{"/tmp"}
+	if wd := path.Clean(info.Config.WorkingDir); info.Config.WorkingDir != "" && wd != "/" && wd != "/tmp" {
+		dirs = append([]string{wd}, dirs...)
+	}
+	for _, dir := range dirs {
+		kept, skip := mountsWithin(info.Mounts, dir)
+		result.KeptMounts = append(result.KeptMounts, kept...)
+		if skip {
+			continue
+		}
+		if err := emptyDirectory(ctx, cli, info.ID, dir, kept); err != nil {
+			return nil, fmt.Errorf("failed to clean %s: %w", dir, err)
+		}
+		result.Cleaned = append(result.Cleaned, dir)
+	}
+	sort.Strings(result.KeptMounts)
+	logger.Info("container reset", "container_id", info.ID, "processes_killed", killed, "sessions_closed", result.SessionsClosed)
+	return result, nil
+}
+
+// closeContainerSessions closes every open shell session of a container and returns how many there were
+func closeContainerSessions(info container.InspectResponse) int {
+	name := strings.TrimPrefix(info.Name, "/")
+	var open []*shellSession
+	sessions.Lock()
+	for _, s := range sessions.byID {
+		if strings.HasPrefix(info.ID, s.containerID) || s.containerID == name {
+			open = append(open, s)
+		}
+	}
+	sessions.Unlock()
+
+	for _, s := range open {
+		s.close()
+	}
+	return len(open)
+}
+
+// killStrayProcesses kills every process in the container that carries an exec marker other than
+// the one of the exec doing the killing, i.e. everything still running from earlier commands,
+// including background processes they left behind. The scan runs twice to catch processes forked
+// during the first one. Processes started outside this server, such as the main process, are kept.
+func killStrayProcesses(ctx context.Context, cli DockerAPI, containerID string) (int, error) {
+	scan := fmt.Sprintf(
+		`for p in /proc/[0-9]*; do `+
+			`m=$(tr '\0' '\n' 2>/dev/null < "$p/environ" | grep '^%[1]s='); `+
+			`if [ -n "$m" ] && [ "$m" != "%[1]s=$%[1]s" ] && kill -9 "${p#/proc/}" 2>/dev/null; then n=$((n+1)); fi; `+
+			`done; `,
+		execMarkerEnv,
+	)
+	result, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		User: "0",
+		Cmd:  []string{"sh", "-c", "n=0; " + scan + scan + "echo $n"},
+	}, execIO{}, resetTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to kill processes: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return 0, fmt.Errorf("failed to kill processes: sh exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	killed, _ := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	return killed, nil
+}
+
+// mountsWithin returns the destinations of the bind and volume mounts at or below dir. skip is set
+// when dir itself is such a mount, in which case nothing in it may be deleted.
+func mountsWithin(mounts []container.MountPoint, dir string) (kept []string, skip bool) {
+	for _, m := range mounts {
+		dest := path.Clean(m.Destination)
+		switch {
+		case dest == dir:
+			return []string{dest}, true
+		case strings.HasPrefix(dest, dir+"/"):
+			kept = append(kept, dest)
+		}
+	}
+	return kept, false
+}
+
+// emptyDirectory deletes everything below dir as root, except the mount points in kept, the
+// directories leading to them and, through -xdev, anything on another filesystem
+func emptyDirectory(ctx context.Context, cli DockerAPI, containerID string, dir string, kept []string) error {
+	cmd := []string{"find", dir, "-xdev", "-mindepth", "1"}
+	exclude := map[string]bool{}
+	for _, k := range kept {
+		for p := k; p != dir && p != "/"; p = path.Dir(p) {
+			exclude[p] = true
+		}
+		cmd = append(cmd, "!", "-path", k+"/*")
+	}
+	excluded := make([]string, 0, len(exclude))
+	for p := range exclude {
+		excluded = append(excluded, p)
+	}
+	sort.Strings(excluded)
+	for _, p := range excluded {
+		cmd = append(cmd, "!", "-path", p)
+	}
+	cmd = append(cmd, "-delete")
+
+	result, err := runAttachedExec(ctx, cli, containerID, container.ExecOptions{
+		User: "0",
+		Cmd:  cmd,
+	}, execIO{}, resetTimeout)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("find exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
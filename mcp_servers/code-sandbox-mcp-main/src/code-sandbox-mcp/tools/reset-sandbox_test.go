@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// TestResetSandboxDeletesFiles checks the commands a reset runs: a process scan and a find that
+// deletes everything in the working directory and /tmp, except bind mounts and the directories
+// leading to them
+func TestResetSandboxDeletesFiles(t *testing.T) {
+	f := newFakeDocker()
+	f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+		if opts.Cmd[0] == "sh" {
+			return fakeExecResult{Stdout: "2\n"}
+		}
+		return fakeExecResult{}
+	}
+	id, _ := initializeSandbox(t, f, map[string]interface{}{})
+	f.mu.Lock()
+	f.containers[id].Mounts = []container.MountPoint{
+		{Type: mount.TypeBind, Source: "/host/data", Destination: "/app/data"},
+		{Type: mount.TypeVolume, Name: "raw", Destination: "/app/in/raw"},
+	}
+	f.mu.Unlock()
+	f.execOptions = nil
+
+	var reset resetResult
+	decodeResult(t, ResetSandbox, map[string]interface{}{"container_id": id}, &reset)
+
+	want := [][]string{
+		{"find", "/app", "-xdev", "-mindepth", "1", "!", "-path", "/app/data/*", "!", "-path", "/app/in/raw/*",
+			"!", "-path", "/app/data", "!", "-path", "/app/in", "!", "-path", "/app/in/raw", "-delete"},
+		{"find", "/tmp", "-xdev", "-mindepth", "1", "-delete"},
+	}
+	var finds [][]string
+	var scans int
+	for _, opts := range f.execOptions {
+		// Cleaning and killing run as root, so files and processes of any user can be removed
+		if opts.User != "0" {
+			t.Errorf("%v ran as %q, want root", opts.Cmd, opts.User)
+		}
+		switch {
+		case opts.Cmd[0] == "find":
+			finds = append(finds, opts.Cmd)
+		case opts.Cmd[0] == "sh" && strings.Contains(opts.Cmd[2], execMarkerEnv) && strings.Contains(opts.Cmd[2], "kill -9"):
+			scans++
+		default:
+			t.Errorf("unexpected command %v", opts.Cmd)
+		}
+	}
+	if scans != 1 {
+		t.Errorf("%d process scans ran, want 1", scans)
+	}
+	if !reflect.DeepEqual(finds, want) {
+		t.Errorf("cleanup commands = %q, want %q", finds, want)
+	}
+	if reset.ProcessesKilled != 2 {
+		t.Errorf("processes killed = %d, want the count the scan printed", reset.ProcessesKilled)
+	}
+	if len(reset.Cleaned) != 2 || reset.Cleaned[0] != "/app" || reset.Cleaned[1] != "/tmp" {
+		t.Errorf("cleaned = %v, want [/app /tmp]", reset.Cleaned)
+	}
+	if !reflect.DeepEqual(reset.KeptMounts, []string{"/app/data", "/app/in/raw"}) {
+		t.Errorf("kept mounts = %v, want [/app/data /app/in/raw]", reset.KeptMounts)
+	}
+}
+
+func TestResetSandboxRefusesStoppedContainer(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("stopped", nil)
+	f.containers[id].State.Running = false
+
+	callTool(t, ResetSandbox, map[string]interface{}{"container_id": id}, true)
+	if len(f.execOptions) != 0 {
+		t.Errorf("commands ran in a stopped container: %v", f.execOptions)
+	}
+}
+
+func TestResetSandboxRefusesUnmanaged(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("not-ours", map[string]string{})
+
+	callTool(t, ResetSandbox, map[string]interface{}{"container_id": id}, true)
+	if len(f.execOptions) != 0 {
+		t.Errorf("commands ran in an unmanaged container: %v", f.execOptions)
+	}
+}
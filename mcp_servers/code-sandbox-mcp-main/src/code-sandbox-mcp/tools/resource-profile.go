@@ -0,0 +1,72 @@
+package tools
+
+import "fmt"
+
+// resourceProfile is a set of limits for the memory, CPUs and processes of a sandbox. In a
+// maximum profile a zero field means that limit has no maximum.
+type resourceProfile struct {
+	MemoryMB  float64
+	CPULimit  float64
+	PidsLimit int64
+}
+
+// defaultResources are the limits of a sandbox whose caller doesn't request any, and maxResources
+// the highest limits a caller may request. Both are read once at startup.
+var defaultResources, maxResources = loadResourceProfiles()
+
+// DefaultLimits returns the memory limit in megabytes, CPU limit and pids limit applied when the
+// caller doesn't request one
+func DefaultLimits() (memoryMB float64, cpuLimit float64, pidsLimit int64) {
+	return defaultResources.MemoryMB, defaultResources.CPULimit, defaultResources.PidsLimit
+}
+
+// loadResourceProfiles reads the default limits from CODE_SANDBOX_DEFAULT_MEMORY_MB,
+// CODE_SANDBOX_DEFAULT_CPU_LIMIT and CODE_SANDBOX_DEFAULT_PIDS_LIMIT, and the maximums from the
+// matching CODE_SANDBOX_MAX_ variables. A default above its maximum is lowered to the maximum, so
+// a sandbox created without limits never fails the check against them.
+func loadResourceProfiles() (resourceProfile, resourceProfile) {
+	defaults := resourceProfile{
+		MemoryMB:  envFloat("CODE_SANDBOX_DEFAULT_MEMORY_MB", defaultMemoryMB),
+		CPULimit:  envFloat("CODE_SANDBOX_DEFAULT_CPU_LIMIT", defaultCPULimit),
+		PidsLimit: envInt("CODE_SANDBOX_DEFAULT_PIDS_LIMIT", defaultPidsLimit),
+	}
+	// A pids limit of zero would be unlimited to Docker
+	if defaults.PidsLimit == 0 {
+		logger.Warn("ignoring invalid setting", "name", "CODE_SANDBOX_DEFAULT_PIDS_LIMIT", "value", 0, "default", defaultPidsLimit)
+		defaults.PidsLimit = defaultPidsLimit
+	}
+	maximums := resourceProfile{
+		MemoryMB:  envFloat("CODE_SANDBOX_MAX_MEMORY_MB", 0),
+		CPULimit:  envFloat("CODE_SANDBOX_MAX_CPU_LIMIT", 0),
+		PidsLimit: envInt("CODE_SANDBOX_MAX_PIDS_LIMIT", 0),
+	}
+
+	if maximums.MemoryMB > 0 && defaults.MemoryMB > maximums.MemoryMB {
+		logger.Warn("lowering the default limit to the maximum", "limit", "memory_mb", "default", defaults.MemoryMB, "max", maximums.MemoryMB)
+		defaults.MemoryMB = maximums.MemoryMB
+	}
+	if maximums.CPULimit > 0 && defaults.CPULimit > maximums.CPULimit {
+		logger.Warn("lowering the default limit to the maximum", "limit", "cpu_limit", "default", defaults.CPULimit, "max", maximums.CPULimit)
+		defaults.CPULimit = maximums.CPULimit
+	}
+	if maximums.PidsLimit > 0 && defaults.PidsLimit > maximums.PidsLimit {
+		logger.Warn("lowering the default limit to the maximum", "limit", "pids_limit", "default", defaults.PidsLimit, "max", maximums.PidsLimit)
+		defaults.PidsLimit = maximums.PidsLimit
+	}
+	return defaults, maximums
+}
+
+// checkResourceMaximums rejects requested limits above the maximums configured for this server.
+// Zero values stand for limits that aren't being set and are never rejected.
+func checkResourceMaximums(memoryMB, cpuLimit float64, pidsLimit int64) error {
+	if maxResources.MemoryMB > 0 && memoryMB > maxResources.MemoryMB {
+		return fmt.Errorf("memory_mb %g exceeds the maximum of %g allowed by this server", memoryMB, maxResources.MemoryMB)
+	}
+	if maxResources.CPULimit > 0 && cpuLimit > maxResources.CPULimit {
+		return fmt.Errorf("cpu_limit %g exceeds the maximum of %g allowed by this server", cpuLimit, maxResources.CPULimit)
+	}
+	if maxResources.PidsLimit > 0 && pidsLimit > maxResources.PidsLimit {
+		return fmt.Errorf("pids_limit %d exceeds the maximum of %d allowed by this server", pidsLimit, maxResources.PidsLimit)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultRestartTimeoutSeconds is how long a restart waits for the container to stop before killing it
+const defaultRestartTimeoutSeconds = 10
+
+// restartResult is the structured result of restarting a sandbox
+type restartResult struct {
+	ContainerID string `json:"container_id"`
+	Status      string `json:"status"`
+	StartedAt   string `json:"started_at"`
+}
+
+// RestartContainer restarts a container, ending every process in it while keeping its filesystem
+func RestartContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := positiveNumberArg(request.Params.Arguments, "timeout_seconds", defaultRestartTimeoutSeconds)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := restartContainer(ctx, containerID, int(timeout))
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// restartContainer restarts a managed container and checks that it is running again afterwards.
+// Its PID 1 is `sleep infinity`, so a healthy sandbox always comes back up.
+func restartContainer(ctx context.Context, containerID string, timeoutSeconds int) (*restartResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Like sandbox_stop, only touch containers this server created
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to restart it", containerID)
+	}
+
+	if err := cli.ContainerRestart(ctx, containerID, container.StopOptions{Timeout: &timeoutSeconds}); err != nil {
+		return nil, fmt.Errorf("failed to restart container: %w", err)
+	}
+
+	info, err = cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if info.State == nil || !info.State.Running {
+		status := "unknown"
+		if info.State != nil {
+			status = info.State.Status
+		}
+		return nil, fmt.Errorf("container %s did not come back up after the restart (state: %s)", containerID, status)
+	}
+
+	return &restartResult{
+		ContainerID: info.ID,
+		Status:      info.State.Status,
+		StartedAt:   info.State.StartedAt,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// maxRestartRetries bounds the retry count of on-failure:N, so a crashing sandbox can't be
+// restarted practically forever
+const maxRestartRetries = 100
+
+// parseRestartPolicy validates the restart_policy argument: no, always, unless-stopped,
+// on-failure or on-failure:N with N from 1 to maxRestartRetries. Unset means no.
+func parseRestartPolicy(arg interface{}) (container.RestartPolicy, error) {
+	if arg == nil {
+		return container.RestartPolicy{}, nil
+	}
+	s, ok := arg.(string)
+	if !ok {
+		return container.RestartPolicy{}, fmt.Errorf("restart_policy must be a string")
+	}
+
+	name, retries, hasRetries := strings.Cut(strings.TrimSpace(s), ":")
+	switch container.RestartPolicyMode(name) {
+	case "", container.RestartPolicyDisabled:
+		if hasRetries {
+			return container.RestartPolicy{}, fmt.Errorf("restart_policy %q: only on-failure takes a retry count", s)
+		}
+		return container.RestartPolicy{}, nil
+	case container.RestartPolicyAlways, container.RestartPolicyUnlessStopped:
+		if hasRetries {
+			return container.RestartPolicy{}, fmt.Errorf("restart_policy %q: only on-failure takes a retry count", s)
+		}
+		return container.RestartPolicy{Name: container.RestartPolicyMode(name)}, nil
+	case container.RestartPolicyOnFailure:
+		policy := container.RestartPolicy{Name: container.RestartPolicyOnFailure}
+		if !hasRetries {
+			return policy, nil
+		}
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 1 || n > maxRestartRetries {
+			return container.RestartPolicy{}, fmt.Errorf("restart_policy %q: the retry count of on-failure must be a whole number from 1 to %d", s, maxRestartRetries)
+		}
+		policy.MaximumRetryCount = n
+		return policy, nil
+	default:
+		return container.RestartPolicy{}, fmt.Errorf("restart_policy must be no, always, unless-stopped, on-failure or on-failure:N, got %q", s)
+	}
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// newToolResultJSON creates a tool result whose text content is the JSON encoding of v
+func newToolResultJSON(v interface{}) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error encoding result: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// newToolResultJSONWithSummary creates a JSON tool result followed by a second, human-readable
+// text content, for clients that show or pattern-match the text instead of parsing the JSON
+func newToolResultJSONWithSummary(v interface{}, summary string) (*mcp.CallToolResult, error) {
+	result, err := newToolResultJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	result.Content = append(result.Content, mcp.NewTextContent(summary))
+	return result, nil
+}
+
+// newToolResultError creates a tool result that is flagged as an error, so clients can tell a failure
+// of the tool itself, such as an invalid argument or an unreachable Docker daemon, from normal output.
+// A program exiting with a non-zero code is not a tool error and is reported as a normal result.
+func newToolResultError(text string) *mcp.CallToolResult {
+	result := mcp.NewToolResultText(text)
+	result.IsError = true
+	return result
+}
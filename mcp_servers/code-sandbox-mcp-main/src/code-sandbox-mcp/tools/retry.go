@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// maxDockerRetryDelay caps the backoff between two attempts, however many retries are configured
+const maxDockerRetryDelay = 5 * time.Second
+
+var (
+	// dockerRetries is how many times a failed create, start, stop or remove is retried
+	// when the daemon was merely unreachable or overloaded
+	dockerRetries = int(envInt("CODE_SANDBOX_DOCKER_RETRIES", 3))
+	// dockerRetryDelay is the wait before the first retry; it doubles with every further attempt
+	dockerRetryDelay = envDuration("CODE_SANDBOX_DOCKER_RETRY_DELAY", 200*time.Millisecond)
+)
+
+// deterministicFailures are daemon errors reported as internal server errors that retrying can't fix,
+// typically a sandbox command that doesn't exist in the image
+var deterministicFailures = []string{
+	"OCI runtime create failed",
+	"executable file not found",
+	"exec format error",
+}
+
+// withDockerRetry runs op, retrying it with exponential backoff while it fails with a transient error.
+// action names the operation in the log, e.g. "create container".
+func withDockerRetry(ctx context.Context, action string, op func() error) error {
+	delay := dockerRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= dockerRetries || !isTransientDockerError(ctx, err) {
+			return err
+		}
+
+		logger.Warn("docker call failed, retrying", "action", action, "attempt", attempt+1, "attempts", dockerRetries+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (gave up retrying: %v)", err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxDockerRetryDelay)
+	}
+}
+
+// isTransientDockerError reports whether err may go away by itself: the daemon couldn't be reached,
+// e.g. while it restarts, or it answered with a 500 or 503. Errors describing the request itself,
+// such as a missing image, a name conflict or invalid options, get the same answer every time.
+func isTransientDockerError(ctx context.Context, err error) bool {
+	// The caller gave up, so there is nobody to retry for
+	if ctx.Err() != nil {
+		return false
+	}
+	if client.IsErrConnectionFailed(err) {
+		return true
+	}
+	if !errdefs.IsSystem(err) && !errdefs.IsUnavailable(err) {
+		return false
+	}
+	for _, msg := range deterministicFailures {
+		if strings.Contains(err.Error(), msg) {
+			return false
+		}
+	}
+	return true
+}
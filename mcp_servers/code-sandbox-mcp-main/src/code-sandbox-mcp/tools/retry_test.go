@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+func TestIsTransientDockerError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection failed", client.ErrorConnectionFailed("unix:///var/run/docker.sock"), true},
+		{"server error", errdefs.System(errors.New("Error response from daemon: driver failed")), true},
+		{"unavailable", errdefs.Unavailable(errors.New("Error response from daemon: service unavailable")), true},
+		{"not found", errdefs.NotFound(errors.New("No such image: python:3.12")), false},
+		{"conflict", errdefs.Conflict(errors.New("the container name is already in use")), false},
+		{"invalid options", errdefs.InvalidParameter(errors.New("invalid mount config")), false},
+		{"runtime create failed", errdefs.System(errors.New(`OCI runtime create failed: runc create failed: exec: "sleep": executable file not found in $PATH`)), false},
+		{"plain error", errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientDockerError(context.Background(), tt.err); got != tt.want {
+				t.Errorf("isTransientDockerError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTransientDockerErrorAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isTransientDockerError(ctx, client.ErrorConnectionFailed("")) {
+		t.Error("an error is retried for a caller that gave up")
+	}
+}
+
+// fastRetries shortens the retry backoff for the duration of the test
+func fastRetries(t *testing.T) {
+	prevRetries, prevDelay := dockerRetries, dockerRetryDelay
+	dockerRetries, dockerRetryDelay = 3, time.Millisecond
+	t.Cleanup(func() { dockerRetries, dockerRetryDelay = prevRetries, prevDelay })
+}
+
+func TestWithDockerRetry(t *testing.T) {
+	fastRetries(t)
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"transient errors are retried", errdefs.Unavailable(errors.New("restarting")), dockerRetries + 1},
+		{"deterministic errors are not", errdefs.NotFound(errors.New("No such image")), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withDockerRetry(context.Background(), "test", func() error {
+				calls++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("op was called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+// TestCreateRetryAdoptsContainer checks that a create retried after an ambiguous failure uses the
+// container the failed attempt created instead of creating a second one
+func TestCreateRetryAdoptsContainer(t *testing.T) {
+	fastRetries(t)
+	f := newFakeDocker()
+	f.createErrs = []error{errdefs.System(errors.New("Error response from daemon: connection reset"))}
+	f.createdDespiteErr = true
+	useFakeDocker(t, f)
+
+	opts, err := parseContainerOptions(map[string]interface{}{"name": "retried"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := setUpContainer(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.creates) != 1 {
+		t.Errorf("ContainerCreate was called %d times, want 1", len(f.creates))
+	}
+	if len(f.containers) != 1 || f.containers[id] == nil {
+		t.Errorf("want exactly the adopted container %s, have %d containers", id, len(f.containers))
+	}
+	if len(f.starts) != 1 || f.starts[0] != id {
+		t.Errorf("started %v, want the adopted container", f.starts)
+	}
+}
+
+// TestCreateRetryAfterFailedAttempt checks that a retry creates the container when the failed
+// attempt didn't
+func TestCreateRetryAfterFailedAttempt(t *testing.T) {
+	fastRetries(t)
+	f := newFakeDocker()
+	f.createErrs = []error{client.ErrorConnectionFailed("")}
+	useFakeDocker(t, f)
+
+	opts, err := parseContainerOptions(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setUpContainer(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.creates) != 2 || len(f.containers) != 1 {
+		t.Errorf("%d creates and %d containers, want 2 and 1", len(f.creates), len(f.containers))
+	}
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultBatchParallel is how many batch items run at once when max_parallel isn't given
+	defaultBatchParallel = 4
+	// maxBatchParallel bounds max_parallel, since every running item is a container of its own
+	maxBatchParallel = 16
+)
+
+// batchItemResult is the outcome of one item of a batch. Error is set when the item could not be
+// run at all, e.g. because of an unknown language; a program exiting non-zero is a normal result.
+type batchItemResult struct {
+	Index    int    `json:"index"`
+	Language string `json:"language"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// batchResult is the structured result of a batch, with the items in input order
+type batchResult struct {
+	Results   []batchItemResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
+// RunBatch runs independent snippets of code in parallel, each in an ephemeral sandbox of its own
+func RunBatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	items, ok := request.Params.Arguments["items"].([]interface{})
+	if !ok || len(items) == 0 {
+		return newToolResultError("items must be a non-empty array of {language, code} objects"), nil
+	}
+
+	maxParallel, err := positiveNumberArg(request.Params.Arguments, "max_parallel", defaultBatchParallel)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	if maxParallel > maxBatchParallel {
+		return newToolResultError(fmt.Sprintf("max_parallel must be at most %d", maxBatchParallel)), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// The cap applies to each item's output separately
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// Every sandbox of the batch gets the same options as sandbox_initialize would give it
+	opts, err := parseContainerOptions(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+	imageOverride, _ := request.Params.Arguments["image"].(string)
+	// Container names are unique, so they can't be shared by the sandboxes of a batch
+	opts.Name = ""
+	// The snippets are exec'd into the sandboxes, which have to stay up for that
+	opts.Cmd, opts.UseImageCmd, opts.RunOnce = nil, false, false
+
+	// A fixed pool of workers takes the items in turn, so at most maxParallel containers exist at once
+	results := make([]batchItemResult, len(items))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < int(maxParallel) && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = runBatchItem(ctx, i, items[i], *opts, imageOverride, timeout, maxOutputBytes)
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	result := batchResult{Results: results}
+	for _, r := range results {
+		if r.Error == "" && *r.ExitCode == 0 {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	return newToolResultJSON(result)
+}
+
+// runBatchItem runs one item of a batch in a sandbox that is removed afterwards.
+// opts is a copy, so setting the item's image doesn't affect the other items.
+func runBatchItem(ctx context.Context, index int, item interface{}, opts containerOptions, imageOverride string, timeout time.Duration, maxOutputBytes int) batchItemResult {
+	result := batchItemResult{Index: index}
+
+	entry, ok := item.(map[string]interface{})
+	if !ok {
+		result.Error = "item must be an object with language and code"
+		return result
+	}
+	result.Language, _ = entry["language"].(string)
+	code, _ := entry["code"].(string)
+	if code == "" {
+		result.Error = "code is required"
+		return result
+	}
+	language, err := interpreterFor(result.Language)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	opts.Image = language.image()
+	if imageOverride != "" {
+		opts.Image = imageOverride
+	}
+	containerID, err := createContainer(ctx, &opts)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer discardContainer(containerID)
+
+	cmdResult, err := runCodeInContainer(ctx, containerID, language, code, timeout, maxOutputBytes, false)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Stdout = cmdResult.Stdout
+	result.Stderr = cmdResult.Stderr
+	result.ExitCode = &cmdResult.ExitCode
+	return result
+}
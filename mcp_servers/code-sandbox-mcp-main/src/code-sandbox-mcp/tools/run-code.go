@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// runCodeResult is the structured result of running a snippet of code
+type runCodeResult struct {
+	// ContainerID is only set when the sandbox is still running after the call
+	ContainerID string `json:"container_id,omitempty"`
+	Language    string `json:"language"`
+	commandResult
+}
+
+// RunCode writes a snippet of code to a sandbox and runs it with the interpreter for its language.
+// Without a container_id an ephemeral sandbox is created for the run and removed afterwards unless keep_alive is set.
+func RunCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	code, ok := request.Params.Arguments["code"].(string)
+	if !ok || code == "" {
+		return newToolResultError("code is required"), nil
+	}
+
+	languageName, ok := request.Params.Arguments["language"].(string)
+	if !ok || languageName == "" {
+		return newToolResultError("language is required"), nil
+	}
+	language, err := interpreterFor(languageName)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	keepAlive, _ := request.Params.Arguments["keep_alive"].(bool)
+
+	containerID, _ := request.Params.Arguments["container_id"].(string)
+	ephemeral := containerID == ""
+	if !ephemeral {
+		if containerID, err = ResolveContainer(ctx, containerID); err != nil {
+			return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+	if ephemeral {
+		// Ephemeral sandboxes accept the same options as sandbox_initialize
+		opts, err := parseContainerOptions(request.Params.Arguments)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+		}
+		if image, _ := request.Params.Arguments["image"].(string); image == "" {
+			opts.Image = language.image()
+		}
+		// The code is exec'd into the sandbox, which has to stay up for that
+		opts.Cmd, opts.UseImageCmd, opts.RunOnce = nil, false, false
+		if request.Params.Meta != nil && request.Params.Meta.ProgressToken != nil {
+			opts.OnPullProgress = pullProgressNotifier(ctx, request.Params.Meta.ProgressToken)
+		}
+
+		containerID, err = createContainer(ctx, opts)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+		}
+		if !keepAlive {
+			defer discardContainer(containerID)
+		}
+	}
+
+	cmdResult, err := runCodeInContainer(ctx, containerID, language, code, timeout, maxOutputBytes, !ephemeral || keepAlive)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	result := runCodeResult{
+		Language:      languageName,
+		commandResult: *cmdResult,
+	}
+	if !ephemeral || keepAlive {
+		touchContainer(containerID)
+		result.ContainerID = containerID
+	}
+
+	return newToolResultJSON(result)
+}
+
+// runCodeInContainer writes code to a file in the container and runs it with the language's interpreter.
+// With cleanup the file is removed afterwards, so sandboxes that outlive the run collect no stray code files.
+func runCodeInContainer(ctx context.Context, containerID string, language codeLanguage, code string, timeout time.Duration, maxOutputBytes int, cleanup bool) (*commandResult, error) {
+	// Write the code to a uniquely named file so concurrent runs in one sandbox don't collide
+	codePath := fmt.Sprintf("/tmp/code-sandbox-%d.%s", time.Now().UnixNano(), language.Extension)
+	if err := writeFileToContainer(ctx, containerID, codePath, []byte(code)); err != nil {
+		return nil, fmt.Errorf("failed to write code: %w", err)
+	}
+
+	result, err := runCommandInContainer(ctx, containerID, []string{language.Interpreter, codePath}, execOverrides{}, execIO{MaxOutputBytes: maxOutputBytes}, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute code: %w", err)
+	}
+
+	if cleanup {
+		_ = executeCommand(ctx, containerID, []string{"rm", "-f", codePath})
+	}
+	return result, nil
+}
+
+// discardContainer force-removes a sandbox nobody will use again, such as one created for a single
+// run or one that failed to start. Nothing in it is worth stopping gracefully, and it uses its own
+// context so cleanup happens even when the request was cancelled.
+func discardContainer(containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cli, err := DockerClient()
+	if err != nil {
+		logger.Error("failed to remove container", "container_id", containerID, "error", err)
+		return
+	}
+
+	if err := withDockerRetry(ctx, "remove container", func() error {
+		return cli.ContainerRemove(ctx, containerID, container.RemoveOptions{
+			RemoveVolumes: true,
+			Force:         true,
+		})
+	}); err != nil && !errdefs.IsNotFound(err) {
+		logger.Error("failed to remove container", "container_id", containerID, "error", err)
+		return
+	}
+	containersStopped.Inc()
+	logger.Info("container removed", "container_id", containerID)
+	forgetContainer(containerID)
+}
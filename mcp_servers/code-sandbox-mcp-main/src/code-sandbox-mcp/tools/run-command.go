@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// commandResult is the structured result of a command run in a container
+type commandResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	// OOMKilled is set, together with a Note saying so, when the command was killed because the
+	// sandbox ran out of memory
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+	Note      string `json:"note,omitempty"`
+
+	// Tty is set when the command ran in a pseudo-terminal, in which case stdout holds all of its
+	// output and stderr is empty
+	Tty bool `json:"tty,omitempty"`
+}
+
+// RunCommand runs a single command in an existing container and returns its output
+func RunCommand(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// The command can be a shell string or an argv-style array of strings
+	cmd, err := parseCommandArgument(request.Params.Arguments["command"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	overrides, err := parseExecOverrides(ctx, containerID, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	streams := execIO{MaxOutputBytes: maxOutputBytes}
+	// Input is written to the command's stdin, which is then closed so the command sees EOF
+	if stdin, ok := request.Params.Arguments["stdin"].(string); ok {
+		streams.Stdin = strings.NewReader(stdin)
+	}
+
+	result, err := runCommandInContainer(ctx, containerID, cmd, overrides, streams, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error executing command: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// parseCommandArgument converts the command argument into an exec command line.
+// A string is run through `sh -c`, an array is executed directly without a shell.
+func parseCommandArgument(arg interface{}) ([]string, error) {
+	switch v := arg.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("command is required")
+		}
+		return []string{"sh", "-c", v}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("command must not be empty")
+		}
+		cmd := make([]string, 0, len(v))
+		for _, part := range v {
+			partStr, ok := part.(string)
+			if !ok {
+				return nil, fmt.Errorf("each element of command must be a string")
+			}
+			cmd = append(cmd, partStr)
+		}
+		return cmd, nil
+	default:
+		return nil, fmt.Errorf("command must be a string or an array of strings")
+	}
+}
+
+// runCommandInContainer runs a command in the container's working directory, as the container's
+// user unless overridden, and returns its stdout, stderr and exit code
+func runCommandInContainer(ctx context.Context, containerID string, cmd []string, overrides execOverrides, streams execIO, timeout time.Duration) (*commandResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	execConfig := container.ExecOptions{
+		// Runs in the working directory the container was created with
+		Cmd: cmd,
+	}
+	overrides.apply(&execConfig)
+	return runAttachedExec(ctx, cli, containerID, execConfig, streams, timeout)
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// TestRunCommandPipesStdin checks that stdin reaches the command in full and is closed, which the
+// fake needs before it runs the command
+func TestRunCommandPipesStdin(t *testing.T) {
+	for _, command := range []interface{}{"cat", []interface{}{"cat"}} {
+		f := newFakeDocker()
+		var received string
+		f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+			received = stdin
+			return fakeExecResult{}
+		}
+		useFakeDocker(t, f)
+		id := f.addContainer("stdin", nil)
+
+		input := "line one\nline two\n"
+		callTool(t, RunCommand, map[string]interface{}{"container_id": id, "command": command, "stdin": input}, false)
+		if received != input {
+			t.Errorf("command %v: received stdin %q, want %q", command, received, input)
+		}
+		if last := f.execOptions[len(f.execOptions)-1]; !last.AttachStdin {
+			t.Errorf("command %v: exec created without AttachStdin", command)
+		}
+	}
+}
+
+func TestRunCommandWithoutStdin(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("no-stdin", nil)
+
+	var result commandResult
+	decodeResult(t, RunCommand, map[string]interface{}{"container_id": id, "command": "true"}, &result)
+	if f.execOptions[len(f.execOptions)-1].AttachStdin {
+		t.Error("exec created with AttachStdin although no stdin was given")
+	}
+}
+
+func TestRunCommandStdinRejectsTty(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("tty", nil)
+	callTool(t, RunCommand, map[string]interface{}{"container_id": id, "command": "cat", "stdin": "x", "tty": true}, true)
+}
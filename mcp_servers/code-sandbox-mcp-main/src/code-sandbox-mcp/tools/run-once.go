@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// runOnceResult is the structured result of sandbox_initialize with run_once
+type runOnceResult struct {
+	ContainerID string `json:"container_id"`
+	Image       string `json:"image"`
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	// ExitCode is missing when the command was still running at the timeout
+	ExitCode *int64 `json:"exit_code,omitempty"`
+	TimedOut bool   `json:"timed_out"`
+	// OOMKilled and Note report a command killed because the sandbox ran out of memory
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+// runOnce creates a sandbox whose main process is the requested command, waits for it to exit,
+// and returns its output. The sandbox is removed afterwards, also when the command times out.
+func runOnce(ctx context.Context, request mcp.CallToolRequest, opts *containerOptions) (*mcp.CallToolResult, error) {
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultWaitTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	maxOutputBytes, err := maxOutputBytesArg(request.Params.Arguments, defaultMaxOutputBytes)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	containerID, err := createContainer(ctx, opts)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+	defer discardContainer(containerID)
+
+	wait, err := waitForContainer(ctx, containerID, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error waiting for container: %v", err)), nil
+	}
+
+	// The logs hold everything the command printed, including after a timeout
+	stdout, stderr, err := readSplitLogs(ctx, containerID, maxOutputBytes)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error reading container logs: %v", err)), nil
+	}
+
+	result := runOnceResult{
+		ContainerID: containerID,
+		Image:       opts.Image,
+		Stdout:      stdout,
+		Stderr:      stderr,
+		ExitCode:    wait.ExitCode,
+		TimedOut:    wait.TimedOut,
+	}
+	if wait.ExitCode != nil && *wait.ExitCode == oomExitCode {
+		if cli, err := DockerClient(); err == nil {
+			if note := oomNote(ctx, cli, containerID); note != "" {
+				result.OOMKilled, result.Note = true, note
+			}
+		}
+	}
+	return newToolResultJSON(result)
+}
+
+// readSplitLogs returns the stdout and stderr logs of a container created without a TTY separately,
+// each capped at maxBytes like the output of an exec
+func readSplitLogs(ctx context.Context, containerID string, maxBytes int) (string, string, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return "", "", err
+	}
+
+	reader, err := cli.ContainerLogs(ctx, containerID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching container logs: %w", err)
+	}
+	defer reader.Close()
+
+	stdout := &headWriter{limit: maxBytes}
+	stderr := &headWriter{limit: maxBytes}
+	if _, err := stdcopy.StdCopy(stdout, stderr, reader); err != nil && err != io.EOF {
+		return "", "", fmt.Errorf("error copying container logs: %w", err)
+	}
+	return stdout.String(), stderr.String(), nil
+}
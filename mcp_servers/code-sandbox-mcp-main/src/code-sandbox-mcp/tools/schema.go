@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Types declares a property that accepts several JSON types, such as a command given either as a
+// string or as an array of strings. It replaces the single type set by mcp.WithString and friends.
+func Types(types ...string) mcp.PropertyOption {
+	return func(schema map[string]interface{}) {
+		schema["type"] = types
+	}
+}
+
+// StringItems declares that every item of an array property is a string
+func StringItems() mcp.PropertyOption {
+	return mcp.Items(map[string]interface{}{"type": "string"})
+}
+
+// ObjectItems declares that every item of an array property is an object
+func ObjectItems() mcp.PropertyOption {
+	return mcp.Items(map[string]interface{}{"type": "object"})
+}
+
+// ValidateArguments wraps a tool handler so the call's arguments are checked against the tool's
+// input schema before the handler runs, and before anything touches Docker. Missing required
+// arguments and values of the wrong type, outside an enum or out of range are reported by name.
+// Arguments the schema doesn't declare are passed through, and null counts as not given, as it
+// does in the handlers.
+func ValidateArguments(tool mcp.Tool, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := validateArguments(tool.InputSchema, request.Params.Arguments); err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		return handler(ctx, request)
+	}
+}
+
+// validateArguments checks args against schema, reporting the first problem in argument order
+func validateArguments(schema mcp.ToolInputSchema, args map[string]interface{}) error {
+	for _, name := range schema.Required {
+		if args[name] == nil {
+			return fmt.Errorf("missing required argument %s", name)
+		}
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, ok := schema.Properties[name].(map[string]interface{})
+		if !ok || args[name] == nil {
+			continue
+		}
+		if err := validateValue(prop, args[name]); err != nil {
+			return fmt.Errorf("invalid argument %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateValue checks one value against a property schema
+func validateValue(prop map[string]interface{}, value interface{}) error {
+	var types []string
+	switch t := prop["type"].(type) {
+	case string:
+		types = []string{t}
+	case []string:
+		types = t
+	}
+	if len(types) > 0 && !hasJSONType(types, value) {
+		return fmt.Errorf("must be %s, got %s", strings.Join(withArticles(types), " or "), jsonType(value))
+	}
+
+	if enum, ok := prop["enum"].([]string); ok {
+		s, _ := value.(string)
+		found := false
+		for _, allowed := range enum {
+			found = found || s == allowed
+		}
+		if !found {
+			return fmt.Errorf("must be one of %s, got %v", strings.Join(enum, ", "), value)
+		}
+	}
+
+	if n, ok := value.(float64); ok {
+		if min, ok := prop["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("must be at least %g, got %g", min, n)
+		}
+		if max, ok := prop["maximum"].(float64); ok && n > max {
+			return fmt.Errorf("must be at most %g, got %g", max, n)
+		}
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		if items, ok := prop["items"].(map[string]interface{}); ok {
+			for i, item := range list {
+				if err := validateValue(items, item); err != nil {
+					return fmt.Errorf("item %d %w", i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasJSONType reports whether a decoded JSON value has one of the given schema types
+func hasJSONType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "integer" && actual == "number" && value.(float64) == float64(int64(value.(float64)))) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of a value decoded by encoding/json
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// withArticles turns schema types into phrases for an error message, e.g. "an array"
+func withArticles(types []string) []string {
+	phrases := make([]string, len(types))
+	for i, t := range types {
+		if strings.ContainsAny(t[:1], "aeiou") {
+			phrases[i] = "an " + t
+		} else {
+			phrases[i] = "a " + t
+		}
+	}
+	return phrases
+}
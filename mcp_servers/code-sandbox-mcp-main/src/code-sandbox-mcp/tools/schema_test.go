@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// schemaTestTool declares the kinds of arguments the real tools use
+var schemaTestTool = mcp.NewTool("schema_test",
+	mcp.WithString("container_id", mcp.Required()),
+	mcp.WithString("language", mcp.Enum("python", "bash")),
+	mcp.WithNumber("timeout_seconds", mcp.Min(1), mcp.Max(600)),
+	mcp.WithBoolean("keep_alive"),
+	mcp.WithArray("mounts", StringItems()),
+	mcp.WithString("commands", Types("string", "array")),
+)
+
+func TestValidateArgumentsRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"missing required", map[string]interface{}{"language": "python"}, "missing required argument container_id"},
+		{"required null", map[string]interface{}{"container_id": nil}, "missing required argument container_id"},
+		{"wrong type", map[string]interface{}{"container_id": 42.0}, "invalid argument container_id: must be a string, got number"},
+		{"bool as string", map[string]interface{}{"container_id": "c", "keep_alive": "yes"}, "invalid argument keep_alive: must be a boolean, got string"},
+		{"invalid enum", map[string]interface{}{"container_id": "c", "language": "cobol"}, "invalid argument language: must be one of python, bash, got cobol"},
+		{"below minimum", map[string]interface{}{"container_id": "c", "timeout_seconds": 0.0}, "invalid argument timeout_seconds: must be at least 1, got 0"},
+		{"above maximum", map[string]interface{}{"container_id": "c", "timeout_seconds": 601.0}, "invalid argument timeout_seconds: must be at most 600, got 601"},
+		{"wrong item type", map[string]interface{}{"container_id": "c", "mounts": []interface{}{"/a:/b", 3.0}}, "invalid argument mounts: item 1 must be a string, got number"},
+		{"none of several types", map[string]interface{}{"container_id": "c", "commands": true}, "invalid argument commands: must be a string or an array, got boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := ValidateArguments(schemaTestTool, func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				called = true
+				return mcp.NewToolResultText("ok"), nil
+			})
+			text := callTool(t, handler, tt.args, true)
+			if called {
+				t.Error("the handler ran despite invalid arguments")
+			}
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("error = %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateArgumentsAcceptsValidInput(t *testing.T) {
+	handler := ValidateArguments(schemaTestTool, func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	args := map[string]interface{}{
+		"container_id":    "c",
+		"language":        "bash",
+		"timeout_seconds": 30.0,
+		"keep_alive":      true,
+		"mounts":          []interface{}{"/a:/b"},
+		"commands":        []interface{}{"ls"},
+		// Undeclared arguments and nulls are left to the handler
+		"extra":       1.0,
+		"keep_alive2": nil,
+	}
+	if text := callTool(t, handler, args, false); text != "ok" {
+		t.Errorf("result = %q", text)
+	}
+}
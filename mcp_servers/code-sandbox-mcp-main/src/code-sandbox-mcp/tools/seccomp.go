@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseSeccompProfile validates the seccomp_profile argument and returns the value for the
+// seccomp= security option, or "" to keep Docker's default profile. The argument is "default",
+// "unconfined", or the absolute path of a JSON profile on the server's host. Like the docker CLI,
+// the file is read here and its contents are sent to the daemon, which never sees the path.
+func parseSeccompProfile(arg interface{}) (string, error) {
+	if arg == nil {
+		return "", nil
+	}
+	profile, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("seccomp_profile must be a string")
+	}
+	profile = strings.TrimSpace(profile)
+
+	switch profile {
+	case "", "default":
+		return "", nil
+	case "unconfined":
+		// Turning syscall filtering off is meant for debugging, so the operator has to allow it
+		if !envBool("CODE_SANDBOX_ALLOW_UNCONFINED_SECCOMP", false) {
+			return "", fmt.Errorf("seccomp_profile unconfined is disabled on this server, set CODE_SANDBOX_ALLOW_UNCONFINED_SECCOMP=true to allow it")
+		}
+		return "unconfined", nil
+	}
+
+	if !filepath.IsAbs(profile) {
+		return "", fmt.Errorf("seccomp_profile must be default, unconfined or the absolute path of a JSON profile, got %q", profile)
+	}
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read seccomp profile: %w", err)
+	}
+	if !json.Valid(data) {
+		return "", fmt.Errorf("seccomp profile %s is not valid JSON", profile)
+	}
+	return string(data), nil
+}
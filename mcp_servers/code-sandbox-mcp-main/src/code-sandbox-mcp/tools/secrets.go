@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+const (
+	// secretsDir is where the secrets of a sandbox are written, on a tmpfs of its own
+	secretsDir = "/run/secrets"
+	// maxSecrets caps the number of secrets of one sandbox
+	maxSecrets = 64
+	// maxSecretsBytes caps the combined size of a sandbox's secrets and is the size of their tmpfs
+	maxSecretsBytes = 1024 * 1024
+)
+
+// secretNamePattern limits secret names to plain file names, so a secret can't be written outside secretsDir
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// parseSecrets validates the secrets argument of sandbox_initialize, an object of file names to
+// contents. Errors name the offending secret but never include its content.
+func parseSecrets(arg interface{}) (map[string]string, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	obj, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("secrets must be an object of file names to contents")
+	}
+	if len(obj) > maxSecrets {
+		return nil, fmt.Errorf("secrets can have at most %d entries", maxSecrets)
+	}
+	secrets := make(map[string]string, len(obj))
+	total := 0
+	for name, v := range obj {
+		if !secretNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("secret name %q must be a file name of 1 to 128 letters, digits, '_', '.' or '-', starting with a letter or digit", name)
+		}
+		content, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("secret %s must be a string", name)
+		}
+		total += len(content)
+		secrets[name] = content
+	}
+	if total > maxSecretsBytes {
+		return nil, fmt.Errorf("secrets are %d bytes together, more than the %d bytes allowed", total, maxSecretsBytes)
+	}
+	return secrets, nil
+}
+
+// checkSecretsMounts rejects mounts that would hide the secrets directory or be hidden by it
+func checkSecretsMounts(mounts []mount.Mount) error {
+	for _, m := range mounts {
+		if m.Target == secretsDir || strings.HasPrefix(m.Target, secretsDir+"/") {
+			return fmt.Errorf("mount target %s conflicts with %s, where secrets are written", m.Target, secretsDir)
+		}
+	}
+	return nil
+}
+
+// secretsTmpfsOptions returns the mount options of the secrets tmpfs, which only the sandbox user can read
+func secretsTmpfsOptions(runAsRoot bool) string {
+	options := fmt.Sprintf("rw,noexec,nosuid,nodev,size=%dk,mode=0700", maxSecretsBytes/1024)
+	if !runAsRoot {
+		options += fmt.Sprintf(",uid=%d,gid=%d", sandboxUID, sandboxGID)
+	}
+	return options
+}
+
+// writeSecrets writes each secret to a read-only file in secretsDir of a running sandbox. The files
+// are streamed through tar inside the container as the sandbox user, so they only ever exist in the
+// tmpfs and are owned by that user; the contents are neither logged nor kept by the server.
+func writeSecrets(ctx context.Context, cli DockerAPI, containerID string, secrets map[string]string) error {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		content := secrets[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0400,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+			ModTime:  time.Now(),
+		}); err != nil {
+			return fmt.Errorf("failed to write secrets: %w", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return fmt.Errorf("failed to write secrets: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+
+	if err := putArchive(ctx, cli, containerID, secretsDir, &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to write secrets to %s: %w", secretsDir, err)
+	}
+	return nil
+}
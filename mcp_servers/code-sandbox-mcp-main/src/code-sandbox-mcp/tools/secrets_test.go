@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"archive/tar"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// TestSecretsWrittenToTmpfs checks that secrets are streamed as read-only files into the secrets
+// tmpfs by tar running as the sandbox user, and never go to the container layer or the container's
+// configuration
+func TestSecretsWrittenToTmpfs(t *testing.T) {
+	f := newFakeDocker()
+	extract := captureSecretsExtract(f)
+	_, created := initializeSandbox(t, f, map[string]interface{}{
+		"secrets": map[string]interface{}{"api_key": "s3cr3t", "db.pass": "hunter2"},
+	})
+
+	options := created.HostConfig.Tmpfs[secretsDir]
+	for _, want := range []string{"noexec", "mode=0700", "uid=1000", "gid=1000"} {
+		if !contains(strings.Split(options, ","), want) {
+			t.Errorf("%s tmpfs options = %q, want %s", secretsDir, options, want)
+		}
+	}
+	// A copy through the API would write to the container layer beneath the tmpfs
+	for _, path := range f.copies {
+		if strings.HasPrefix(path, secretsDir) {
+			t.Errorf("secrets were copied to %s through the API", path)
+		}
+	}
+	for _, env := range created.Config.Env {
+		if strings.Contains(env, "s3cr3t") {
+			t.Errorf("a secret is in the environment: %s", env)
+		}
+	}
+
+	if extract.opts == nil {
+		t.Fatal("no command extracted the secrets")
+	}
+	// An empty exec user is the container's user, so the files are owned by the sandbox user
+	if extract.opts.User != "" {
+		t.Errorf("tar ran as %q, want the container user", extract.opts.User)
+	}
+	want := map[string]string{"api_key": "s3cr3t", "db.pass": "hunter2"}
+	if !reflect.DeepEqual(extract.files, want) {
+		t.Errorf("extracted %v, want %v", extract.files, want)
+	}
+	for name, mode := range extract.modes {
+		if mode != 0o400 {
+			t.Errorf("%s has mode %04o, want 0400", name, mode)
+		}
+	}
+}
+
+func TestSecretsOfRootSandbox(t *testing.T) {
+	f := newFakeDocker()
+	extract := captureSecretsExtract(f)
+	_, created := initializeSandbox(t, f, map[string]interface{}{"run_as_root": true, "secrets": map[string]interface{}{"token": "t"}})
+	if options := created.HostConfig.Tmpfs[secretsDir]; strings.Contains(options, "uid=") {
+		t.Errorf("%s tmpfs options = %q, want it owned by root", secretsDir, options)
+	}
+	if extract.opts == nil || extract.opts.User != "" || created.Config.User != "" {
+		t.Errorf("secrets weren't extracted as the root container user: %+v", extract.opts)
+	}
+	if extract.files["token"] != "t" {
+		t.Errorf("extracted %v", extract.files)
+	}
+}
+
+// secretsExtract is what captureSecretsExtract saw of the command writing the secrets
+type secretsExtract struct {
+	opts  *container.ExecOptions
+	files map[string]string
+	modes map[string]int64
+}
+
+// captureSecretsExtract makes f record the tar command that extracts into secretsDir and the
+// archive it was given on stdin
+func captureSecretsExtract(f *fakeDocker) *secretsExtract {
+	extract := &secretsExtract{files: map[string]string{}, modes: map[string]int64{}}
+	f.onExec = func(containerID string, opts container.ExecOptions, stdin string) fakeExecResult {
+		if !reflect.DeepEqual(opts.Cmd, []string{"tar", "-xf", "-", "--no-same-owner", "-C", secretsDir}) {
+			return fakeExecResult{}
+		}
+		extract.opts = &opts
+		tr := tar.NewReader(strings.NewReader(stdin))
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			content, _ := io.ReadAll(tr)
+			extract.files[hdr.Name], extract.modes[hdr.Name] = string(content), hdr.Mode
+		}
+		return fakeExecResult{}
+	}
+	return extract
+}
+
+func TestSecretsRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"path name", map[string]interface{}{"secrets": map[string]interface{}{"../escape": "x"}}, `secret name "../escape" must be a file name`},
+		{"non-string content", map[string]interface{}{"secrets": map[string]interface{}{"n": 1.0}}, "secret n must be a string"},
+		{"too large", map[string]interface{}{"secrets": map[string]interface{}{"big": strings.Repeat("x", maxSecretsBytes+1)}}, "more than the 1048576 bytes allowed"},
+		{"run_once", map[string]interface{}{"secrets": map[string]interface{}{"a": "x"}, "run_once": true, "cmd": "true"}, "secrets can't be combined with run_once"},
+		{"hidden by a mount", map[string]interface{}{"secrets": map[string]interface{}{"a": "x"}, "mounts": []interface{}{"/host:/run/secrets"}}, "conflicts with /run/secrets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeDocker(t, newFakeDocker())
+			text := callTool(t, InitializeEnvironment, tt.args, true)
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("error = %q, want %q", text, tt.want)
+			}
+			// Errors name the secret but never show its content
+			if strings.Contains(text, "xxxx") {
+				t.Error("the error shows the secret's content")
+			}
+		})
+	}
+}
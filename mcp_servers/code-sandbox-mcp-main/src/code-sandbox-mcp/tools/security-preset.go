@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultSecurityPreset applies no bundle, so existing callers keep the per-option defaults
+const defaultSecurityPreset = "none"
+
+// securityPresets maps the security_preset argument to the sandbox_initialize arguments it sets.
+// Arguments the caller passes explicitly win over the preset, so a preset can be loosened one
+// option at a time, e.g. strict with network: bridge.
+var securityPresets = map[string]map[string]interface{}{
+	"none": {},
+	// standard pins the hardening the individual options default to, with the server's default limits
+	"standard": {
+		"network":         "none",
+		"run_as_root":     false,
+		"cap_add":         []interface{}{},
+		"seccomp_profile": "default",
+		"memory_mb":       defaultResources.MemoryMB,
+		"cpu_limit":       defaultResources.CPULimit,
+		"pids_limit":      float64(defaultResources.PidsLimit),
+		"tmpfs_tmp":       true,
+	},
+	// strict is meant for untrusted code: on top of standard the image can't be modified, and the
+	// sandbox gets less memory, CPU, processes and open files
+	"strict": {
+		"network":         "none",
+		"run_as_root":     false,
+		"cap_add":         []interface{}{},
+		"seccomp_profile": "default",
+		"readonly_rootfs": true,
+		"tmpfs_tmp":       true,
+		"tmpfs_size_mb":   float64(defaultTmpfsSizeMB),
+		"memory_mb":       256.0,
+		"cpu_limit":       0.5,
+		"pids_limit":      64.0,
+		"ulimits":         []interface{}{map[string]interface{}{"name": "nofile", "soft": 256.0, "hard": 256.0}},
+	},
+}
+
+// SecurityPresets returns the names of the security presets in alphabetical order
+func SecurityPresets() []string {
+	names := make([]string, 0, len(securityPresets))
+	for name := range securityPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applySecurityPreset returns args with the settings of the requested security_preset filled in
+// wherever the caller didn't give a value. args itself is left unchanged.
+func applySecurityPreset(args map[string]interface{}) (map[string]interface{}, error) {
+	name := defaultSecurityPreset
+	if raw := args["security_preset"]; raw != nil {
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("security_preset must be a string")
+		}
+		name = strings.ToLower(strings.TrimSpace(s))
+	}
+	preset, ok := securityPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown security_preset %q, must be one of %s", name, strings.Join(SecurityPresets(), ", "))
+	}
+	if len(preset) == 0 {
+		return args, nil
+	}
+
+	merged := make(map[string]interface{}, len(args)+len(preset))
+	for key, value := range preset {
+		merged[key] = value
+	}
+	for key, value := range args {
+		if value != nil {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestStrictPreset checks the settings a strict sandbox is created with: no network, a non-root
+// user, a read-only root filesystem with tmpfs scratch space, and tight limits
+func TestStrictPreset(t *testing.T) {
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{"security_preset": "strict"})
+
+	host := created.HostConfig
+	if host.NetworkMode != "none" {
+		t.Errorf("NetworkMode = %q, want none", host.NetworkMode)
+	}
+	if created.Config.User != "1000:1000" {
+		t.Errorf("User = %q, want 1000:1000", created.Config.User)
+	}
+	if !host.ReadonlyRootfs || host.Tmpfs["/tmp"] == "" || host.Tmpfs["/app"] == "" {
+		t.Errorf("ReadonlyRootfs = %v, Tmpfs = %v, want a read-only rootfs with /tmp and /app on tmpfs", host.ReadonlyRootfs, host.Tmpfs)
+	}
+	if host.Memory != 256*1024*1024 || host.NanoCPUs != 5e8 || host.PidsLimit == nil || *host.PidsLimit != 64 {
+		t.Errorf("Memory = %d, NanoCPUs = %d, PidsLimit = %v", host.Memory, host.NanoCPUs, host.PidsLimit)
+	}
+	if len(host.Ulimits) != 1 || host.Ulimits[0].Name != "nofile" || host.Ulimits[0].Hard != 256 {
+		t.Errorf("Ulimits = %v, want nofile 256", host.Ulimits)
+	}
+	if len(host.CapDrop) != 1 || host.CapDrop[0] != "ALL" || len(host.CapAdd) != 0 || !contains(host.SecurityOpt, "no-new-privileges") {
+		t.Errorf("CapDrop = %v, CapAdd = %v, SecurityOpt = %v", host.CapDrop, host.CapAdd, host.SecurityOpt)
+	}
+}
+
+// TestPresetOverride checks that an explicit argument loosens a single setting of the preset
+func TestPresetOverride(t *testing.T) {
+	_, created := initializeSandbox(t, newFakeDocker(), map[string]interface{}{"security_preset": "strict", "network": "bridge"})
+	host := created.HostConfig
+	if host.NetworkMode != "bridge" || !host.ReadonlyRootfs || created.Config.User == "" || *host.PidsLimit != 64 {
+		t.Errorf("NetworkMode = %q, ReadonlyRootfs = %v, User = %q, PidsLimit = %d", host.NetworkMode, host.ReadonlyRootfs, created.Config.User, *host.PidsLimit)
+	}
+}
+
+func TestUnknownPreset(t *testing.T) {
+	useFakeDocker(t, newFakeDocker())
+	text := callTool(t, InitializeEnvironment, map[string]interface{}{"security_preset": "paranoid"}, true)
+	if !strings.Contains(text, `unknown security_preset "paranoid", must be one of none, standard, strict`) {
+		t.Errorf("error = %q", text)
+	}
+}
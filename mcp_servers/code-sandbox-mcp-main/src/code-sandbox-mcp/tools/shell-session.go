@@ -0,0 +1,477 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultSessionShell is the shell started by sandbox_session_start when none is requested
+	defaultSessionShell = "/bin/sh"
+	// maxSessionOutput caps the unread output kept per stream; older output is dropped first
+	maxSessionOutput = 1024 * 1024
+)
+
+// sessionDoneMarker matches the line printed after each input sent to a session, carrying the
+// session's marker, the input's sequence number and the exit status of its last command
+var sessionDoneMarker = regexp.MustCompile(`\n?__CSMCP_DONE_([0-9a-f]+)_(\d+)_(\d+)__\n`)
+
+// sessionDonePrefix starts every done line
+const sessionDonePrefix = "__CSMCP_DONE_"
+
+// maxDoneLineLen bounds the length of a done line, for finding those cut by trimming the output
+const maxDoneLineLen = 128
+
+// shellSession is a long-lived shell in a container, so the working directory and variables
+// set by one input are still there for the next
+type shellSession struct {
+	id          string
+	containerID string
+	// marker tags the shell's processes like the marker of any other exec, and its done lines
+	marker string
+	conn   types.HijackedResponse
+
+	mu       sync.Mutex
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	dropped  int
+	seq      int
+	pending  int
+	lastExit *int
+	// changed is signalled whenever output arrives
+	changed chan struct{}
+	// done is closed once the shell has gone away, with endReason saying why
+	done      chan struct{}
+	endReason string
+}
+
+// sessions is the registry of open shell sessions, keyed by session ID
+var sessions = struct {
+	sync.Mutex
+	byID map[string]*shellSession
+}{byID: make(map[string]*shellSession)}
+
+// sessionInfo is the structured result of opening a session
+type sessionInfo struct {
+	SessionID   string `json:"session_id"`
+	ContainerID string `json:"container_id"`
+	Shell       string `json:"shell"`
+}
+
+// sessionOutput is the structured result of the session tools that return output
+type sessionOutput struct {
+	SessionID string `json:"session_id"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	// ExitCode is the exit status of the last input that finished since the previous call
+	ExitCode *int `json:"exit_code,omitempty"`
+	// Running is true while an input sent to the session hasn't finished yet
+	Running bool `json:"running"`
+	// DroppedBytes counts output discarded because it exceeded the buffer before it was read
+	DroppedBytes int    `json:"dropped_bytes,omitempty"`
+	Ended        bool   `json:"ended"`
+	EndReason    string `json:"end_reason,omitempty"`
+}
+
+// StartSession opens a persistent shell in a container
+func StartSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	shell, _ := request.Params.Arguments["shell"].(string)
+	if shell == "" {
+		shell = defaultSessionShell
+	}
+
+	session, err := startShellSession(ctx, containerID, shell)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error starting session: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(sessionInfo{
+		SessionID:   session.id,
+		ContainerID: containerID,
+		Shell:       shell,
+	})
+}
+
+// SendToSession writes input to a session's shell and waits for it to finish
+func SendToSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session, err := sessionArg(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	input, ok := request.Params.Arguments["input"].(string)
+	if !ok {
+		return newToolResultError("input is required"), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultExecTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	output, err := session.send(ctx, input, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(session.containerID)
+	return newToolResultJSON(output)
+}
+
+// ReadSession returns the output a session produced since it was last read, without waiting
+func ReadSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session, err := sessionArg(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	return newToolResultJSON(session.read())
+}
+
+// CloseSession ends a session's shell and every process started from it
+func CloseSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	session, err := sessionArg(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	output := session.close()
+	return newToolResultJSON(output)
+}
+
+// sessionArg looks up the session named by the required session_id argument
+func sessionArg(args map[string]interface{}) (*shellSession, error) {
+	id, ok := args["session_id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+
+	sessions.Lock()
+	defer sessions.Unlock()
+	session, ok := sessions.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("no open session %s, it may have ended or been closed already", id)
+	}
+	return session, nil
+}
+
+// startShellSession starts a shell with attached stdin, stdout and stderr and registers it.
+// The shell runs as the container's user in its working directory, like every other exec.
+func startShellSession(ctx context.Context, containerID string, shell string) (*shellSession, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// The session is tracked under the container's full ID, so removing the container by any of
+	// its names or IDs ends it
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if info.State == nil || !info.State.Running {
+		return nil, fmt.Errorf("container %s is not running", containerID)
+	}
+	containerID = info.ID
+
+	marker, err := newExecMarker()
+	if err != nil {
+		return nil, err
+	}
+
+	exec, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          []string{shell},
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          []string{execMarkerEnv + "=" + marker},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	// The session outlives the request that opened it, so the attach isn't tied to its context
+	conn, err := cli.ContainerExecAttach(context.Background(), exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+
+	session := &shellSession{
+		id:          marker,
+		containerID: containerID,
+		marker:      marker,
+		conn:        conn,
+		changed:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	go session.readOutput(cli, exec.ID)
+
+	sessions.Lock()
+	sessions.byID[session.id] = session
+	sessions.Unlock()
+	return session, nil
+}
+
+// readOutput collects the shell's output until the stream ends, which happens when the shell
+// exits, the session is closed or the container stops, and then records why the session ended
+// and drops it from the registry
+func (s *shellSession) readOutput(cli DockerAPI, execID string) {
+	_, _ = stdcopy.StdCopy(&sessionStream{session: s}, &sessionStream{session: s, stderr: true}, s.conn.Reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reason := "the session was closed"
+	if info, err := cli.ContainerInspect(ctx, s.containerID); err != nil || info.State == nil || !info.State.Running {
+		reason = "the container is no longer running"
+	} else if exitCode, err := waitForExecExit(ctx, cli, execID); err == nil {
+		reason = fmt.Sprintf("the shell exited with code %d", exitCode)
+	}
+
+	s.mu.Lock()
+	if s.endReason == "" {
+		s.endReason = reason
+	}
+	s.mu.Unlock()
+
+	// Nothing more can be sent to or read from a shell that has gone away
+	sessions.Lock()
+	if sessions.byID[s.id] == s {
+		delete(sessions.byID, s.id)
+	}
+	sessions.Unlock()
+	close(s.done)
+}
+
+// sessionStream appends one of the shell's output streams to the session buffers
+type sessionStream struct {
+	session *shellSession
+	stderr  bool
+}
+
+func (w *sessionStream) Write(p []byte) (int, error) {
+	s := w.session
+	s.mu.Lock()
+	buf := &s.stdout
+	if w.stderr {
+		buf = &s.stderr
+	}
+	buf.Write(p)
+	if over := buf.Len() - maxSessionOutput; over > 0 {
+		if w.stderr {
+			buf.Next(over)
+			s.dropped += over
+		} else {
+			s.trimStdout(over)
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// trimStdout drops the oldest n bytes of unread stdout. Done lines within them are recorded first,
+// since collect never sees them, and one reaching past the cut is dropped whole rather than cut in
+// two. The caller must hold s.mu.
+func (s *shellSession) trimStdout(n int) {
+	data := s.stdout.Bytes()
+	window := data[:min(len(data), n+maxDoneLineLen)]
+	for _, m := range sessionDoneMarker.FindAllSubmatchIndex(window, -1) {
+		if m[0] >= n {
+			break
+		}
+		s.recordDone(string(window[m[2]:m[3]]), string(window[m[4]:m[5]]), string(window[m[6]:m[7]]))
+		// The done line isn't output, so it doesn't count as dropped
+		s.dropped -= m[1] - m[0]
+		n = max(n, m[1])
+	}
+	s.stdout.Next(n)
+	s.dropped += n
+}
+
+// recordDone handles a done line: one of this session's marker for the pending input finishes it
+// with the given exit status. The caller must hold s.mu.
+func (s *shellSession) recordDone(marker string, seq string, exitStatus string) {
+	if marker != s.marker {
+		return
+	}
+	n, _ := strconv.Atoi(seq)
+	exitCode, _ := strconv.Atoi(exitStatus)
+	if n == s.pending {
+		s.pending = 0
+		s.lastExit = &exitCode
+	}
+}
+
+// send writes input to the shell followed by a command printing a done line, then collects the
+// output until that line appears. If the timeout elapses first, the input keeps running and the
+// output so far is returned with Running set; the rest can be read with sandbox_session_read.
+func (s *shellSession) send(ctx context.Context, input string, timeout time.Duration) (*sessionOutput, error) {
+	s.mu.Lock()
+	if s.isEnded() {
+		reason := s.endReason
+		s.mu.Unlock()
+		return nil, fmt.Errorf("session %s has ended: %s", s.id, reason)
+	}
+	if s.pending != 0 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("the previous input to session %s is still running, read the session until it finishes or close it", s.id)
+	}
+	s.seq++
+	s.pending = s.seq
+	s.lastExit = nil
+	seq := s.seq
+	s.mu.Unlock()
+
+	if !strings.HasSuffix(input, "\n") {
+		input += "\n"
+	}
+	done := fmt.Sprintf("printf '\\n__CSMCP_DONE_%s_%d_%%d__\\n' \"$?\"\n", s.marker, seq)
+	if _, err := s.conn.Conn.Write([]byte(input + done)); err != nil {
+		return nil, fmt.Errorf("failed to write to session: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	output := &sessionOutput{SessionID: s.id}
+	for {
+		s.collect(output)
+		if !output.Running || output.Ended {
+			return output, nil
+		}
+
+		select {
+		case <-s.changed:
+		case <-s.done:
+		case <-timer.C:
+			return output, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// read returns the unread output of the session
+func (s *shellSession) read() *sessionOutput {
+	output := &sessionOutput{SessionID: s.id}
+	s.collect(output)
+	return output
+}
+
+// close ends the session: closing stdin ends the shell, and any background processes it started
+// are killed through their exec marker. The session is removed from the registry.
+func (s *shellSession) close() *sessionOutput {
+	sessions.Lock()
+	delete(sessions.byID, s.id)
+	sessions.Unlock()
+
+	s.mu.Lock()
+	if s.endReason == "" {
+		s.endReason = "the session was closed"
+	}
+	s.mu.Unlock()
+
+	_ = s.conn.CloseWrite()
+	s.conn.Close()
+	if cli, err := DockerClient(); err == nil {
+		killExecProcesses(cli, s.containerID, "", s.marker)
+	}
+
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+	}
+	return s.read()
+}
+
+// collect moves the unread output into out, stripping the done lines and recording the exit
+// status of finished inputs. While an input is running, a trailing partial line that may be the
+// start of its done line, split across writes of the shell, is kept for the next call.
+func (s *shellSession) collect(out *sessionOutput) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stdout := s.stdout.String()
+	s.stdout.Reset()
+	for _, m := range sessionDoneMarker.FindAllStringSubmatch(stdout, -1) {
+		s.recordDone(m[1], m[2], m[3])
+	}
+	stdout = sessionDoneMarker.ReplaceAllString(stdout, "")
+	if s.pending != 0 && !s.isEnded() {
+		// The newline printed before the done line belongs to it, so it is kept as well
+		i := strings.LastIndexByte(stdout, '\n')
+		if rest := stdout[i+1:]; strings.HasPrefix(sessionDonePrefix, rest) || strings.HasPrefix(rest, sessionDonePrefix) {
+			s.stdout.WriteString(stdout[max(i, 0):])
+			stdout = stdout[:max(i, 0)]
+		}
+	}
+
+	out.Stdout += stdout
+	out.Stderr += s.stderr.String()
+	s.stderr.Reset()
+	out.DroppedBytes += s.dropped
+	s.dropped = 0
+	if s.lastExit != nil {
+		out.ExitCode = s.lastExit
+		s.lastExit = nil
+	}
+	out.Ended = s.isEnded()
+	out.Running = s.pending != 0 && !out.Ended
+	if out.Ended {
+		out.EndReason = s.endReason
+	}
+}
+
+// isEnded reports whether the shell has gone away. The caller must hold s.mu.
+func (s *shellSession) isEnded() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// forgetSessions ends and drops the shell sessions of a removed container, whose streams are gone
+func forgetSessions(containerID string) {
+	var ended []*shellSession
+	sessions.Lock()
+	for id, s := range sessions.byID {
+		if strings.HasPrefix(s.containerID, containerID) {
+			ended = append(ended, s)
+			delete(sessions.byID, id)
+		}
+	}
+	sessions.Unlock()
+
+	for _, s := range ended {
+		s.mu.Lock()
+		if s.endReason == "" {
+			s.endReason = "the container was removed"
+		}
+		s.mu.Unlock()
+		s.conn.Close()
+	}
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSessionDoneLineSplitAcrossWrites checks that a done line arriving in pieces still finishes
+// the input it belongs to, and that none of it shows up in the output
+func TestSessionDoneLineSplitAcrossWrites(t *testing.T) {
+	s := &shellSession{
+		id:      "abc123",
+		marker:  "abc123",
+		seq:     1,
+		pending: 1,
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	stdout := &sessionStream{session: s}
+	output := &sessionOutput{SessionID: s.id}
+
+	for _, chunk := range []string{"hello\n", "world", "\n__CSMCP_", "DONE_abc1", "23_1_7__\n"} {
+		if _, err := stdout.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		s.collect(output)
+	}
+
+	if output.Running {
+		t.Fatal("input is still running after its done line arrived")
+	}
+	if output.ExitCode == nil || *output.ExitCode != 7 {
+		t.Fatalf("exit code = %v, want 7", output.ExitCode)
+	}
+	if output.Stdout != "hello\nworld" {
+		t.Errorf("stdout = %q, want %q", output.Stdout, "hello\nworld")
+	}
+}
+
+// TestSessionPartialLineWithoutInput checks that output is never held back while no input is running
+func TestSessionPartialLineWithoutInput(t *testing.T) {
+	s := &shellSession{id: "abc123", marker: "abc123", changed: make(chan struct{}, 1), done: make(chan struct{})}
+	if _, err := (&sessionStream{session: s}).Write([]byte("__CSMCP")); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.read().Stdout; got != "__CSMCP" {
+		t.Errorf("stdout = %q, want %q", got, "__CSMCP")
+	}
+}
+
+// TestForgetSessions checks that removing a container drops its sessions from the registry
+func TestForgetSessions(t *testing.T) {
+	conn, _ := newFakeConn()
+	s := &shellSession{
+		id:          "forget-me",
+		containerID: "0123456789abcdef",
+		conn:        conn,
+		changed:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	sessions.Lock()
+	sessions.byID[s.id] = s
+	sessions.Unlock()
+
+	forgetContainer("0123456789ab")
+
+	if _, err := sessionArg(map[string]interface{}{"session_id": s.id}); err == nil {
+		t.Error("the session of a removed container is still registered")
+	}
+	if s.endReason != "the container was removed" {
+		t.Errorf("end reason = %q", s.endReason)
+	}
+}
+
+// TestSessionDoneLineKeptThroughTrimming checks that an input finishes even when its done line is
+// followed by more than the buffer holds before anyone reads it, and that the dropped bytes only
+// count output
+func TestSessionDoneLineKeptThroughTrimming(t *testing.T) {
+	s := &shellSession{id: "abc123", marker: "abc123", seq: 1, pending: 1, changed: make(chan struct{}, 1), done: make(chan struct{})}
+	stdout := &sessionStream{session: s}
+
+	// The done line straddles the cut of the second write
+	doneLine := "\n__CSMCP_DONE_abc123_1_3__\n"
+	if _, err := stdout.Write([]byte("x" + doneLine)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdout.Write([]byte(strings.Repeat("y", maxSessionOutput-len(doneLine)+5))); err != nil {
+		t.Fatal(err)
+	}
+
+	output := s.read()
+	if output.Running {
+		t.Fatal("input is still running after its done line was trimmed")
+	}
+	if output.ExitCode == nil || *output.ExitCode != 3 {
+		t.Fatalf("exit code = %v, want 3", output.ExitCode)
+	}
+	if output.DroppedBytes != 1 {
+		t.Errorf("dropped bytes = %d, want the 1 byte of output before the done line", output.DroppedBytes)
+	}
+	if strings.Contains(output.Stdout, "CSMCP") || len(output.Stdout) != maxSessionOutput-len(doneLine)+5 {
+		t.Errorf("stdout holds %d bytes, want only the output after the done line", len(output.Stdout))
+	}
+}
+
+// TestSessionUnregisteredWhenShellEnds checks that a session whose shell went away is dropped from
+// the registry without being closed
+func TestSessionUnregisteredWhenShellEnds(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("shell-ends", nil)
+	conn, server := newFakeConn()
+	s := &shellSession{
+		id:          "ends-on-eof",
+		containerID: id,
+		conn:        conn,
+		changed:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	sessions.Lock()
+	sessions.byID[s.id] = s
+	sessions.Unlock()
+
+	go s.readOutput(f, "no-such-exec")
+	server.stdout.Close()
+	<-s.done
+
+	if _, err := sessionArg(map[string]interface{}{"session_id": s.id}); err == nil {
+		t.Error("the session is still registered after its shell went away")
+	}
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"time"
+)
+
+// defaultShutdownGrace bounds how long the server waits for its sandboxes to be removed on exit
+const defaultShutdownGrace = 15 * time.Second
+
+// CleanupOnShutdown removes the sandboxes created by this server process as it exits, so a killed
+// server doesn't leave containers running. Sandboxes of other servers sharing the Docker daemon are
+// left alone. Setting CODE_SANDBOX_CLEANUP_ON_EXIT to false keeps them for reuse after a restart.
+// The cleanup gives up after CODE_SANDBOX_SHUTDOWN_GRACE (default 15s); containers still left over
+// are orphans, which the reaper of a later server removes once CODE_SANDBOX_ORPHAN_TTL has passed.
+func CleanupOnShutdown() {
+	if !envBool("CODE_SANDBOX_CLEANUP_ON_EXIT", true) {
+		return
+	}
+	grace := envDuration("CODE_SANDBOX_SHUTDOWN_GRACE", defaultShutdownGrace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	result, err := stopAllSandboxes(ctx, true, "")
+	if err != nil {
+		logger.Error("shutdown cleanup failed", "error", err)
+		return
+	}
+	for _, f := range result.Failed {
+		logger.Error("shutdown failed to remove container", "container_id", f.ContainerID, "error", f.Error)
+	}
+	if result.Removed > 0 {
+		logger.Info("shutdown removed sandboxes", "count", result.Removed)
+	}
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// stopAllResult summarises a bulk cleanup of the managed containers
+type stopAllResult struct {
+	Removed    int              `json:"removed"`
+	RemovedIDs []string         `json:"removed_container_ids"`
+	Failed     []stopAllFailure `json:"failed"`
+}
+
+// stopAllFailure is a container that could not be removed during a bulk cleanup
+type stopAllFailure struct {
+	ContainerID string `json:"container_id"`
+	Error       string `json:"error"`
+}
+
+// StopAllSandboxes stops and removes every container created by this server, or with session_id
+// only those of that client session
+func StopAllSandboxes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	clientSession, err := parseSessionID(request.Params.Arguments["session_id"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := stopAllSandboxes(ctx, false, clientSession)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	summary := fmt.Sprintf("Removed %d sandbox(es)", result.Removed)
+	if len(result.Failed) > 0 {
+		summary += fmt.Sprintf(", %d could not be removed", len(result.Failed))
+	}
+	return newToolResultJSONWithSummary(result, summary)
+}
+
+// stopAllSandboxes stops and removes every managed container, or with sessionOnly only those
+// created by this server process, and with a clientSession only those attributed to it. It is
+// best-effort: a container that fails to stop is recorded and the others are still removed. The
+// containers are stopped concurrently, since each one may take up to the stop timeout to exit.
+func stopAllSandboxes(ctx context.Context, sessionOnly bool, clientSession string) (*stopAllResult, error) {
+	managed, err := listManagedContainers(ctx, clientSession)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := managed[:0]
+	for _, c := range managed {
+		if !sessionOnly || c.Labels[serverSessionLabel] == serverSessionID {
+			containers = append(containers, c)
+		}
+	}
+
+	errs := make([]error, len(containers))
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		go func(i int, containerID string) {
+			defer wg.Done()
+			errs[i] = stopAndRemoveContainer(ctx, containerID, defaultStopTimeoutSeconds, true)
+		}(i, c.ID)
+	}
+	wg.Wait()
+
+	result := &stopAllResult{RemovedIDs: []string{}, Failed: []stopAllFailure{}}
+	for i, c := range containers {
+		if errs[i] != nil {
+			result.Failed = append(result.Failed, stopAllFailure{ContainerID: c.ID, Error: errs[i].Error()})
+			continue
+		}
+		forgetContainer(c.ID)
+		result.Removed++
+		result.RemovedIDs = append(result.RemovedIDs, c.ID)
+	}
+	return result, nil
+}
@@ -3,50 +3,109 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// defaultStopTimeoutSeconds is how long a sandbox's processes get to exit before they are killed
+const defaultStopTimeoutSeconds = 10
+
 // StopContainer stops and removes a container by its ID
 func StopContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Get the container ID from the request
-	containerId, ok := request.Params.Arguments["container_id"].(string)
-	if !ok || containerId == "" {
-		return mcp.NewToolResultText("Error: container_id is required"), nil
+	containerId, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	// Zero kills the container right away
+	stopTimeout, err := nonNegativeNumberArg(request.Params.Arguments, "stop_timeout_seconds", defaultStopTimeoutSeconds)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	// Anonymous volumes go with the container unless the caller wants to keep them
+	removeVolumes := true
+	if v, ok := request.Params.Arguments["remove_volumes"].(bool); ok {
+		removeVolumes = v
 	}
 
 	// Stop and remove the container
-	if err := stopAndRemoveContainer(ctx, containerId); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err)), nil
+	if err := stopAndRemoveContainer(ctx, containerId, int(stopTimeout), removeVolumes); err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
 	}
 
+	forgetContainer(containerId)
 	return mcp.NewToolResultText(fmt.Sprintf("Successfully stopped and removed container: %s", containerId)), nil
 }
 
-// stopAndRemoveContainer stops and removes a Docker container
-func stopAndRemoveContainer(ctx context.Context, containerId string) error {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
+// stopAndRemoveContainer stops and removes a Docker container, killing its processes if they
+// haven't exited after timeoutSeconds. removeVolumes also removes the container's anonymous
+// volumes; Docker never removes named volumes along with a container.
+func stopAndRemoveContainer(ctx context.Context, containerId string, timeoutSeconds int, removeVolumes bool) error {
+	cli, err := DockerClient()
 	if err != nil {
-		return fmt.Errorf("failed to create Docker client: %w", err)
+		return err
+	}
+
+	// Only remove containers this server created, so a mistyped or colliding ID
+	// can't take down an unrelated container.
+	info, err := cli.ContainerInspect(ctx, containerId)
+	if err != nil {
+		return inspectError(containerId, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to remove it", containerId)
 	}
-	defer cli.Close()
 
 	// Attempt to stop the container with a timeout, but don't fail even if it errors.
-	timeout := 10 // seconds
-	_ = cli.ContainerStop(ctx, containerId, container.StopOptions{Timeout: &timeout})
+	start := time.Now()
+	if err := withDockerRetry(ctx, "stop container", func() error {
+		return cli.ContainerStop(ctx, containerId, container.StopOptions{Timeout: &timeoutSeconds})
+	}); err != nil {
+		logger.Warn("failed to stop container, removing it by force", "container_id", containerId, "error", err)
+	} else {
+		logger.Info("container stopped", "container_id", containerId, "duration", time.Since(start))
+	}
 
 	// Always attempt force remove so the container is cleaned up regardless of state.
-	if err := cli.ContainerRemove(ctx, containerId, container.RemoveOptions{
-		RemoveVolumes: true,
-		Force:         true,
-	}); err != nil {
+	err = withDockerRetry(ctx, "remove container", func() error {
+		return cli.ContainerRemove(ctx, containerId, container.RemoveOptions{
+			RemoveVolumes: removeVolumes,
+			Force:         true,
+		})
+	})
+	switch {
+	case err == nil, errdefs.IsNotFound(err):
+		// An auto_remove sandbox may already be gone once it has stopped
+		containersStopped.Inc()
+		logger.Info("container removed", "container_id", containerId)
+		return nil
+	case errdefs.IsConflict(err) && strings.Contains(err.Error(), "already in progress"):
+		// ... or Docker may still be removing it, in which case wait for that to finish
+		return waitForRemoval(ctx, cli, containerId)
+	default:
 		return fmt.Errorf("failed to remove container: %w", err)
 	}
+}
 
-	return nil
+// waitForRemoval waits until Docker has finished removing a container that is already being removed
+func waitForRemoval(ctx context.Context, cli DockerAPI, containerId string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	waitCh, errCh := cli.ContainerWait(ctx, containerId, container.WaitConditionRemoved)
+	select {
+	case <-waitCh:
+		return nil
+	case err := <-errCh:
+		if errdefs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to wait for container removal: %w", err)
+	}
 }
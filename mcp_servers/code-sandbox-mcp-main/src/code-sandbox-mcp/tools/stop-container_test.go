@@ -0,0 +1,65 @@
+package tools
+
+import "testing"
+
+func TestStopContainerRemovesByForce(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("stop-me", nil)
+
+	callTool(t, StopContainer, map[string]interface{}{"container_id": id}, false)
+
+	if len(f.stops) != 1 || f.stops[0] != id {
+		t.Errorf("stopped %v, want %s", f.stops, id)
+	}
+	if len(f.removes) != 1 {
+		t.Fatalf("ContainerRemove was called %d times, want 1", len(f.removes))
+	}
+	opts := f.removes[0].Options
+	if !opts.Force || !opts.RemoveVolumes {
+		t.Errorf("remove options = %+v, want Force and RemoveVolumes", opts)
+	}
+	if len(f.containers) != 0 {
+		t.Error("the container is still there")
+	}
+}
+
+func TestStopContainerRefusesUnmanaged(t *testing.T) {
+	f := newFakeDocker()
+	useFakeDocker(t, f)
+	id := f.addContainer("not-ours", map[string]string{})
+
+	callTool(t, StopContainer, map[string]interface{}{"container_id": id}, true)
+
+	if len(f.stops) != 0 || len(f.removes) != 0 {
+		t.Errorf("an unmanaged container was stopped (%v) or removed (%v)", f.stops, f.removes)
+	}
+}
+
+func TestStopContainerRemoveVolumes(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want bool
+	}{
+		{"default", map[string]interface{}{}, true},
+		{"remove", map[string]interface{}{"remove_volumes": true}, true},
+		{"keep", map[string]interface{}{"remove_volumes": false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDocker()
+			useFakeDocker(t, f)
+			tt.args["container_id"] = f.addContainer("volumes", nil)
+
+			callTool(t, StopContainer, tt.args, false)
+
+			if len(f.removes) != 1 {
+				t.Fatalf("ContainerRemove was called %d times, want 1", len(f.removes))
+			}
+			if opts := f.removes[0].Options; opts.RemoveVolumes != tt.want || !opts.Force {
+				t.Errorf("remove options = %+v, want RemoveVolumes %v and Force", opts, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// defaultNofileLimit caps the open file descriptors of a sandbox when the caller doesn't set nofile
+const defaultNofileLimit = 1024
+
+// knownUlimits are the resource limits Docker can set on a container, as named by setrlimit(2) without the RLIMIT_ prefix
+var knownUlimits = map[string]bool{
+	"as": true, "core": true, "cpu": true, "data": true, "fsize": true, "locks": true,
+	"memlock": true, "msgqueue": true, "nice": true, "nofile": true, "nproc": true,
+	"rss": true, "rtprio": true, "rttime": true, "sigpending": true, "stack": true,
+}
+
+// parseUlimits validates the ulimits argument, an array of {name, soft, hard} objects, and adds the
+// default nofile limit unless the caller sets one. The limits are returned sorted by name.
+func parseUlimits(arg interface{}) ([]*container.Ulimit, error) {
+	limits := map[string]*container.Ulimit{}
+	if arg != nil {
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ulimits must be an array of {name, soft, hard} objects")
+		}
+		for _, item := range list {
+			ulimit, err := parseUlimit(item)
+			if err != nil {
+				return nil, err
+			}
+			if limits[ulimit.Name] != nil {
+				return nil, fmt.Errorf("ulimit %s is given more than once", ulimit.Name)
+			}
+			limits[ulimit.Name] = ulimit
+		}
+	}
+
+	if limits["nofile"] == nil {
+		limits["nofile"] = &container.Ulimit{Name: "nofile", Soft: defaultNofileLimit, Hard: defaultNofileLimit}
+	}
+
+	ulimits := make([]*container.Ulimit, 0, len(limits))
+	for _, ulimit := range limits {
+		ulimits = append(ulimits, ulimit)
+	}
+	sort.Slice(ulimits, func(i, j int) bool { return ulimits[i].Name < ulimits[j].Name })
+	return ulimits, nil
+}
+
+// parseUlimit validates a single {name, soft, hard} entry of the ulimits argument.
+// hard defaults to soft, and soft may not exceed hard.
+func parseUlimit(item interface{}) (*container.Ulimit, error) {
+	entry, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("each ulimits entry must be an object with name, soft and hard")
+	}
+
+	name, _ := entry["name"].(string)
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !knownUlimits[name] {
+		known := make([]string, 0, len(knownUlimits))
+		for k := range knownUlimits {
+			known = append(known, k)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unknown ulimit %q, known ulimits: %s", name, strings.Join(known, ", "))
+	}
+
+	soft, ok := entry["soft"].(float64)
+	if !ok || soft < 0 || soft != float64(int64(soft)) {
+		return nil, fmt.Errorf("ulimit %s: soft must be a non-negative integer", name)
+	}
+	hard := soft
+	if raw, present := entry["hard"]; present && raw != nil {
+		hard, ok = raw.(float64)
+		if !ok || hard < 0 || hard != float64(int64(hard)) {
+			return nil, fmt.Errorf("ulimit %s: hard must be a non-negative integer", name)
+		}
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("ulimit %s: soft limit %d exceeds hard limit %d", name, int64(soft), int64(hard))
+	}
+
+	return &container.Ulimit{Name: name, Soft: int64(soft), Hard: int64(hard)}, nil
+}
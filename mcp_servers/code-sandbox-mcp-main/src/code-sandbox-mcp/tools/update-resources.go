@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// updateResourcesResult is the structured result of changing a sandbox's resource limits. The
+// limits are read back from the container, so they are the ones in effect after the update.
+type updateResourcesResult struct {
+	ContainerID string  `json:"container_id"`
+	MemoryBytes int64   `json:"memory_bytes"`
+	CPUs        float64 `json:"cpus"`
+	PidsLimit   int64   `json:"pids_limit"`
+	// Warnings are passed on from the daemon, e.g. when the kernel doesn't support a limit
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// resourceUpdate holds the limits to change; zero fields are left as they are
+type resourceUpdate struct {
+	MemoryMB  float64
+	CPULimit  float64
+	PidsLimit int64
+}
+
+// UpdateResources changes the memory, CPU and process limits of a sandbox without recreating it
+func UpdateResources(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	update, err := parseResourceUpdate(request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := updateContainerResources(ctx, containerID, update)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// parseResourceUpdate reads the limits to change, of which at least one must be given
+func parseResourceUpdate(args map[string]interface{}) (resourceUpdate, error) {
+	var update resourceUpdate
+	var err error
+	if update.MemoryMB, err = positiveNumberArg(args, "memory_mb", 0); err != nil {
+		return update, err
+	}
+	if update.CPULimit, err = positiveNumberArg(args, "cpu_limit", 0); err != nil {
+		return update, err
+	}
+	pidsLimit, err := positiveNumberArg(args, "pids_limit", 0)
+	if err != nil {
+		return update, err
+	}
+	// As at create time, a limit below one would be truncated to zero, which Docker treats as unlimited
+	if pidsLimit > 0 && pidsLimit < 1 {
+		return update, fmt.Errorf("pids_limit must be at least 1")
+	}
+	update.PidsLimit = int64(pidsLimit)
+	if err := checkResourceMaximums(update.MemoryMB, update.CPULimit, update.PidsLimit); err != nil {
+		return update, err
+	}
+
+	if update.MemoryMB == 0 && update.CPULimit == 0 && update.PidsLimit == 0 {
+		return update, fmt.Errorf("at least one of memory_mb, cpu_limit or pids_limit is required")
+	}
+	return update, nil
+}
+
+// updateContainerResources applies update to a managed container and returns the limits in effect
+// afterwards. Lowering the memory limit of a running sandbox below what it uses makes the kernel
+// refuse the change, so that case is reported up front with the current usage.
+func updateContainerResources(ctx context.Context, containerID string, update resourceUpdate) (*updateResourcesResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Like sandbox_stop, only touch containers this server created
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	if !isManaged(info.Config.Labels) {
+		return nil, fmt.Errorf("container %s was not created by code-sandbox-mcp, refusing to update its resources", containerID)
+	}
+
+	var resources container.Resources
+	if update.MemoryMB > 0 {
+		memoryBytes := int64(update.MemoryMB * 1024 * 1024)
+		if info.State != nil && info.State.Running {
+			stats, err := getContainerStats(ctx, containerID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check the current memory usage: %w", err)
+			}
+			if stats.MemoryUsageBytes > uint64(memoryBytes) {
+				return nil, fmt.Errorf("memory_mb %g is below the %.1f MB the sandbox currently uses; free memory in the sandbox first or choose a higher limit",
+					update.MemoryMB, float64(stats.MemoryUsageBytes)/1024/1024)
+			}
+		}
+		// Swap stays capped at the memory limit, as it is at create time
+		resources.Memory = memoryBytes
+		resources.MemorySwap = memoryBytes
+	}
+	if update.CPULimit > 0 {
+		resources.NanoCPUs = int64(update.CPULimit * 1e9)
+	}
+	if update.PidsLimit > 0 {
+		resources.PidsLimit = &update.PidsLimit
+	}
+
+	resp, err := cli.ContainerUpdate(ctx, containerID, container.UpdateConfig{Resources: resources})
+	if err != nil {
+		return nil, fmt.Errorf("the daemon refused to update the resources of container %s: %w", containerID, err)
+	}
+
+	info, err = cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, inspectError(containerID, err)
+	}
+	limits := describeLimits(info.HostConfig)
+	return &updateResourcesResult{
+		ContainerID: info.ID,
+		MemoryBytes: limits.MemoryBytes,
+		CPUs:        limits.CPUs,
+		PidsLimit:   limits.PidsLimit,
+		Warnings:    resp.Warnings,
+	}, nil
+}
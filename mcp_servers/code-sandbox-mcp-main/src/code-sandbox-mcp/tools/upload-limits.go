@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// defaultMaxUploadBytes caps the uncompressed size of a directory upload
+	defaultMaxUploadBytes = 100 * 1024 * 1024
+	// defaultMaxUploadFiles caps the number of entries in a directory upload
+	defaultMaxUploadFiles = 10000
+)
+
+// uploadLimits tracks the size and entry count of an archive being uploaded, so a crafted
+// archive can't fill the sandbox's disk or the server's memory
+type uploadLimits struct {
+	maxBytes int64
+	maxFiles int64
+	bytes    int64
+	files    int64
+}
+
+// newUploadLimits returns the limits set by CODE_SANDBOX_MAX_UPLOAD_BYTES and CODE_SANDBOX_MAX_UPLOAD_FILES
+func newUploadLimits() *uploadLimits {
+	return &uploadLimits{
+		maxBytes: envInt("CODE_SANDBOX_MAX_UPLOAD_BYTES", defaultMaxUploadBytes),
+		maxFiles: envInt("CODE_SANDBOX_MAX_UPLOAD_FILES", defaultMaxUploadFiles),
+	}
+}
+
+// add accounts for one archive entry and fails once either limit is exceeded
+func (l *uploadLimits) add(header *tar.Header) error {
+	l.files++
+	if l.files > l.maxFiles {
+		return fmt.Errorf("upload has more than %d files and directories, the limit set by CODE_SANDBOX_MAX_UPLOAD_FILES", l.maxFiles)
+	}
+	l.bytes += header.Size
+	if l.bytes > l.maxBytes {
+		return fmt.Errorf("upload is larger than %d bytes uncompressed, the limit set by CODE_SANDBOX_MAX_UPLOAD_BYTES", l.maxBytes)
+	}
+	return nil
+}
+
+// repackArchive checks a client-supplied tar archive, optionally gzip-compressed, against the
+// limits and returns it as a plain tar archive. Entries keep their modes and directory structure;
+// entries escaping the destination or other than files, directories and links are rejected.
+// The limits are enforced while reading, so a compressed archive can't expand without bound.
+func repackArchive(data []byte, limits *uploadLimits) (*bytes.Buffer, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip data: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("archive entry %q points outside the destination directory", header.Name)
+		}
+		if name == "." {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink, tar.TypeLink:
+		default:
+			return nil, fmt.Errorf("archive entry %q is not a regular file, directory or link", header.Name)
+		}
+		if err := limits.add(header); err != nil {
+			return nil, err
+		}
+
+		header.Name = name
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		// The header's size is what was counted, so never copy more than it claims
+		if _, err := io.CopyN(tw, tr, header.Size); err != nil && header.Size > 0 {
+			return nil, fmt.Errorf("failed to read archive entry %q: %w", header.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
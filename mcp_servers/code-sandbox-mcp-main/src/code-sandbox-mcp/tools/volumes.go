@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// volumeNamePattern is the set of names Docker accepts for a named volume
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// volumeResult is the structured result of creating a volume
+type volumeResult struct {
+	Name       string `json:"name"`
+	Driver     string `json:"driver"`
+	Mountpoint string `json:"mountpoint"`
+	CreatedAt  string `json:"created_at"`
+	SessionID  string `json:"session_id,omitempty"`
+	// Created is false when a volume of this name created by this server already existed
+	Created bool `json:"created"`
+}
+
+// CreateVolume creates a named Docker volume that sandboxes can attach to keep data beyond their own lifetime
+func CreateVolume(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	name, err := parseVolumeName(request.Params.Arguments["name"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	sessionID, err := parseSessionID(request.Params.Arguments["session_id"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := createVolume(ctx, name, sessionID)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+	return newToolResultJSON(result)
+}
+
+// RemoveVolume removes a named volume created by CreateVolume, refusing while a container still uses it
+func RemoveVolume(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	name, err := parseVolumeName(request.Params.Arguments["name"])
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	if err := removeVolume(ctx, name); err != nil {
+		return newToolResultError(fmt.Sprintf("Error: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully removed volume: %s", name)), nil
+}
+
+// parseVolumeName validates a volume name argument
+func parseVolumeName(arg interface{}) (string, error) {
+	name, ok := arg.(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if !volumeNamePattern.MatchString(name) {
+		return "", fmt.Errorf("volume name %q must be at least two letters, digits, '_', '.' or '-', starting with a letter or digit", name)
+	}
+	return name, nil
+}
+
+// createVolume creates a volume labelled as managed by this server. Creating a volume that this
+// server already created returns it unchanged, so callers can set up their volumes idempotently.
+func createVolume(ctx context.Context, name string, sessionID string) (*volumeResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Docker's create silently returns an existing volume, which may belong to something else
+	existing, err := cli.VolumeInspect(ctx, name)
+	switch {
+	case err == nil:
+		if !isManaged(existing.Labels) {
+			return nil, fmt.Errorf("volume %s already exists and was not created by code-sandbox-mcp, choose a different name", name)
+		}
+		return newVolumeResult(existing, false), nil
+	case !errdefs.IsNotFound(err):
+		return nil, fmt.Errorf("failed to inspect volume: %w", err)
+	}
+
+	vol, err := cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: managedLabels(sessionID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	return newVolumeResult(vol, true), nil
+}
+
+// newVolumeResult describes a volume for the tool result
+func newVolumeResult(vol volume.Volume, created bool) *volumeResult {
+	return &volumeResult{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Mountpoint: vol.Mountpoint,
+		CreatedAt:  vol.CreatedAt,
+		SessionID:  vol.Labels[clientSessionLabel],
+		Created:    created,
+	}
+}
+
+// removeVolume removes a managed volume. It is never forced, so data a container is using can't
+// disappear from under it; the error names the containers that have to be stopped first.
+func removeVolume(ctx context.Context, name string) error {
+	cli, err := DockerClient()
+	if err != nil {
+		return err
+	}
+
+	vol, err := cli.VolumeInspect(ctx, name)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("no such volume: %s", name)
+		}
+		return fmt.Errorf("failed to inspect volume: %w", err)
+	}
+	if !isManaged(vol.Labels) {
+		return fmt.Errorf("volume %s was not created by code-sandbox-mcp, refusing to remove it", name)
+	}
+
+	err = cli.VolumeRemove(ctx, name, false)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsConflict(err) {
+		return fmt.Errorf("failed to remove volume: %w", err)
+	}
+
+	// Stopped containers keep their volumes in use too, so list all of them
+	users, listErr := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("volume", name)),
+	})
+	if listErr != nil || len(users) == 0 {
+		return fmt.Errorf("volume %s is still in use by a container, stop the sandboxes using it first", name)
+	}
+	ids := make([]string, len(users))
+	for i, c := range users {
+		ids[i] = c.ID[:12]
+	}
+	return fmt.Errorf("volume %s is still in use by container(s) %s, stop them with sandbox_stop first", name, strings.Join(ids, ", "))
+}
+
+// parseVolumeMounts converts the volumes argument, a list of volume_name:container_path[:ro|rw]
+// strings, into volume mounts. Volumes hold data meant to be kept, so they are writable unless ro is given.
+func parseVolumeMounts(arg interface{}) ([]mount.Mount, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("volumes must be an array of volume_name:container_path[:ro|rw] strings")
+	}
+
+	mounts := make([]mount.Mount, 0, len(list))
+	for _, item := range list {
+		spec, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("each volumes entry must be a string")
+		}
+
+		parts := strings.Split(spec, ":")
+		readOnly := false
+		if len(parts) == 3 {
+			switch parts[2] {
+			case "ro":
+				readOnly = true
+			case "rw":
+			default:
+				return nil, fmt.Errorf("volume %q: mode must be ro or rw", spec)
+			}
+			parts = parts[:2]
+		}
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("volume %q must be in volume_name:container_path[:ro|rw] form", spec)
+		}
+		name, containerPath := parts[0], parts[1]
+
+		if !volumeNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("volume %q: invalid volume name %q", spec, name)
+		}
+		if !path.IsAbs(containerPath) {
+			return nil, fmt.Errorf("volume %q: container path must be absolute", spec)
+		}
+
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   name,
+			Target:   path.Clean(containerPath),
+			ReadOnly: readOnly,
+		})
+	}
+	return mounts, nil
+}
+
+// checkVolumeMounts makes sure every volume to attach exists and was created by this server.
+// Docker would otherwise create a missing volume on the fly, without the labels that allow removing it,
+// and a sandbox could attach a volume holding some other container's data.
+func checkVolumeMounts(ctx context.Context, cli DockerAPI, mounts []mount.Mount) error {
+	for _, m := range mounts {
+		if m.Type != mount.TypeVolume {
+			continue
+		}
+		vol, err := cli.VolumeInspect(ctx, m.Source)
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				return fmt.Errorf("no such volume: %s, create it with sandbox_volume_create first", m.Source)
+			}
+			return fmt.Errorf("failed to inspect volume: %w", err)
+		}
+		if !isManaged(vol.Labels) {
+			return fmt.Errorf("volume %s was not created by code-sandbox-mcp, refusing to attach it", m.Source)
+		}
+	}
+	return nil
+}
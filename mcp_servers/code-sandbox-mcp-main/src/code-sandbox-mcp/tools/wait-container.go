@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultWaitTimeout bounds how long sandbox_wait blocks when no timeout is requested
+const defaultWaitTimeout = 60 * time.Second
+
+// waitResult is the structured result of waiting for a container to stop
+type waitResult struct {
+	ContainerID string `json:"container_id"`
+	// Status is "exited" when the container stopped and "running" when the timeout elapsed first
+	Status   string `json:"status"`
+	ExitCode *int64 `json:"exit_code,omitempty"`
+	TimedOut bool   `json:"timed_out"`
+	Error    string `json:"error,omitempty"`
+}
+
+// WaitForContainer blocks until a container stops running or the timeout elapses
+func WaitForContainer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	timeout, err := parseTimeoutSeconds(request.Params.Arguments, "timeout_seconds", defaultWaitTimeout)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	result, err := waitForContainer(ctx, containerID, timeout)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error waiting for container: %v", err)), nil
+	}
+
+	touchContainer(containerID)
+	return newToolResultJSON(result)
+}
+
+// waitForContainer waits for the container to stop. Running out of time is not an error but a
+// result with TimedOut set; cancellation of ctx, e.g. by the MCP client, is reported as an error.
+func waitForContainer(ctx context.Context, containerID string, timeout time.Duration) (*waitResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The daemon answers with the exit code once the container is no longer running,
+	// immediately if it already stopped
+	respCh, errCh := cli.ContainerWait(waitCtx, containerID, container.WaitConditionNotRunning)
+	select {
+	case resp := <-respCh:
+		result := &waitResult{ContainerID: containerID, Status: "exited", ExitCode: &resp.StatusCode}
+		if resp.Error != nil {
+			result.Error = resp.Error.Message
+		}
+		return result, nil
+	case err := <-errCh:
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if waitCtx.Err() == context.DeadlineExceeded {
+			return &waitResult{ContainerID: containerID, Status: "running", TimedOut: true}, nil
+		}
+		return nil, err
+	}
+}
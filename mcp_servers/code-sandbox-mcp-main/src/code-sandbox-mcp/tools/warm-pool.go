@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxWarmPoolSize bounds CODE_SANDBOX_WARM_POOL_SIZE, since every pooled sandbox holds its resources while idle
+	maxWarmPoolSize = 32
+	// warmPoolRetryDelay is how long the pool waits after a failed create before trying again
+	warmPoolRetryDelay = 30 * time.Second
+)
+
+// warmPoolConfig is the image and number of idle sandboxes the pool keeps ready
+type warmPoolConfig struct {
+	Image string
+	Size  int
+}
+
+// pooledContainer is an idle sandbox in the pool. Image is the reference the sandbox was created
+// from, which has CODE_SANDBOX_REGISTRY_MIRROR applied.
+type pooledContainer struct {
+	ID    string
+	Image string
+}
+
+// warmPool holds the sandboxes created ahead of time so sandbox_initialize can skip the create and
+// readiness probe. Creating counts the sandboxes being created to refill it.
+var warmPool = struct {
+	sync.Mutex
+	config   warmPoolConfig
+	idle     []pooledContainer
+	creating int
+	refill   chan struct{}
+}{refill: make(chan struct{}, 1)}
+
+// StartWarmPool keeps CODE_SANDBOX_WARM_POOL_SIZE idle sandboxes of CODE_SANDBOX_WARM_POOL_IMAGE
+// (default: the default image) ready, refilling the pool in the background whenever one is handed
+// out. Nothing is pooled when the size is unset or 0. The goroutine stops when ctx is cancelled.
+func StartWarmPool(ctx context.Context) {
+	size := envInt("CODE_SANDBOX_WARM_POOL_SIZE", 0)
+	if size <= 0 {
+		return
+	}
+	if size > maxWarmPoolSize {
+		logger.Warn("limiting the warm pool size", "size", size, "max", maxWarmPoolSize)
+		size = maxWarmPoolSize
+	}
+	image, err := normalizeImage(envImage("CODE_SANDBOX_WARM_POOL_IMAGE", defaultImage))
+	if err != nil {
+		logger.Error("warm pool disabled", "error", err)
+		return
+	}
+
+	warmPool.Lock()
+	warmPool.config = warmPoolConfig{Image: image, Size: int(size)}
+	warmPool.Unlock()
+	logger.Info("starting warm pool", "image", image, "size", size)
+
+	go func() {
+		ticker := time.NewTicker(warmPoolRetryDelay)
+		defer ticker.Stop()
+		for {
+			fillWarmPool(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-warmPool.refill:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// warmPoolOptions returns the options pooled sandboxes are created with: the defaults of
+// sandbox_initialize for the pool's image
+func warmPoolOptions(image string) (*containerOptions, error) {
+	return parseContainerOptions(map[string]interface{}{"image": image})
+}
+
+// fillWarmPool creates sandboxes one after the other until the pool is full, giving up until the
+// next retry at the first failure, e.g. when the image isn't available locally
+func fillWarmPool(ctx context.Context) {
+	for ctx.Err() == nil {
+		warmPool.Lock()
+		cfg := warmPool.config
+		if cfg.Size == 0 || len(warmPool.idle)+warmPool.creating >= cfg.Size {
+			warmPool.Unlock()
+			return
+		}
+		warmPool.creating++
+		warmPool.Unlock()
+
+		var id string
+		opts, err := warmPoolOptions(cfg.Image)
+		if err == nil {
+			id, err = createContainer(ctx, opts)
+		}
+
+		warmPool.Lock()
+		warmPool.creating--
+		switch {
+		case err != nil:
+			warmPool.Unlock()
+			logger.Warn("failed to create warm pool sandbox, retrying later", "image", cfg.Image, "retry_in", warmPoolRetryDelay, "error", err)
+			return
+		case warmPool.config.Size == 0:
+			// The pool was drained while this sandbox was being created
+			warmPool.Unlock()
+			discardContainer(id)
+			return
+		}
+		warmPool.idle = append(warmPool.idle, pooledContainer{ID: id, Image: opts.Image})
+		warmPool.Unlock()
+		logger.Info("warm pool sandbox ready", "container_id", id, "image", opts.Image)
+	}
+}
+
+// takePooledContainer hands out an idle pooled sandbox when opts are exactly the options pooled
+// sandboxes were created with, so the caller gets the sandbox it asked for. Options that only take
+// effect at create time, such as limits, mounts, labels or a name, never match. The sandbox is
+// reset like sandbox_reset first; a sandbox that can't be reset is removed, and false is returned
+// so the caller creates one instead.
+func takePooledContainer(ctx context.Context, opts *containerOptions) (string, bool) {
+	warmPool.Lock()
+	if warmPool.config.Size == 0 || len(warmPool.idle) == 0 {
+		warmPool.Unlock()
+		return "", false
+	}
+	want, err := warmPoolOptions(warmPool.config.Image)
+	got := *opts
+	got.OnPullProgress, got.Warnings = nil, nil
+	if err != nil || !reflect.DeepEqual(&got, want) {
+		warmPool.Unlock()
+		return "", false
+	}
+	pooled := warmPool.idle[0]
+	warmPool.idle = warmPool.idle[1:]
+	warmPool.Unlock()
+
+	// Refill in the background, so this request doesn't wait for it
+	select {
+	case warmPool.refill <- struct{}{}:
+	default:
+	}
+
+	if _, err := resetSandbox(ctx, pooled.ID); err != nil {
+		logger.Warn("failed to reset warm pool sandbox, discarding it", "container_id", pooled.ID, "error", err)
+		discardContainer(pooled.ID)
+		return "", false
+	}
+	opts.Image = pooled.Image
+	logger.Info("handed out warm pool sandbox", "container_id", pooled.ID, "image", pooled.Image)
+	return pooled.ID, true
+}
+
+// isPooled reports whether a container is idle in the warm pool, which the reaper and sandbox_list leave out
+func isPooled(containerID string) bool {
+	warmPool.Lock()
+	defer warmPool.Unlock()
+	for _, p := range warmPool.idle {
+		if p.ID == containerID {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetPooled drops a removed container from the pool, e.g. one removed by sandbox_stop_all
+func forgetPooled(containerID string) {
+	warmPool.Lock()
+	defer warmPool.Unlock()
+	for i, p := range warmPool.idle {
+		if strings.HasPrefix(p.ID, containerID) {
+			warmPool.idle = append(warmPool.idle[:i], warmPool.idle[i+1:]...)
+			return
+		}
+	}
+}
+
+// DrainPool removes every idle sandbox of the warm pool and stops refilling it until the server restarts
+func DrainPool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	warmPool.Lock()
+	warmPool.config.Size = 0
+	idle := warmPool.idle
+	warmPool.idle = nil
+	warmPool.Unlock()
+
+	result := &stopAllResult{RemovedIDs: []string{}, Failed: []stopAllFailure{}}
+	for _, p := range idle {
+		// Nothing in a pooled sandbox is worth a graceful stop
+		if err := stopAndRemoveContainer(ctx, p.ID, 0, true); err != nil {
+			result.Failed = append(result.Failed, stopAllFailure{ContainerID: p.ID, Error: err.Error()})
+			continue
+		}
+		forgetContainer(p.ID)
+		result.Removed++
+		result.RemovedIDs = append(result.RemovedIDs, p.ID)
+	}
+
+	summary := fmt.Sprintf("Drained the warm pool, removed %d sandbox(es)", result.Removed)
+	if len(result.Failed) > 0 {
+		summary += fmt.Sprintf(", %d could not be removed", len(result.Failed))
+	}
+	return newToolResultJSONWithSummary(result, summary)
+}
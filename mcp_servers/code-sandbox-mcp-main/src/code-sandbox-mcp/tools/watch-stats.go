@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// defaultStatsInterval is how often a watched sandbox's usage is reported
+	defaultStatsInterval = 2 * time.Second
+	// minStatsInterval is the rate the daemon produces stats at; shorter intervals couldn't be met
+	minStatsInterval = time.Second
+)
+
+// Reasons a watch ends with
+const (
+	watchCancelled = "cancelled"
+	watchStopped   = "stopped"
+	watchDuration  = "duration"
+)
+
+// watchStatsResult summarizes a watch once it ends
+type watchStatsResult struct {
+	ContainerID string `json:"container_id"`
+	// Reason is cancelled, stopped when the container stopped, or duration when duration_seconds passed
+	Reason          string          `json:"reason"`
+	Samples         int             `json:"samples"`
+	PeakCPUPercent  float64         `json:"peak_cpu_percent"`
+	PeakMemoryBytes uint64          `json:"peak_memory_usage_bytes"`
+	Last            *containerStats `json:"last,omitempty"`
+}
+
+// WatchStats reports the resource usage of a running container through progress notifications
+// until the client cancels the request, the container stops or duration_seconds passes
+func WatchStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Extract parameters
+	containerID, err := containerIDArg(ctx, request.Params.Arguments)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	interval, err := parseTimeoutSeconds(request.Params.Arguments, "interval_seconds", defaultStatsInterval)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+	if interval < minStatsInterval {
+		return newToolResultError(fmt.Sprintf("interval_seconds must be at least %g", minStatsInterval.Seconds())), nil
+	}
+
+	// Without a duration the watch lasts as long as the request
+	duration, err := parseTimeoutSeconds(request.Params.Arguments, "duration_seconds", 0)
+	if err != nil {
+		return newToolResultError(err.Error()), nil
+	}
+
+	var onSample func(*containerStats)
+	if request.Params.Meta != nil && request.Params.Meta.ProgressToken != nil {
+		onSample = statsNotifier(ctx, request.Params.Meta.ProgressToken)
+	}
+
+	result, err := watchContainerStats(ctx, containerID, interval, duration, onSample)
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("Error watching container stats: %v", err)), nil
+	}
+	return newToolResultJSON(result)
+}
+
+// watchContainerStats reads the daemon's stats stream and passes a sample to onSample every
+// interval. The stream is closed when ctx is cancelled, which unblocks the decoder, so nothing is
+// left reading from it once the watch returns.
+func watchContainerStats(ctx context.Context, containerID string, interval, duration time.Duration, onSample func(*containerStats)) (*watchStatsResult, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRunning(ctx, cli, containerID); err != nil {
+		return nil, err
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if duration > 0 {
+		watchCtx, cancel = context.WithTimeout(watchCtx, duration)
+		defer cancel()
+	}
+
+	resp, err := cli.ContainerStats(watchCtx, containerID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer resp.Body.Close()
+	stop := context.AfterFunc(watchCtx, func() { resp.Body.Close() })
+	defer stop()
+
+	result := &watchStatsResult{ContainerID: containerID}
+	var last time.Time
+
+	// The stream is a sequence of JSON objects, one per second, each carrying the previous
+	// sample's CPU counters in precpu_stats, so every object on its own gives a CPU percentage
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var raw container.StatsResponse
+		if err := dec.Decode(&raw); err != nil {
+			switch {
+			case ctx.Err() != nil:
+				result.Reason = watchCancelled
+			case watchCtx.Err() != nil:
+				result.Reason = watchDuration
+			case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+				result.Reason = watchStopped
+			default:
+				return nil, fmt.Errorf("failed to decode container stats: %w", err)
+			}
+			return result, nil
+		}
+
+		// A stopped container keeps sending samples without a read time
+		if raw.Read.IsZero() {
+			result.Reason = watchStopped
+			return result, nil
+		}
+		// The first sample has no previous counters to compute the CPU percentage from
+		if raw.PreCPUStats.SystemUsage == 0 || (!last.IsZero() && raw.Read.Sub(last) < interval) {
+			continue
+		}
+		last = raw.Read
+
+		stats := statsFromResponse(containerID, raw)
+		result.Samples++
+		result.Last = stats
+		result.PeakCPUPercent = max(result.PeakCPUPercent, stats.CPUPercent)
+		result.PeakMemoryBytes = max(result.PeakMemoryBytes, stats.MemoryUsageBytes)
+		if onSample != nil {
+			onSample(stats)
+		}
+	}
+}
+
+// statsNotifier returns a sample callback that sends each sample to the client as a
+// notifications/progress message, with the sample number as progress. Notifications are best
+// effort, like those of sandbox_exec_stream.
+func statsNotifier(ctx context.Context, token mcp.ProgressToken) func(*containerStats) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+
+	var progress float64
+	warned := false
+	return func(s *containerStats) {
+		progress++
+		err := srv.SendNotificationToClient(ctx, "notifications/progress", map[string]interface{}{
+			"progressToken": token,
+			"progress":      progress,
+			"message":       fmt.Sprintf("cpu %.1f%%, memory %.1f MB of %.1f MB, %d processes", s.CPUPercent, float64(s.MemoryUsageBytes)/1024/1024, float64(s.MemoryLimitBytes)/1024/1024, s.Pids),
+			"stats":         s,
+		})
+		if err != nil && !warned {
+			logger.Warn("failed to send stats notification", "error", err)
+			warned = true
+		}
+	}
+}
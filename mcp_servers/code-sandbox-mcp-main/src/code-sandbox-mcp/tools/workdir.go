@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// resolveContainerPath returns p unchanged if it is absolute, and otherwise resolves it
+// against the working directory the container was created with
+func resolveContainerPath(ctx context.Context, containerID string, p string) (string, error) {
+	if strings.HasPrefix(p, "/") {
+		return p, nil
+	}
+
+	workdir, err := containerWorkdir(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(workdir, p), nil
+}
+
+// containerWorkdir returns the working directory of a container, falling back to
+// the default for containers whose image and config don't set one
+func containerWorkdir(ctx context.Context, containerID string) (string, error) {
+	cli, err := DockerClient()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", inspectError(containerID, err)
+	}
+	if info.Config == nil || info.Config.WorkingDir == "" {
+		return defaultWorkdir, nil
+	}
+	return info.Config.WorkingDir, nil
+}